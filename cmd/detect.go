@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -24,12 +28,18 @@ var (
 	provider     string
 	interval     string
 	failOnDrift  bool
+	failOnError  bool
+	moduleDir    string
 )
 
 var detectCmd = &cobra.Command{
 	Use:   "detect",
 	Short: "Detect infrastructure drift",
 	Long: `Detect drift by comparing Terraform state with actual cloud resources.
+
+The state may also be the output of "terraform show -json", either of a
+state or of a saved plan. For a plan, cloud resources are compared against
+the planned values and drift reported by Terraform itself is included.
 	
 Examples:
   # One-time detection
@@ -54,6 +64,10 @@ func init() {
 	detectCmd.Flags().StringVarP(&provider, "provider", "p", "", "specific provider to check (aws, gcp, azure)")
 	detectCmd.Flags().StringVarP(&interval, "interval", "i", "5m", "check interval for watch mode")
 	detectCmd.Flags().BoolVar(&failOnDrift, "fail-on-drift", false, "exit with error code if drift detected (useful for CI/CD)")
+	detectCmd.Flags().BoolVar(&failOnError, "fail-on-error", false, "exit with error code if any resource could not be verified, a state failed to load or a detector failed")
+	detectCmd.Flags().StringVar(&moduleDir, "module-dir", "", "Terraform root module to compare state against (three-way config/state/cloud diff)")
+
+	_ = viper.BindPFlag("terraform.module_dir", detectCmd.Flags().Lookup("module-dir"))
 }
 
 func runDetect(cmd *cobra.Command, args []string) error {
@@ -90,54 +104,173 @@ func runSingleDetection(ctx context.Context) error {
 
 	// Load Terraform state
 	log.Info("Loading Terraform state...")
-	stateLoader := terraform.NewStateLoader(viper.GetString("terraform.state_path"))
-	state, err := stateLoader.LoadState(ctx)
+	targets, err := stateTargets()
+	if err != nil {
+		return fmt.Errorf("failed to configure Terraform state backend: %w", err)
+	}
+	states, stateErrs, err := loadStates(ctx, targets)
 	if err != nil {
 		return fmt.Errorf("failed to load Terraform state: %w", err)
 	}
-	color.Green("✓ Loaded Terraform state (%d resources)", len(state.Resources))
+	if err := checkStaleStates(states); err != nil {
+		return err
+	}
+
+	totalResources := 0
+	for _, state := range states {
+		totalResources += len(state.ManagedInstances())
+	}
+	if len(states) == 1 {
+		color.Green("✓ Loaded Terraform state (%d resources)", totalResources)
+	} else {
+		color.Green("✓ Loaded %d Terraform states (%d resources)", len(states), totalResources)
+	}
 
 	// Initialize detectors
-	driftDetectors := initializeDetectors()
+	driftDetectors := initializeDetectors(states, len(stateErrs) > 0)
 	if len(driftDetectors) == 0 {
 		return fmt.Errorf("no cloud providers enabled in configuration")
 	}
+	for _, state := range states {
+		if len(state.ReportedDrift) > 0 {
+			driftDetectors = append(driftDetectors, detectors.NewTerraformDetector())
+			break
+		}
+	}
+
+	configs, err := moduleConfigs(states)
+	if err != nil {
+		return err
+	}
 
 	// Detect drift
 	analyzer := drift.NewAnalyzer()
-	var allDrifts []drift.DriftItem
+	results := runDetectors(ctx, states, driftDetectors)
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("drift detection cancelled: %w", err)
+	}
 
-	for _, detector := range driftDetectors {
-		log.Infof("Checking %s resources...", detector.Name())
-		drifts, err := detector.Detect(ctx, state)
-		if err != nil {
-			log.Errorf("Error detecting drift in %s: %v", detector.Name(), err)
-			continue
+	var allDrifts []drift.DriftItem
+	allErrors := stateErrs
+
+	for i, state := range states {
+		var stateDrifts []drift.DriftItem
+		for j, detector := range driftDetectors {
+			result := results[i][j]
+			var scanErrs detectors.ScanErrors
+			if errors.As(result.err, &scanErrs) {
+				for _, scanErr := range scanErrs {
+					scanErr.State = state.Source
+					scanErr.Workspace = state.Workspace
+					allErrors = append(allErrors, scanErr)
+				}
+			} else if result.err != nil {
+				log.Errorf("Error detecting drift in %s: %v", detector.Name(), result.err)
+				allErrors = append(allErrors, drift.ScanError{
+					Provider:  detector.Name(),
+					Class:     drift.ErrorClassDetector,
+					Message:   result.err.Error(),
+					State:     state.Source,
+					Workspace: state.Workspace,
+				})
+				continue
+			}
+			stateDrifts = append(stateDrifts, result.drifts...)
+		}
+		if config, ok := configs[state.ModuleDir]; ok {
+			stateDrifts = detectors.CompareWithConfig(config, state, stateDrifts)
+		}
+		for i := range stateDrifts {
+			stateDrifts[i].State = state.Source
+			stateDrifts[i].Workspace = state.Workspace
 		}
-		allDrifts = append(allDrifts, drifts...)
+		allDrifts = append(allDrifts, stateDrifts...)
 	}
 
 	// Analyze results
-	report := analyzer.GenerateReport(allDrifts)
-	
+	report := analyzer.GenerateReport(allDrifts, allErrors, totalResources)
+
 	// Display results
 	displayResults(report, time.Since(startTime))
 
 	// Send notifications (unless dry-run)
-	if !dryRun && len(allDrifts) > 0 {
+	if !dryRun && (len(report.Drifts) > 0 || len(report.ScanErrors) > 0) {
 		if err := sendNotifications(ctx, report); err != nil {
 			log.Errorf("Failed to send notifications: %v", err)
 		}
 	}
 
 	// Exit with error if drift detected and flag is set
-	if failOnDrift && len(allDrifts) > 0 {
-		return fmt.Errorf("drift detected in %d resources", len(allDrifts))
+	if failOnDrift && len(report.Drifts) > 0 {
+		return fmt.Errorf("drift detected in %d resources", len(report.Drifts))
+	}
+
+	// Exit with error if coverage is incomplete and flag is set
+	if failOnError && len(report.ScanErrors) > 0 {
+		return fmt.Errorf("incomplete coverage: %s", summarizeScanErrors(report.ScanErrors))
 	}
 
 	return nil
 }
 
+// summarizeScanErrors counts scan errors by what failed: single resources,
+// whole states that could not be loaded, and detectors that failed on a state
+func summarizeScanErrors(scanErrors []drift.ScanError) string {
+	var resources, states, detectorRuns int
+	for _, e := range scanErrors {
+		switch {
+		case e.Address != "":
+			resources++
+		case e.Class == drift.ErrorClassDetector:
+			detectorRuns++
+		default:
+			states++
+		}
+	}
+
+	var parts []string
+	if resources > 0 {
+		parts = append(parts, fmt.Sprintf("%d resource(s) unverified", resources))
+	}
+	if states > 0 {
+		parts = append(parts, fmt.Sprintf("%d state(s) failed to load", states))
+	}
+	if detectorRuns > 0 {
+		parts = append(parts, fmt.Sprintf("%d detector run(s) failed", detectorRuns))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// detectorResult is the outcome of one detector on one state
+type detectorResult struct {
+	drifts []drift.DriftItem
+	err    error
+}
+
+// runDetectors runs every detector against every state in parallel. Results
+// are indexed by state and detector so the report order does not depend on
+// which finishes first.
+func runDetectors(ctx context.Context, states []*terraform.State, driftDetectors []detectors.Detector) [][]detectorResult {
+	results := make([][]detectorResult, len(states))
+
+	var wg sync.WaitGroup
+	for i, state := range states {
+		results[i] = make([]detectorResult, len(driftDetectors))
+		for j, detector := range driftDetectors {
+			wg.Add(1)
+			go func(i, j int, state *terraform.State, detector detectors.Detector) {
+				defer wg.Done()
+				log.Infof("Checking %s resources in %s...", detector.Name(), drift.StateLabel(state.Source, state.Workspace))
+				drifts, err := detector.Detect(ctx, state)
+				results[i][j] = detectorResult{drifts: drifts, err: err}
+			}(i, j, state, detector)
+		}
+	}
+	wg.Wait()
+
+	return results
+}
+
 func runContinuousDetection(ctx context.Context, interval time.Duration) error {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
@@ -161,14 +294,33 @@ func runContinuousDetection(ctx context.Context, interval time.Duration) error {
 	}
 }
 
-func initializeDetectors() []detectors.Detector {
+func initializeDetectors(states []*terraform.State, incompleteStates bool) []detectors.Detector {
 	var detectorList []detectors.Detector
 
+	var policyConfig detectors.APIPolicyConfig
+	if err := viper.UnmarshalKey("detection.api", &policyConfig); err != nil {
+		log.Errorf("Invalid detection.api configuration, using defaults: %v", err)
+	}
+	policy := detectors.NewAPIPolicy(policyConfig)
+
 	// AWS Detector
 	if viper.GetBool("providers.aws.enabled") && (provider == "" || provider == "aws") {
-		awsDetector, err := detectors.NewAWSDetector(
-			viper.GetStringSlice("providers.aws.regions"),
-		)
+		var accounts []detectors.AWSAccount
+		err := viper.UnmarshalKey("providers.aws.accounts", &accounts)
+		var awsDetector *detectors.AWSDetector
+		if err == nil {
+			awsDetector, err = detectors.NewAWSDetector(detectors.AWSConfig{
+				Regions:      viper.GetStringSlice("providers.aws.regions"),
+				AliasRegions: viper.GetStringMapString("providers.aws.provider_aliases"),
+				Accounts:     accounts,
+				IgnoreTags:   viper.GetStringSlice("providers.aws.ignore_tags"),
+				Workers:      viper.GetInt("detection.workers"),
+				Policy:       policy,
+				States:       states,
+
+				IncompleteStates: incompleteStates,
+			})
+		}
 		if err != nil {
 			log.Errorf("Failed to initialize AWS detector: %v", err)
 		} else {
@@ -213,34 +365,115 @@ func displayResults(report *drift.Report, duration time.Duration) {
 	color.Cyan("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println()
 
-	if len(report.Drifts) == 0 {
+	switch {
+	case len(report.Drifts) == 0 && len(report.ScanErrors) == 0:
 		color.Green("✓ No drift detected! Infrastructure is in sync with Terraform state.")
-	} else {
+	case len(report.Drifts) == 0:
+		color.Yellow("✓ No drift detected in the verified resources, but coverage is incomplete.")
+	default:
 		color.Yellow("⚠  Drift detected in %d resource(s):", len(report.Drifts))
 		fmt.Println()
 
-		for i, driftItem := range report.Drifts {
-			color.Red("  %d. %s (%s)", i+1, driftItem.ResourceName, driftItem.ResourceType)
-			color.Yellow("     Provider: %s", driftItem.Provider)
-			color.White("     Changes:")
-			for _, change := range driftItem.Changes {
-				color.White("       - %s: %v → %v", change.Field, change.Expected, change.Actual)
+		groups := report.GroupByState()
+		for _, group := range groups {
+			if len(groups) > 1 {
+				color.Cyan("  State: %s", drift.StateLabel(group.State, group.Workspace))
+				fmt.Println()
+			}
+			for i, driftItem := range group.Drifts {
+				color.Red("  %d. %s", i+1, driftItem.Address)
+				color.Yellow("     Provider: %s%s", driftItem.Provider, locationLabel(driftItem))
+				color.White("     Changes:")
+				for _, change := range driftItem.Changes {
+					if change.Ignored {
+						if viper.GetBool("verbose") {
+							color.HiBlack("       - %s: %v → %v (ignored by lifecycle)", change.Field, change.Expected, change.Actual)
+						}
+						continue
+					}
+					color.White("       - %s: %v → %v%s", change.Field, change.Expected, change.Actual, originLabel(change))
+				}
+				fmt.Println()
+			}
+		}
+	}
+
+	if len(report.ScanErrors) > 0 {
+		fmt.Println()
+		color.Red("✗ %d resource(s) unverified (could not be checked):", len(report.ScanErrors))
+		for _, scanErr := range report.ScanErrors {
+			target := scanErr.Address
+			if target == "" {
+				target = "all " + scanErr.Provider + " resources in " + drift.StateLabel(scanErr.State, scanErr.Workspace)
 			}
-			fmt.Println()
+			color.Red("   • [%s] %s (%s): %s", scanErr.Class, target, scanErr.Provider, scanErr.Message)
 		}
+		fmt.Println()
+	}
 
+	if len(report.Drifts) > 0 || len(report.ScanErrors) > 0 {
 		// Summary
 		color.Cyan("Summary:")
 		color.White("  Total Resources Checked: %d", report.TotalResources)
 		color.Red("  Resources with Drift: %d", len(report.Drifts))
+		if len(report.ScanErrors) > 0 {
+			color.Red("  Unverified Resources: %d", len(report.ScanErrors))
+		}
+		if len(report.Ignored) > 0 {
+			color.White("  Ignored by lifecycle: %d", len(report.Ignored))
+		}
 		color.White("  Detection Time: %v", duration)
 	}
 
+	if len(report.Ignored) > 0 && viper.GetBool("verbose") {
+		fmt.Println()
+		color.HiBlack("  Ignored by lifecycle ignore_changes:")
+		for _, driftItem := range report.Ignored {
+			color.HiBlack("   • %s", driftItem.Address)
+			for _, change := range driftItem.Changes {
+				color.HiBlack("       - %s: %v → %v", change.Field, change.Expected, change.Actual)
+			}
+		}
+	}
+
 	fmt.Println()
 	color.Cyan("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
 	fmt.Println()
 }
 
+// locationLabel formats the account and region of a drift item, if known
+func locationLabel(item drift.DriftItem) string {
+	switch {
+	case item.AccountID != "" && item.Region != "":
+		return fmt.Sprintf(" (account %s, %s)", item.AccountID, item.Region)
+	case item.AccountID != "":
+		return fmt.Sprintf(" (account %s)", item.AccountID)
+	case item.Region != "":
+		return fmt.Sprintf(" (%s)", item.Region)
+	default:
+		return ""
+	}
+}
+
+// originLabel describes where a change came from when configuration was compared
+func originLabel(change drift.Change) string {
+	switch change.Origin {
+	case drift.OriginCloud:
+		return " [changed outside Terraform]"
+	case drift.OriginState:
+		if reflect.DeepEqual(change.Configured, change.Expected) {
+			return " [state differs from configuration]"
+		}
+		return fmt.Sprintf(" [state differs from configuration: %v]", change.Configured)
+	case drift.OriginCloudAndState:
+		return fmt.Sprintf(" [configuration, state and cloud all differ; configured: %v]", change.Configured)
+	case drift.OriginUnknown:
+		return " [origin unknown: may be managed in a state that failed to load]"
+	default:
+		return ""
+	}
+}
+
 func sendNotifications(ctx context.Context, report *drift.Report) error {
 	color.Cyan("📤 Sending notifications...")
 
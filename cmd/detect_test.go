@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+)
+
+func TestSummarizeScanErrors(t *testing.T) {
+	resource := drift.ScanError{Address: "aws_instance.web", Provider: "AWS", Class: drift.ErrorClassThrottled}
+	state := drift.ScanError{Provider: "Terraform", Class: drift.ErrorClassStateDecrypt, State: "prod.tfstate"}
+	detector := drift.ScanError{Provider: "GCP", Class: drift.ErrorClassDetector, State: "prod.tfstate"}
+
+	tests := []struct {
+		name   string
+		errors []drift.ScanError
+		want   string
+	}{
+		{"resources only", []drift.ScanError{resource, resource}, "2 resource(s) unverified"},
+		{"state only", []drift.ScanError{state}, "1 state(s) failed to load"},
+		{"all classes", []drift.ScanError{resource, state, detector, state}, "1 resource(s) unverified, 2 state(s) failed to load, 1 detector run(s) failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeScanErrors(tt.errors); got != tt.want {
+				t.Errorf("summarizeScanErrors() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -47,7 +47,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 # Terraform Configuration
 terraform:
-  # Backend type: local, s3, gcs, azurerm
+  # Backend type: local, s3, gcs, azurerm, cloud (or remote), http
   state_backend: "local"
   
   # Path to state file (for local backend)
@@ -58,11 +58,72 @@ terraform:
     bucket: "my-terraform-state"
     key: "production/terraform.tfstate"
     region: "us-east-1"
+    # Optional: pin a specific object version of a versioned bucket
+    version_id: ""
+    # Optional: require SSE-KMS encryption with this key (ID or ARN)
+    kms_key_id: ""
+    # Optional: base64 encoded key for SSE-C encrypted state
+    sse_customer_key: ""
+    # Optional: custom endpoint, e.g. a local S3 stand-in
+    endpoint: ""
+    use_path_style: false
   
   # GCS backend configuration (if using gcs)
   gcs:
     bucket: "my-terraform-state"
     prefix: "production"
+    # Credentials: GOOGLE_OAUTH_ACCESS_TOKEN env var or access_token
+    # Optional: custom endpoint, e.g. a local GCS emulator
+    endpoint: ""
+  
+  # Azure Blob Storage backend configuration (if using azurerm)
+  azurerm:
+    storage_account_name: "tfstate"
+    container_name: "tfstate"
+    key: "production.terraform.tfstate"
+    # Credentials: ARM_ACCESS_KEY or ARM_SAS_TOKEN env vars
+    # Optional: custom endpoint, e.g. a local Azurite emulator
+    endpoint: ""
+  
+  # Terraform Cloud / Enterprise configuration (if using cloud or remote)
+  cloud:
+    hostname: "app.terraform.io"
+    organization: "my-org"
+    workspace: "production"
+    # Credentials: TF_TOKEN_app_terraform_io or TFE_TOKEN env vars
+  
+  # Generic HTTP backend configuration (if using http)
+  http:
+    address: "https://state.example.com/production"
+    # Credentials: TF_HTTP_USERNAME and TF_HTTP_PASSWORD env vars
+    # Optional: hold the state lock while reading
+    lock_address: ""
+    unlock_address: ""
+  
+  # Terraform workspace to read
+  workspace: "default"
+  
+  # Stale state handling when serial goes backwards or lineage changes
+  # between runs: warn, fail or ignore
+  stale_state: "warn"
+  # Where last-seen lineage/serial are kept (default ~/.drift-detector/state-tracking.json)
+  state_tracking_file: ""
+  
+  # Root module to compare state against (three-way config/state/cloud diff)
+  module_dir: ""
+  # Extra .tfvars files used when evaluating the configuration
+  var_files: []
+  
+  # Scan several states in one run (overrides state_backend/state_path).
+  # Entries are local paths/globs or backend entries; backend blocks not
+  # given in an entry are inherited from the settings above.
+  # states:
+  #   - "./stacks/*/terraform.tfstate"
+  #   - backend: "s3"
+  #     s3:
+  #       key: "network/terraform.tfstate"
+  #     workspaces: ["default", "staging"]
+  #     module_dir: "./network"
 
 # Cloud Provider Configuration
 providers:
@@ -72,6 +133,27 @@ providers:
     regions:
       - "us-east-1"
       - "us-west-2"
+    # Resources are checked in the region found in their state (ARN, region
+    # or availability zone). Otherwise the region of their provider alias is
+    # used, falling back to the first region above.
+    provider_aliases:
+      # west: "us-west-2"
+    # Accounts reached by assuming a role. Resources use the account whose
+    # provider_aliases (or name) match their provider alias, else the account
+    # whose states globs match their state, else the default credentials.
+    accounts: []
+    #   - name: "prod"
+    #     role_arn: "arn:aws:iam::123456789012:role/DriftDetector"
+    #     external_id: ""
+    #     session_name: "drift-detector"
+    #     provider_aliases: ["prod"]
+    #     states: ["s3://tf-state/prod/*"]
+    # Tag keys written by systems other than Terraform, as glob patterns.
+    # Tags are compared against tags_all, so provider default_tags count as
+    # managed; aws:* tags are always ignored.
+    ignore_tags: []
+    #   - "CostCenter"
+    #   - "kubernetes.io/cluster/*"
     # Credentials: Use AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY env vars
     # Or configure AWS CLI: aws configure
     
@@ -94,6 +176,28 @@ detection:
   # Check interval for watch mode
   interval: "5m"
   
+  # Concurrent resource checks per provider account and region
+  workers: 10
+  
+  # Cloud API call policy. Throttled and transient failures are retried with
+  # exponential backoff and jitter; resources still failing are reported as
+  # unverified. Rate limits are token buckets per provider and region.
+  api:
+    retry:
+      max_attempts: 5
+      base_delay: "500ms"
+      max_delay: "20s"
+    rate_limits:
+      default:
+        requests_per_second: 10
+        burst: 20
+      aws:
+        requests_per_second: 20
+        burst: 40
+        regions:
+          # us-east-1:
+          #   requests_per_second: 10
+  
   # Resource types to monitor (leave empty to monitor all)
   resources_to_monitor:
     - "aws_instance"
@@ -154,8 +258,13 @@ notifications:
 
 # Security Configuration
 security:
-  # Encrypt sensitive data in state
+  # Decrypt encrypted state files (OpenTofu pbkdf2/aes_gcm or age) before parsing
   encrypt_state: true
+  # Keys for encrypted state; when no file is set, the passphrase is read from
+  # DRIFT_STATE_PASSPHRASE and age identities from DRIFT_AGE_IDENTITY
+  state_encryption:
+    passphrase_file: ""
+    age_identity_file: ""
   
   # Read-only mode (don't modify any resources)
   read_only: true
@@ -0,0 +1,424 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/MeowTux/drift-detector/internal/detectors"
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// maxConcurrentStateLoads bounds how many states are fetched at once
+const maxConcurrentStateLoads = 8
+
+// stateTarget is a single state (backend + workspace) to scan
+type stateTarget struct {
+	workspace string
+	moduleDir string
+	source    terraform.StateSource
+}
+
+// terraformConfig returns the terraform section of the configuration
+func terraformConfig() *viper.Viper {
+	cfg := viper.Sub("terraform")
+	if cfg == nil {
+		cfg = viper.New()
+	}
+	return cfg
+}
+
+// stateTargets builds the list of states to scan. terraform.states takes
+// precedence; otherwise the single state_backend/state_path pair is used.
+func stateTargets() ([]stateTarget, error) {
+	base := terraformConfig()
+
+	entries, ok := base.Get("states").([]interface{})
+	if !ok || len(entries) == 0 {
+		targets, err := targetsForEntry(base, []string{base.GetString("workspace")})
+		return withModuleDir(targets, viper.GetString("terraform.module_dir")), err
+	}
+
+	var targets []stateTarget
+	for i, entry := range entries {
+		var entryTargets []stateTarget
+		var err error
+
+		switch e := entry.(type) {
+		case string:
+			entryTargets, err = localTargets(e, []string{""})
+		case map[string]interface{}:
+			entryTargets, err = targetsForMapEntry(base, e)
+			if moduleDir, ok := e["module_dir"].(string); ok {
+				entryTargets = withModuleDir(entryTargets, moduleDir)
+			}
+		default:
+			err = fmt.Errorf("unsupported entry type %T", entry)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("terraform.states[%d]: %w", i, err)
+		}
+		targets = append(targets, entryTargets...)
+	}
+
+	return targets, nil
+}
+
+// targetsForMapEntry builds targets for a terraform.states entry of the form
+// {backend, path, workspaces, <backend block>}. Backend blocks not given in
+// the entry are inherited from the top-level terraform section.
+func targetsForMapEntry(base *viper.Viper, entry map[string]interface{}) ([]stateTarget, error) {
+	cfg := viper.New()
+	if err := cfg.MergeConfigMap(base.AllSettings()); err != nil {
+		return nil, err
+	}
+	if err := cfg.MergeConfigMap(entry); err != nil {
+		return nil, err
+	}
+
+	backend := cfg.GetString("backend")
+	if backend == "" {
+		backend = "local"
+	}
+	cfg.Set("state_backend", backend)
+	if path := cfg.GetString("path"); path != "" {
+		cfg.Set("state_path", path)
+	}
+
+	workspaces := cfg.GetStringSlice("workspaces")
+	if len(workspaces) == 0 {
+		workspaces = []string{cfg.GetString("workspace")}
+	}
+
+	return targetsForEntry(cfg, workspaces)
+}
+
+// targetsForEntry builds one target per workspace for a backend configuration
+func targetsForEntry(cfg *viper.Viper, workspaces []string) ([]stateTarget, error) {
+	backend := cfg.GetString("state_backend")
+	if backend == "" || backend == "local" {
+		return localTargets(cfg.GetString("state_path"), workspaces)
+	}
+
+	var targets []stateTarget
+	for _, workspace := range workspaces {
+		source, err := newStateSource(cfg, workspace)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, stateTarget{workspace: workspace, source: source})
+	}
+	return targets, nil
+}
+
+// withModuleDir sets the configuration root module of each target
+func withModuleDir(targets []stateTarget, moduleDir string) []stateTarget {
+	for i := range targets {
+		targets[i].moduleDir = moduleDir
+	}
+	return targets
+}
+
+// localTargets expands a local state path or glob for each workspace
+func localTargets(pattern string, workspaces []string) ([]stateTarget, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state path pattern %q: %w", pattern, err)
+	}
+	if len(paths) == 0 {
+		// Not a glob, or nothing matched: let loading report the missing file
+		paths = []string{pattern}
+	}
+
+	var targets []stateTarget
+	for _, path := range paths {
+		for _, workspace := range workspaces {
+			targets = append(targets, stateTarget{
+				workspace: workspace,
+				source:    terraform.NewLocalSource(terraform.LocalWorkspacePath(path, workspace)),
+			})
+		}
+	}
+	return targets, nil
+}
+
+// newStateSource builds the state source selected by state_backend
+func newStateSource(cfg *viper.Viper, workspace string) (terraform.StateSource, error) {
+	switch backend := cfg.GetString("state_backend"); backend {
+	case "", "local":
+		return terraform.NewLocalSource(terraform.LocalWorkspacePath(cfg.GetString("state_path"), workspace)), nil
+	case "s3":
+		return terraform.NewS3Source(terraform.S3Config{
+			Bucket:             cfg.GetString("s3.bucket"),
+			Key:                cfg.GetString("s3.key"),
+			Region:             cfg.GetString("s3.region"),
+			Workspace:          workspace,
+			WorkspaceKeyPrefix: cfg.GetString("s3.workspace_key_prefix"),
+			VersionID:          cfg.GetString("s3.version_id"),
+			KMSKeyID:           cfg.GetString("s3.kms_key_id"),
+			SSECustomerKey:     cfg.GetString("s3.sse_customer_key"),
+			Endpoint:           cfg.GetString("s3.endpoint"),
+			UsePathStyle:       cfg.GetBool("s3.use_path_style"),
+		})
+	case "gcs":
+		return terraform.NewGCSSource(terraform.GCSConfig{
+			Bucket:        cfg.GetString("gcs.bucket"),
+			Prefix:        cfg.GetString("gcs.prefix"),
+			Workspace:     workspace,
+			AccessToken:   cfg.GetString("gcs.access_token"),
+			EncryptionKey: cfg.GetString("gcs.encryption_key"),
+			Endpoint:      cfg.GetString("gcs.endpoint"),
+		})
+	case "azurerm":
+		return terraform.NewAzureSource(terraform.AzureConfig{
+			StorageAccount: cfg.GetString("azurerm.storage_account_name"),
+			Container:      cfg.GetString("azurerm.container_name"),
+			Key:            cfg.GetString("azurerm.key"),
+			Workspace:      workspace,
+			AccessKey:      cfg.GetString("azurerm.access_key"),
+			SASToken:       cfg.GetString("azurerm.sas_token"),
+			Endpoint:       cfg.GetString("azurerm.endpoint"),
+		})
+	case "remote", "cloud":
+		if workspace == "" || workspace == "default" {
+			workspace = cfg.GetString("cloud.workspace")
+		}
+		return terraform.NewCloudSource(terraform.CloudConfig{
+			Hostname:     cfg.GetString("cloud.hostname"),
+			Organization: cfg.GetString("cloud.organization"),
+			Workspace:    workspace,
+			Token:        cfg.GetString("cloud.token"),
+			Endpoint:     cfg.GetString("cloud.endpoint"),
+		})
+	case "http":
+		if workspace != "" && workspace != "default" {
+			return nil, fmt.Errorf("http backend does not support workspaces")
+		}
+		return terraform.NewHTTPSource(terraform.HTTPConfig{
+			Address:              cfg.GetString("http.address"),
+			Username:             cfg.GetString("http.username"),
+			Password:             cfg.GetString("http.password"),
+			LockAddress:          cfg.GetString("http.lock_address"),
+			LockMethod:           cfg.GetString("http.lock_method"),
+			UnlockAddress:        cfg.GetString("http.unlock_address"),
+			UnlockMethod:         cfg.GetString("http.unlock_method"),
+			SkipCertVerification: cfg.GetBool("http.skip_cert_verification"),
+		})
+	default:
+		return nil, fmt.Errorf("unsupported state backend: %s", backend)
+	}
+}
+
+// loadStates loads all targets concurrently. States that fail to load are
+// skipped and returned as scan errors, since none of their resources were
+// checked; an error is returned only if none could be loaded.
+func loadStates(ctx context.Context, targets []stateTarget) ([]*terraform.State, []drift.ScanError, error) {
+	decrypter, err := stateDecrypter()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	states := make([]*terraform.State, len(targets))
+	errs := make([]error, len(targets))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentStateLoads)
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target stateTarget) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			state, err := terraform.NewStateLoaderFromSource(target.source).
+				WithDecrypter(decrypter).
+				LoadState(ctx)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			state.Workspace = target.workspace
+			state.ModuleDir = target.moduleDir
+			states[i] = state
+		}(i, target)
+	}
+	wg.Wait()
+
+	var loaded []*terraform.State
+	var scanErrs []drift.ScanError
+	var lastErr error
+	for i, state := range states {
+		if errs[i] != nil {
+			log.Errorf("Failed to load state %s: %v", targets[i].source.Location(), errs[i])
+			scanErrs = append(scanErrs, drift.ScanError{
+				Provider:  "Terraform",
+				Class:     classifyStateError(errs[i]),
+				Message:   errs[i].Error(),
+				State:     targets[i].source.Location(),
+				Workspace: targets[i].workspace,
+			})
+			lastErr = errs[i]
+			continue
+		}
+		loaded = append(loaded, state)
+	}
+
+	if len(loaded) == 0 && lastErr != nil {
+		return nil, nil, lastErr
+	}
+	return loaded, scanErrs, nil
+}
+
+// classifyStateError maps the error of a state that could not be loaded to
+// a scan error class
+func classifyStateError(err error) string {
+	var loadErr *terraform.LoadError
+	if errors.As(err, &loadErr) {
+		switch loadErr.Stage {
+		case terraform.LoadStageDecrypt:
+			return drift.ErrorClassStateDecrypt
+		case terraform.LoadStageParse:
+			return drift.ErrorClassStateInvalid
+		}
+	}
+
+	var statusErr *terraform.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return drift.ErrorClassAccessDenied
+		case http.StatusNotFound:
+			return drift.ErrorClassNotFoundAmbiguous
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return drift.ErrorClassThrottled
+		}
+		return drift.ErrorClassUnknown
+	}
+
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return drift.ErrorClassNotFoundAmbiguous
+	case errors.Is(err, fs.ErrPermission):
+		return drift.ErrorClassAccessDenied
+	}
+
+	// The S3 backend goes through the AWS SDK
+	return detectors.ClassifyAWSError(err)
+}
+
+// stateDecrypter builds the decrypter for encrypted state when
+// security.encrypt_state is enabled. The passphrase and age identities come
+// from the files in security.state_encryption or from the
+// DRIFT_STATE_PASSPHRASE and DRIFT_AGE_IDENTITY environment variables.
+// Without any key no decrypter is returned, so only encrypted states fail.
+func stateDecrypter() (*terraform.Decrypter, error) {
+	if !viper.GetBool("security.encrypt_state") {
+		return nil, nil
+	}
+
+	passphrase, err := secretFromFileOrEnv(viper.GetString("security.state_encryption.passphrase_file"), "DRIFT_STATE_PASSPHRASE")
+	if err != nil {
+		return nil, err
+	}
+	identities, err := secretFromFileOrEnv(viper.GetString("security.state_encryption.age_identity_file"), "DRIFT_AGE_IDENTITY")
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" && identities == "" {
+		return nil, nil
+	}
+
+	return terraform.NewDecrypter(passphrase, identities)
+}
+
+// secretFromFileOrEnv reads a secret from a file if one is given, otherwise
+// from an environment variable
+func secretFromFileOrEnv(path, envName string) (string, error) {
+	if path == "" {
+		return os.Getenv(envName), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// checkStaleStates compares the loaded states against the lineage and serial
+// seen in previous runs. Depending on terraform.stale_state it warns about
+// (warn, the default), fails on (fail) or ignores (ignore) stale states.
+func checkStaleStates(states []*terraform.State) error {
+	mode := viper.GetString("terraform.stale_state")
+	switch mode {
+	case "":
+		mode = "warn"
+	case "warn", "fail":
+	case "ignore":
+		return nil
+	default:
+		return fmt.Errorf("invalid terraform.stale_state %q (expected warn, fail or ignore)", mode)
+	}
+
+	path := viper.GetString("terraform.state_tracking_file")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to locate home directory for state tracking: %w", err)
+		}
+		path = filepath.Join(home, ".drift-detector", "state-tracking.json")
+	}
+
+	tracker, err := terraform.NewStateTracker(path)
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	for _, state := range states {
+		if err := tracker.Observe(state); err != nil {
+			log.Warn(err)
+			stale = append(stale, terraform.StateKey(state))
+		}
+	}
+
+	if err := tracker.Save(); err != nil {
+		log.Warnf("Failed to save state tracking file: %v", err)
+	}
+
+	if mode == "fail" && len(stale) > 0 {
+		return fmt.Errorf("stale Terraform state detected: %s", strings.Join(stale, ", "))
+	}
+	return nil
+}
+
+// moduleConfigs loads the Terraform configuration of each root module
+// referenced by the states, keyed by directory
+func moduleConfigs(states []*terraform.State) (map[string]*terraform.ModuleConfig, error) {
+	configs := make(map[string]*terraform.ModuleConfig)
+	varFiles := viper.GetStringSlice("terraform.var_files")
+
+	for _, state := range states {
+		if state.ModuleDir == "" {
+			continue
+		}
+		if _, ok := configs[state.ModuleDir]; ok {
+			continue
+		}
+		config, err := terraform.LoadModuleConfig(state.ModuleDir, varFiles)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Terraform configuration: %w", err)
+		}
+		configs[state.ModuleDir] = config
+	}
+
+	return configs, nil
+}
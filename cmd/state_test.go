@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+)
+
+func TestLoadStatesReportsFailedStates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	good := write("good.tfstate", `{"version": 4, "serial": 1, "lineage": "abc", "resources": []}`)
+	invalid := write("invalid.tfstate", `{"version": 4, "serial": 1, "resources": []}`)
+	encrypted := write("encrypted.tfstate", `{"meta": {}, "encrypted_data": "AAAA", "encryption_version": "v0"}`)
+	missing := filepath.Join(dir, "missing.tfstate")
+
+	targets := []stateTarget{
+		{source: terraform.NewLocalSource(good)},
+		{source: terraform.NewLocalSource(invalid), workspace: "staging"},
+		{source: terraform.NewLocalSource(encrypted)},
+		{source: terraform.NewLocalSource(missing)},
+	}
+
+	states, scanErrs, err := loadStates(context.Background(), targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].Source != good {
+		t.Fatalf("loaded %d states, want only %s", len(states), good)
+	}
+
+	want := []struct {
+		state, workspace, class string
+	}{
+		{invalid, "staging", drift.ErrorClassStateInvalid},
+		{encrypted, "", drift.ErrorClassStateDecrypt},
+		{missing, "", drift.ErrorClassNotFoundAmbiguous},
+	}
+	if len(scanErrs) != len(want) {
+		t.Fatalf("got %d scan errors, want %d: %+v", len(scanErrs), len(want), scanErrs)
+	}
+	for i, w := range want {
+		got := scanErrs[i]
+		if got.State != w.state || got.Workspace != w.workspace || got.Class != w.class || got.Provider != "Terraform" || got.Address != "" {
+			t.Errorf("scan error %d = %+v, want state %s, workspace %q, class %s", i, got, w.state, w.workspace, w.class)
+		}
+	}
+}
+
+func TestLoadStatesNoneLoaded(t *testing.T) {
+	targets := []stateTarget{{source: terraform.NewLocalSource(filepath.Join(t.TempDir(), "missing.tfstate"))}}
+	if _, _, err := loadStates(context.Background(), targets); err == nil {
+		t.Fatal("loadStates() succeeded, want an error when no state loads")
+	}
+}
+
+func TestClassifyStateError(t *testing.T) {
+	fetch := func(err error) error {
+		return &terraform.LoadError{Location: "https://state.example.com", Stage: terraform.LoadStageFetch, Err: err}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"forbidden", fetch(&terraform.HTTPStatusError{StatusCode: 403}), drift.ErrorClassAccessDenied},
+		{"unauthorized", fetch(&terraform.HTTPStatusError{StatusCode: 401}), drift.ErrorClassAccessDenied},
+		{"not found", fetch(&terraform.HTTPStatusError{StatusCode: 404}), drift.ErrorClassNotFoundAmbiguous},
+		{"throttled", fetch(fmt.Errorf("wrapped: %w", &terraform.HTTPStatusError{StatusCode: 429})), drift.ErrorClassThrottled},
+		{"server error", fetch(&terraform.HTTPStatusError{StatusCode: 500}), drift.ErrorClassUnknown},
+		{"decrypt", &terraform.LoadError{Stage: terraform.LoadStageDecrypt, Err: terraform.ErrStateEncrypted}, drift.ErrorClassStateDecrypt},
+		{"parse", &terraform.LoadError{Stage: terraform.LoadStageParse, Err: errors.New("missing lineage")}, drift.ErrorClassStateInvalid},
+		{"other", fetch(errors.New("boom")), drift.ErrorClassUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyStateError(tt.err); got != tt.want {
+				t.Errorf("classifyStateError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
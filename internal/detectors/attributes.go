@@ -0,0 +1,78 @@
+package detectors
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Helpers for reading state attributes, which encoding/json decodes as
+// string, float64, bool, []interface{} and map[string]interface{}
+
+func stringAttr(attrs map[string]interface{}, name string) string {
+	s, _ := attrs[name].(string)
+	return s
+}
+
+func boolAttr(attrs map[string]interface{}, name string) bool {
+	b, _ := attrs[name].(bool)
+	return b
+}
+
+func intAttr(attrs map[string]interface{}, name string) int {
+	switch v := attrs[name].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	default:
+		return 0
+	}
+}
+
+// stringListAttr returns a list or set of strings, sorted
+func stringListAttr(attrs map[string]interface{}, name string) []string {
+	list, _ := attrs[name].([]interface{})
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		if s, ok := v.(string); ok && s != "" {
+			result = append(result, s)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// blockAttr returns the objects of a nested block such as ingress
+func blockAttr(attrs map[string]interface{}, name string) []map[string]interface{} {
+	list, _ := attrs[name].([]interface{})
+	result := make([]map[string]interface{}, 0, len(list))
+	for _, v := range list {
+		if m, ok := v.(map[string]interface{}); ok {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// stringMapAttr returns a map of strings such as tags
+func stringMapAttr(attrs map[string]interface{}, name string) map[string]string {
+	m, _ := attrs[name].(map[string]interface{})
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = fmt.Sprint(v)
+	}
+	return result
+}
+
+// firstBlock returns the single object of a nested block such as
+// metadata_options, or nil when the state has none
+func firstBlock(attrs map[string]interface{}, name string) map[string]interface{} {
+	if blocks := blockAttr(attrs, name); len(blocks) > 0 {
+		return blocks[0]
+	}
+	return nil
+}
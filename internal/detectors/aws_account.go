@@ -0,0 +1,162 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultSessionName is used when an account does not set a session name
+const defaultSessionName = "drift-detector"
+
+// AWSAccount configures an AWS account reached by assuming a role
+type AWSAccount struct {
+	Name        string `mapstructure:"name"`
+	AccountID   string `mapstructure:"account_id"`
+	RoleARN     string `mapstructure:"role_arn"`
+	ExternalID  string `mapstructure:"external_id"`
+	SessionName string `mapstructure:"session_name"`
+
+	// ProviderAliases lists the provider aliases whose resources live in
+	// this account; when empty, an alias equal to Name matches
+	ProviderAliases []string `mapstructure:"provider_aliases"`
+
+	// States lists glob patterns of state locations whose resources live
+	// in this account
+	States []string `mapstructure:"states"`
+}
+
+// awsAccount holds the clients of one account and the result of assuming
+// its role
+type awsAccount struct {
+	AWSAccount
+	clients *awsRegionClients
+
+	once      sync.Once
+	assumeErr error
+}
+
+func newAWSAccount(base aws.Config, account AWSAccount, regions []string, policy *APIPolicy) *awsAccount {
+	cfg := base.Copy()
+
+	if account.RoleARN != "" {
+		if account.AccountID == "" {
+			account.AccountID = accountFromARN(account.RoleARN)
+		}
+		sessionName := account.SessionName
+		if sessionName == "" {
+			sessionName = defaultSessionName
+		}
+
+		provider := stscreds.NewAssumeRoleProvider(newSTSClient(base, policy), account.RoleARN,
+			func(o *stscreds.AssumeRoleOptions) {
+				o.RoleSessionName = sessionName
+				if account.ExternalID != "" {
+					o.ExternalID = aws.String(account.ExternalID)
+				}
+			})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+
+	return &awsAccount{
+		AWSAccount: account,
+		clients:    newAWSRegionClients(cfg, regions, policy),
+	}
+}
+
+// assume retrieves credentials for the account's role once, so an account
+// whose role cannot be assumed is reported instead of failing per call
+func (a *awsAccount) assume(ctx context.Context) error {
+	a.once.Do(func() {
+		if a.RoleARN == "" {
+			return
+		}
+		if _, err := a.clients.cfg.Credentials.Retrieve(ctx); err != nil {
+			a.assumeErr = fmt.Errorf("failed to assume role %s for account %s: %w", a.RoleARN, a.label(), err)
+			log.Errorf("%v", a.assumeErr)
+		}
+	})
+	return a.assumeErr
+}
+
+// label names the account in messages
+func (a *awsAccount) label() string {
+	switch {
+	case a.Name != "" && a.AccountID != "":
+		return fmt.Sprintf("%s (%s)", a.Name, a.AccountID)
+	case a.Name != "":
+		return a.Name
+	case a.AccountID != "":
+		return a.AccountID
+	default:
+		return "default"
+	}
+}
+
+func (a *awsAccount) matchesAlias(alias string) bool {
+	if len(a.ProviderAliases) == 0 {
+		return alias == a.Name
+	}
+	for _, candidate := range a.ProviderAliases {
+		if candidate == alias {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *awsAccount) matchesState(location string) bool {
+	for _, pattern := range a.States {
+		if ok, _ := filepath.Match(pattern, location); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// accountFor picks the account of a resource: by the alias of its provider,
+// then by the state it was loaded from, then the default credentials
+func (d *AWSDetector) accountFor(state *terraform.State, resource terraform.ResourceInstance) *awsAccount {
+	if alias := resource.ProviderAlias(); alias != "" {
+		for _, account := range d.accounts {
+			if account.matchesAlias(alias) {
+				return account
+			}
+		}
+	}
+
+	for _, account := range d.accounts {
+		if account.matchesState(state.Source) {
+			return account
+		}
+	}
+
+	return d.defaultAccount
+}
+
+// callerAccountID looks up the account of the given credentials with
+// sts:GetCallerIdentity
+func callerAccountID(ctx context.Context, cfg aws.Config, policy *APIPolicy) (string, error) {
+	out, err := newSTSClient(cfg, policy).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Account), nil
+}
+
+// accountFromARN returns the account ID field of an ARN
+func accountFromARN(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[4]
+}
@@ -0,0 +1,79 @@
+package detectors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestCallerAccountID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if action := r.Form.Get("Action"); action != "GetCallerIdentity" {
+			t.Errorf("Action = %q, want GetCallerIdentity", action)
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult>
+    <Arn>arn:aws:iam::111122223333:user/ci</Arn>
+    <UserId>AIDAEXAMPLE</UserId>
+    <Account>111122223333</Account>
+  </GetCallerIdentityResult>
+  <ResponseMetadata><RequestId>1</RequestId></ResponseMetadata>
+</GetCallerIdentityResponse>`))
+	}))
+	defer srv.Close()
+
+	cfg := aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		BaseEndpoint: aws.String(srv.URL),
+	}
+	got, err := callerAccountID(context.Background(), cfg, NewAPIPolicy(APIPolicyConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "111122223333" {
+		t.Errorf("callerAccountID() = %q, want 111122223333", got)
+	}
+}
+
+func TestCallerAccountIDError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<ErrorResponse><Error><Type>Sender</Type><Code>InvalidClientTokenId</Code><Message>The security token included in the request is invalid.</Message></Error></ErrorResponse>`))
+	}))
+	defer srv.Close()
+
+	cfg := aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		BaseEndpoint: aws.String(srv.URL),
+	}
+	_, err := callerAccountID(context.Background(), cfg, NewAPIPolicy(APIPolicyConfig{}))
+	if err == nil || !strings.Contains(err.Error(), "InvalidClientTokenId") {
+		t.Fatalf("callerAccountID() error = %v, want InvalidClientTokenId", err)
+	}
+}
+
+func TestAccountFromARN(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{"arn:aws:iam::444455556666:role/drift-detector", "444455556666"},
+		{"arn:aws-cn:iam::444455556666:role/path/to/role", "444455556666"},
+		{"not-an-arn", ""},
+	}
+	for _, tt := range tests {
+		if got := accountFromARN(tt.arn); got != tt.want {
+			t.Errorf("accountFromARN(%q) = %q, want %q", tt.arn, got, tt.want)
+		}
+	}
+}
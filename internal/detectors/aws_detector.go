@@ -3,38 +3,124 @@ package detectors
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/MeowTux/drift-detector/internal/drift"
 	"github.com/MeowTux/drift-detector/internal/terraform"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	log "github.com/sirupsen/logrus"
 )
 
+// AWSConfig configures the AWS detector
+type AWSConfig struct {
+	// Regions lists the regions to scan; the first is the default for
+	// resources whose region cannot be determined from state
+	Regions []string
+
+	// AliasRegions maps provider aliases to the region they are configured with
+	AliasRegions map[string]string
+
+	// Accounts are reached by assuming a role; resources not matching any
+	// account use the default credentials
+	Accounts []AWSAccount
+
+	// IgnoreTags are glob patterns of tag keys managed outside Terraform
+	IgnoreTags []string
+
+	// Workers bounds the concurrent checks per account and region
+	Workers int
+
+	// Policy sets retries and rate limits of API calls; defaults apply when nil
+	Policy *APIPolicy
+
+	// States are all states of the run. Policies granted to an IAM role,
+	// user or group are looked up in all of them, since the attachment may
+	// be managed in another state than the principal.
+	States []*terraform.State
+
+	// IncompleteStates is set when some states failed to load; policies
+	// granted outside the loaded states are then of unknown origin
+	IncompleteStates bool
+}
+
 // AWSDetector detects drift in AWS resources
 type AWSDetector struct {
-	regions   []string
-	ec2Client *ec2.Client
-	s3Client  *s3.Client
+	regions        []string
+	aliasRegions   map[string]string
+	defaultAccount *awsAccount
+	accounts       []*awsAccount
+	ignoreTags     tagFilter
+	pool           *workerPool
+
+	// grants indexes the related resources of all states of the run, or is
+	// nil when the detector only sees the state it checks
+	grants           relatedResources
+	incompleteStates bool
+}
+
+// awsCheckResult is the outcome of checking one resource
+type awsCheckResult struct {
+	item    *drift.DriftItem
+	scanErr *drift.ScanError
 }
 
-// NewAWSDetector creates a new AWS detector
-func NewAWSDetector(regions []string) (*AWSDetector, error) {
+// NewAWSDetector creates a new AWS detector. Each resource is checked in the
+// region recorded in its state, with the credentials of the account that
+// matches its provider alias or state.
+func NewAWSDetector(cfg AWSConfig) (*AWSDetector, error) {
+	regions := cfg.Regions
 	if len(regions) == 0 {
 		regions = []string{"us-east-1"}
 	}
 
-	cfg, err := config.LoadDefaultConfig(context.Background())
+	awsCfg, err := config.LoadDefaultConfig(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
+	if awsCfg.Region == "" {
+		awsCfg.Region = regions[0]
+	}
 
-	return &AWSDetector{
-		regions:   regions,
-		ec2Client: ec2.NewFromConfig(cfg),
-		s3Client:  s3.NewFromConfig(cfg),
-	}, nil
+	policy := cfg.Policy
+	if policy == nil {
+		policy = NewAPIPolicy(APIPolicyConfig{})
+	}
+
+	// Resolve the account of the default credentials once, so its drift
+	// items and scan errors carry the account ID like role-based accounts
+	defaultAccount := AWSAccount{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if accountID, err := callerAccountID(ctx, awsCfg, policy); err != nil {
+		log.Warnf("Failed to resolve the AWS account of the default credentials: %v", err)
+	} else {
+		defaultAccount.AccountID = accountID
+	}
+
+	d := &AWSDetector{
+		regions:        regions,
+		aliasRegions:   cfg.AliasRegions,
+		defaultAccount: newAWSAccount(awsCfg, defaultAccount, regions, policy),
+		ignoreTags:     newTagFilter(cfg.IgnoreTags),
+		pool:           newWorkerPool(cfg.Workers),
+
+		incompleteStates: cfg.IncompleteStates,
+	}
+	if len(cfg.States) > 0 {
+		var resources []terraform.ResourceInstance
+		for _, state := range cfg.States {
+			resources = append(resources, state.ManagedInstances()...)
+		}
+		d.grants = indexRelated(resources)
+	}
+	for _, account := range cfg.Accounts {
+		if account.RoleARN == "" {
+			return nil, fmt.Errorf("AWS account %q has no role_arn", account.Name)
+		}
+		d.accounts = append(d.accounts, newAWSAccount(awsCfg, account, regions, policy))
+	}
+
+	return d, nil
 }
 
 // Name returns the detector name
@@ -42,287 +128,328 @@ func (d *AWSDetector) Name() string {
 	return "AWS"
 }
 
-// Detect performs drift detection
+// Detect performs drift detection. Resources are checked concurrently
+// through a worker pool per account and region; the results keep the order
+// of the state.
 func (d *AWSDetector) Detect(ctx context.Context, state *terraform.State) ([]drift.DriftItem, error) {
-	var drifts []drift.DriftItem
-
-	log.Debugf("Detecting drift in AWS resources across %d regions", len(d.regions))
-
-	for _, resource := range state.Resources {
+	var resources []terraform.ResourceInstance
+	for _, resource := range state.ManagedInstances() {
 		// Only check AWS resources
-		if !isAWSResource(resource.Type) {
-			continue
+		if isAWSResource(resource.Type) {
+			resources = append(resources, resource)
 		}
+	}
 
-		switch resource.Type {
-		case "aws_instance":
-			drift, err := d.checkEC2Instance(ctx, resource)
-			if err != nil {
-				log.Warnf("Error checking EC2 instance %s: %v", resource.Name, err)
-				continue
-			}
-			if drift != nil {
-				drifts = append(drifts, *drift)
-			}
-
-		case "aws_s3_bucket":
-			drift, err := d.checkS3Bucket(ctx, resource)
-			if err != nil {
-				log.Warnf("Error checking S3 bucket %s: %v", resource.Name, err)
-				continue
-			}
-			if drift != nil {
-				drifts = append(drifts, *drift)
-			}
+	// Standalone resources such as security group rules are merged into the
+	// resource they belong to
+	related := indexRelated(resources)
 
-		case "aws_security_group":
-			drift, err := d.checkSecurityGroup(ctx, resource)
-			if err != nil {
-				log.Warnf("Error checking security group %s: %v", resource.Name, err)
-				continue
-			}
-			if drift != nil {
-				drifts = append(drifts, *drift)
-			}
+	// Split-out bucket configuration resources record no region of their
+	// own; they live where their bucket does
+	bucketRegions := make(map[string]string)
+	for _, resource := range resources {
+		if resource.Type == "aws_s3_bucket" {
+			bucketRegions[stringAttr(resource.Attributes, "bucket")] = resourceRegion(resource, d.aliasRegions, d.regions[0])
 		}
 	}
 
-	return drifts, nil
-}
+	log.Debugf("Detecting drift in %d AWS resources across %d regions and %d accounts",
+		len(resources), len(d.regions), len(d.accounts)+1)
 
-func (d *AWSDetector) checkEC2Instance(ctx context.Context, resource terraform.Resource) (*drift.DriftItem, error) {
-	instanceID, ok := resource.Attributes["id"].(string)
-	if !ok {
-		return nil, fmt.Errorf("instance ID not found")
+	// Group resources by account and region so each group's resources can
+	// be described in batches before they are compared
+	type resourceGroup struct {
+		key       string
+		account   *awsAccount
+		region    string
+		resources []terraform.ResourceInstance
 	}
-
-	// Describe the instance
-	result, err := d.ec2Client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
-		InstanceIds: []string{instanceID},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to describe instance: %w", err)
+	type groupKey struct {
+		account *awsAccount
+		region  string
 	}
+	var groups []*resourceGroup
+	groupOf := make([]*resourceGroup, len(resources))
+	byKey := make(map[groupKey]*resourceGroup)
+	for i, resource := range resources {
+		account := d.accountFor(state, resource)
+		region := resourceRegion(resource, d.aliasRegions, d.regions[0])
+		if _, ok := s3ConfigChecks[resource.Type]; ok {
+			if bucketRegion, ok := bucketRegions[stringAttr(resource.Attributes, "bucket")]; ok {
+				region = bucketRegion
+			}
+		}
 
-	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
-		return &drift.DriftItem{
-			ResourceType: resource.Type,
-			ResourceName: resource.Name,
-			Provider:     "AWS",
-			Severity:     "critical",
-			Changes: []drift.Change{{
-				Field:    "existence",
-				Expected: "exists",
-				Actual:   "deleted",
-			}},
-		}, nil
+		// A role may lead back into the account of the default credentials,
+		// so groups are told apart by account configuration, not account ID
+		group, ok := byKey[groupKey{account, region}]
+		if !ok {
+			group = &resourceGroup{key: account.AccountID + "/" + region, account: account, region: region}
+			byKey[groupKey{account, region}] = group
+			groups = append(groups, group)
+		}
+		group.resources = append(group.resources, resource)
+		groupOf[i] = group
 	}
 
-	instance := result.Reservations[0].Instances[0]
-	var changes []drift.Change
-
-	// Check instance type
-	expectedType, _ := resource.Attributes["instance_type"].(string)
-	if string(instance.InstanceType) != expectedType {
-		changes = append(changes, drift.Change{
-			Field:    "instance_type",
-			Expected: expectedType,
-			Actual:   string(instance.InstanceType),
+	indexes, err := runPool(ctx, d.pool, len(groups),
+		func(g int) string { return groups[g].key },
+		func(ctx context.Context, g int) *awsResourceIndex {
+			group := groups[g]
+			if group.account.assume(ctx) != nil {
+				return nil
+			}
+			return buildAWSIndex(ctx, group.account.clients.forRegion(group.region), group.resources)
 		})
+	if err != nil {
+		return nil, err
+	}
+	indexOf := make(map[*resourceGroup]*awsResourceIndex, len(groups))
+	for g, group := range groups {
+		indexOf[group] = indexes[g]
 	}
 
-	// Check tags
-	expectedTags, _ := resource.Attributes["tags"].(map[string]interface{})
-	actualTags := make(map[string]string)
-	for _, tag := range instance.Tags {
-		if tag.Key != nil && tag.Value != nil {
-			actualTags[*tag.Key] = *tag.Value
-		}
+	results, err := runPool(ctx, d.pool, len(resources),
+		func(i int) string { return groupOf[i].key },
+		func(ctx context.Context, i int) awsCheckResult {
+			group := groupOf[i]
+			return d.checkResource(ctx, group.account, group.region, indexOf[group], related, resources[i])
+		})
+	if err != nil {
+		return nil, err
 	}
 
-	for key, expectedVal := range expectedTags {
-		if actualVal, ok := actualTags[key]; !ok || actualVal != expectedVal {
-			changes = append(changes, drift.Change{
-				Field:    fmt.Sprintf("tags.%s", key),
-				Expected: expectedVal,
-				Actual:   actualTags[key],
-			})
+	var drifts []drift.DriftItem
+	var scanErrs ScanErrors
+	for _, result := range results {
+		if result.scanErr != nil {
+			scanErrs = append(scanErrs, *result.scanErr)
+		}
+		if result.item != nil {
+			drifts = append(drifts, *result.item)
 		}
 	}
 
-	if len(changes) > 0 {
-		return &drift.DriftItem{
-			ResourceType: resource.Type,
-			ResourceName: resource.Name,
-			ResourceID:   instanceID,
-			Provider:     "AWS",
-			Severity:     determineSeverity(changes),
-			Changes:      changes,
-		}, nil
+	if len(scanErrs) > 0 {
+		return drifts, scanErrs
 	}
-
-	return nil, nil
+	return drifts, nil
 }
 
-func (d *AWSDetector) checkS3Bucket(ctx context.Context, resource terraform.Resource) (*drift.DriftItem, error) {
-	bucketName, ok := resource.Attributes["bucket"].(string)
-	if !ok {
-		return nil, fmt.Errorf("bucket name not found")
+// checkResource checks a single resource in its account and region, using
+// the batch-fetched index where the resource type has one and the related
+// resources of the state
+func (d *AWSDetector) checkResource(ctx context.Context, account *awsAccount, region string, index *awsResourceIndex, related relatedResources, resource terraform.ResourceInstance) awsCheckResult {
+	if err := account.assume(ctx); err != nil {
+		return awsCheckResult{scanErr: &drift.ScanError{
+			Address:   resource.Address,
+			Provider:  "AWS",
+			AccountID: account.AccountID,
+			Class:     ClassifyAWSError(err),
+			Message:   err.Error(),
+		}}
 	}
 
-	var changes []drift.Change
+	clients := account.clients.forRegion(region)
 
-	// Check bucket versioning
-	versioning, err := d.s3Client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{
-		Bucket: &bucketName,
-	})
-	if err != nil {
-		// Bucket might have been deleted
-		return &drift.DriftItem{
-			ResourceType: resource.Type,
-			ResourceName: resource.Name,
-			Provider:     "AWS",
-			Severity:     "critical",
-			Changes: []drift.Change{{
-				Field:    "existence",
-				Expected: "exists",
-				Actual:   "deleted or inaccessible",
-			}},
-		}, nil
-	}
+	var item *drift.DriftItem
+	var err error
 
-	expectedVersioning, _ := resource.Attributes["versioning"].(map[string]interface{})
-	if expectedVersioning != nil {
-		enabled, _ := expectedVersioning["enabled"].(bool)
-		if enabled && versioning.Status != "Enabled" {
-			changes = append(changes, drift.Change{
-				Field:    "versioning",
-				Expected: "Enabled",
-				Actual:   string(versioning.Status),
-			})
-		}
-	}
+	switch resource.Type {
+	case "aws_instance":
+		item, err = d.checkEC2Instance(ctx, clients, index, resource)
 
-	// Check encryption
-	encryption, err := d.s3Client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{
-		Bucket: &bucketName,
-	})
-
-	expectedEncryption, _ := resource.Attributes["server_side_encryption_configuration"]
-	if expectedEncryption != nil && err != nil {
-		changes = append(changes, drift.Change{
-			Field:    "encryption",
-			Expected: "enabled",
-			Actual:   "disabled",
-		})
-	} else if expectedEncryption == nil && encryption != nil {
-		changes = append(changes, drift.Change{
-			Field:    "encryption",
-			Expected: "disabled",
-			Actual:   "enabled",
-		})
-	}
+	case "aws_s3_bucket":
+		item, err = d.checkS3Bucket(ctx, clients, related, resource)
 
-	if len(changes) > 0 {
-		return &drift.DriftItem{
-			ResourceType: resource.Type,
-			ResourceName: resource.Name,
-			ResourceID:   bucketName,
-			Provider:     "AWS",
-			Severity:     determineSeverity(changes),
-			Changes:      changes,
-		}, nil
-	}
+	case "aws_security_group":
+		item, err = d.checkSecurityGroup(index, related, resource)
 
-	return nil, nil
-}
+	case "aws_db_instance":
+		item, err = d.checkDBInstance(index, resource)
+
+	case "aws_rds_cluster":
+		item, err = d.checkRDSCluster(index, resource)
+
+	case "aws_lambda_function":
+		item, err = d.checkLambdaFunction(ctx, clients, resource)
+
+	case "aws_iam_role":
+		item, err = d.checkIAMRole(ctx, clients, related, resource)
+
+	case "aws_iam_user":
+		item, err = d.checkIAMUser(ctx, clients, related, resource)
+
+	case "aws_iam_group":
+		item, err = d.checkIAMGroup(ctx, clients, related, resource)
+
+	case "aws_iam_policy":
+		item, err = d.checkIAMPolicy(ctx, clients, resource)
+
+	case "aws_iam_role_policy", "aws_iam_user_policy", "aws_iam_group_policy":
+		item, err = d.checkIAMInlinePolicy(ctx, clients, resource)
+
+	case "aws_iam_role_policy_attachment", "aws_iam_user_policy_attachment", "aws_iam_group_policy_attachment":
+		item, err = d.checkIAMPolicyAttachment(ctx, clients, resource)
+
+	case "aws_vpc":
+		item, err = d.checkVPC(ctx, clients, index, resource)
+
+	case "aws_subnet":
+		item, err = d.checkSubnet(index, resource)
+
+	case "aws_route_table":
+		item, err = d.checkRouteTable(index, related, resource)
+
+	case "aws_route":
+		item, err = d.checkRoute(index, resource)
 
-func (d *AWSDetector) checkSecurityGroup(ctx context.Context, resource terraform.Resource) (*drift.DriftItem, error) {
-	sgID, ok := resource.Attributes["id"].(string)
-	if !ok {
-		return nil, fmt.Errorf("security group ID not found")
+	case "aws_network_acl":
+		item, err = d.checkNetworkACL(index, related, resource)
+
+	case "aws_internet_gateway":
+		item, err = d.checkInternetGateway(index, resource)
+
+	case "aws_nat_gateway":
+		item, err = d.checkNatGateway(index, resource)
+
+	case "aws_vpc_peering_connection":
+		item, err = d.checkPeeringConnection(index, resource)
+
+	default:
+		if _, ok := s3ConfigChecks[resource.Type]; ok {
+			item, err = d.checkS3BucketConfig(ctx, clients, resource)
+		}
 	}
 
-	result, err := d.ec2Client.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
-		GroupIds: []string{sgID},
-	})
 	if err != nil {
-		return &drift.DriftItem{
-			ResourceType: resource.Type,
-			ResourceName: resource.Name,
-			Provider:     "AWS",
-			Severity:     "critical",
-			Changes: []drift.Change{{
-				Field:    "existence",
-				Expected: "exists",
-				Actual:   "deleted",
-			}},
-		}, nil
+		// The API policy already retried transient errors, so the resource
+		// is reported as unverified rather than skipped
+		log.Warnf("Error checking %s in %s: %v", resource.Address, region, err)
+		return awsCheckResult{scanErr: &drift.ScanError{
+			Address:   resource.Address,
+			Provider:  "AWS",
+			AccountID: account.AccountID,
+			Class:     ClassifyAWSError(err),
+			Message:   err.Error(),
+		}}
 	}
-
-	if len(result.SecurityGroups) == 0 {
-		return &drift.DriftItem{
-			ResourceType: resource.Type,
-			ResourceName: resource.Name,
-			Provider:     "AWS",
-			Severity:     "critical",
-			Changes: []drift.Change{{
-				Field:    "existence",
-				Expected: "exists",
-				Actual:   "deleted",
-			}},
-		}, nil
+	if item != nil {
+		item.Region = region
+		item.AccountID = account.AccountID
 	}
+	return awsCheckResult{item: item}
+}
 
-	sg := result.SecurityGroups[0]
-	var changes []drift.Change
-
-	// Compare ingress rules
-	expectedIngress, _ := resource.Attributes["ingress"].([]interface{})
-	if len(sg.IpPermissions) != len(expectedIngress) {
-		changes = append(changes, drift.Change{
-			Field:    "ingress_rules_count",
-			Expected: len(expectedIngress),
-			Actual:   len(sg.IpPermissions),
-		})
+// existenceDrift reports a resource in state that no longer exists
+func existenceDrift(resource terraform.ResourceInstance) *drift.DriftItem {
+	return &drift.DriftItem{
+		ResourceType: resource.Type,
+		ResourceName: resource.Name,
+		Address:      resource.Address,
+		Provider:     "AWS",
+		Severity:     "critical",
+		Changes: []drift.Change{{
+			Field:    "existence",
+			Expected: "exists",
+			Actual:   "deleted",
+		}},
 	}
+}
 
-	// Compare egress rules
-	expectedEgress, _ := resource.Attributes["egress"].([]interface{})
-	if len(sg.IpPermissionsEgress) != len(expectedEgress) {
-		changes = append(changes, drift.Change{
-			Field:    "egress_rules_count",
-			Expected: len(expectedEgress),
-			Actual:   len(sg.IpPermissionsEgress),
-		})
+// changesDrift reports the changes found in a resource, or nil without any
+func changesDrift(resource terraform.ResourceInstance, id string, changes []drift.Change) *drift.DriftItem {
+	if len(changes) == 0 {
+		return nil
 	}
-
-	if len(changes) > 0 {
-		return &drift.DriftItem{
-			ResourceType: resource.Type,
-			ResourceName: resource.Name,
-			ResourceID:   sgID,
-			Provider:     "AWS",
-			Severity:     "high",
-			Changes:      changes,
-		}, nil
+	return &drift.DriftItem{
+		ResourceType: resource.Type,
+		ResourceName: resource.Name,
+		Address:      resource.Address,
+		ResourceID:   id,
+		Provider:     "AWS",
+		Severity:     determineSeverity(changes),
+		Changes:      changes,
 	}
+}
 
-	return nil, nil
+// grantScope returns the related resources IAM grants are matched against:
+// those of all states of the run when known, else those of the checked state
+func (d *AWSDetector) grantScope(related relatedResources) relatedResources {
+	if d.grants != nil {
+		return d.grants
+	}
+	return related
 }
 
 func isAWSResource(resourceType string) bool {
 	return len(resourceType) > 4 && resourceType[:4] == "aws_"
 }
 
+// criticalSeverityFields are fields whose drift means code or infrastructure
+// runs in a state nobody reviewed
+var criticalSeverityFields = map[string]bool{
+	"existence":        true,
+	"code_sha256":      true,
+	"source_code_hash": true,
+}
+
+// highSeverityFields are fields whose drift weakens security or exposure
+var highSeverityFields = map[string]bool{
+	"encryption":                   true,
+	"public_access":                true,
+	"vpc_security_group_ids":       true,
+	"iam_instance_profile":         true,
+	"metadata_options.http_tokens": true,
+	"root_block_device.encrypted":  true,
+
+	"rule.apply_server_side_encryption_by_default.sse_algorithm": true,
+	"block_public_acls":           true,
+	"block_public_policy":         true,
+	"ignore_public_acls":          true,
+	"restrict_public_buckets":     true,
+	"policy":                      true,
+	"access_control_policy.grant": true,
+
+	"publicly_accessible": true,
+	"storage_encrypted":   true,
+	"deletion_protection": true,
+
+	"role":                          true,
+	"vpc_config.security_group_ids": true,
+
+	"map_public_ip_on_launch": true,
+}
+
+// severityRank orders severities from least to most severe
+var severityRank = map[string]int{"low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// valueSeverities rate changes whose severity depends on the values involved
+// rather than the field alone; they return "" for changes they do not rate
+var valueSeverities = []func(drift.Change) string{
+	sgRuleSeverity,
+	policyStatementSeverity,
+	iamGrantSeverity,
+	routeSeverity,
+}
+
 func determineSeverity(changes []drift.Change) string {
+	severity := "medium"
+	raise := func(s string) {
+		if severityRank[s] > severityRank[severity] {
+			severity = s
+		}
+	}
 	for _, change := range changes {
-		if change.Field == "existence" {
+		if criticalSeverityFields[change.Field] {
 			return "critical"
 		}
-		if change.Field == "encryption" || change.Field == "public_access" {
-			return "high"
+		if highSeverityFields[change.Field] {
+			raise("high")
+		}
+		for _, rate := range valueSeverities {
+			raise(rate(change))
 		}
 	}
-	return "medium"
+	return severity
 }
@@ -0,0 +1,118 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// rootVolumeID returns the ID of the EBS volume attached as the root device
+func rootVolumeID(instance types.Instance) string {
+	for _, mapping := range instance.BlockDeviceMappings {
+		if aws.ToString(mapping.DeviceName) == aws.ToString(instance.RootDeviceName) && mapping.Ebs != nil {
+			return aws.ToString(mapping.Ebs.VolumeId)
+		}
+	}
+	return ""
+}
+
+// instanceProfileName returns the name of an instance profile from its ARN,
+// which is what the aws_instance resource records
+func instanceProfileName(profile *types.IamInstanceProfile) string {
+	if profile == nil {
+		return ""
+	}
+	arn := aws.ToString(profile.Arn)
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+func (d *AWSDetector) checkEC2Instance(ctx context.Context, clients *awsClients, index *awsResourceIndex, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	instanceID, ok := resource.Attributes["id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("instance ID not found")
+	}
+	if index.instancesErr != nil {
+		return nil, index.instancesErr
+	}
+
+	instance, found := index.instances[instanceID]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	attrs := resource.Attributes
+	var changes changeSet
+
+	changes.compareString("instance_type", attrs["instance_type"], string(instance.InstanceType))
+	changes.compareString("subnet_id", attrs["subnet_id"], aws.ToString(instance.SubnetId))
+	changes.compareString("iam_instance_profile", attrs["iam_instance_profile"], instanceProfileName(instance.IamInstanceProfile))
+	changes.compareBool("ebs_optimized", attrs["ebs_optimized"], aws.ToBool(instance.EbsOptimized))
+	changes.compareBool("source_dest_check", attrs["source_dest_check"], aws.ToBool(instance.SourceDestCheck))
+
+	var groupIDs []string
+	for _, group := range instance.SecurityGroups {
+		groupIDs = append(groupIDs, aws.ToString(group.GroupId))
+	}
+	changes.compareStrings("vpc_security_group_ids", attrs["vpc_security_group_ids"], groupIDs)
+
+	monitoring := instance.Monitoring != nil &&
+		(instance.Monitoring.State == types.MonitoringStateEnabled || instance.Monitoring.State == types.MonitoringStatePending)
+	changes.compareBool("monitoring", attrs["monitoring"], monitoring)
+
+	// Only a settled state is compared; pending or stopping instances are on
+	// their way somewhere
+	expectedState := stringAttr(attrs, "instance_state")
+	if instance.State != nil && (expectedState == "running" || expectedState == "stopped") {
+		actualState := string(instance.State.Name)
+		if (actualState == "running" || actualState == "stopped") && actualState != expectedState {
+			changes.add("instance_state", expectedState, actualState)
+		}
+	}
+
+	if metadata := firstBlock(attrs, "metadata_options"); metadata != nil && instance.MetadataOptions != nil {
+		options := instance.MetadataOptions
+		changes.compareString("metadata_options.http_tokens", metadata["http_tokens"], string(options.HttpTokens))
+		changes.compareString("metadata_options.http_endpoint", metadata["http_endpoint"], string(options.HttpEndpoint))
+		changes.compareInt("metadata_options.http_put_response_hop_limit", metadata["http_put_response_hop_limit"], int(aws.ToInt32(options.HttpPutResponseHopLimit)))
+	}
+
+	if root := firstBlock(attrs, "root_block_device"); root != nil {
+		if volumeID := rootVolumeID(instance); volumeID != "" {
+			if index.volumesErr != nil {
+				return nil, index.volumesErr
+			}
+			if volume, ok := index.volumes[volumeID]; ok {
+				changes.compareInt("root_block_device.volume_size", root["volume_size"], int(aws.ToInt32(volume.Size)))
+				changes.compareString("root_block_device.volume_type", root["volume_type"], string(volume.VolumeType))
+				changes.compareBool("root_block_device.encrypted", root["encrypted"], aws.ToBool(volume.Encrypted))
+			}
+		}
+	}
+
+	// Termination protection is not part of DescribeInstances
+	if _, ok := attrs["disable_api_termination"].(bool); ok {
+		out, err := clients.ec2.DescribeInstanceAttribute(ctx, &ec2.DescribeInstanceAttributeInput{
+			InstanceId: aws.String(instanceID),
+			Attribute:  types.InstanceAttributeNameDisableApiTermination,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe termination protection: %w", err)
+		}
+		var protected bool
+		if out.DisableApiTermination != nil {
+			protected = aws.ToBool(out.DisableApiTermination.Value)
+		}
+		changes.compareBool("disable_api_termination", attrs["disable_api_termination"], protected)
+	}
+
+	// Check tags, including tags added outside Terraform
+	changes = append(changes, d.tagChanges(attrs, ec2Tags(instance.Tags))...)
+
+	return changesDrift(resource, instanceID, changes), nil
+}
@@ -0,0 +1,160 @@
+package detectors
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestChangeSetCompare(t *testing.T) {
+	var changes changeSet
+	changes.compareString("instance_type", "t3.micro", "t3.large")
+	changes.compareString("subnet_id", "subnet-1", "subnet-1")
+	changes.compareString("key_name", nil, "deployer")
+	changes.compareBool("ebs_optimized", false, true)
+	changes.compareBool("monitoring", nil, true)
+	changes.compareInt("volume_size", float64(8), 8)
+	changes.compareInt("hop_limit", float64(1), 2)
+	changes.compareStrings("vpc_security_group_ids", []interface{}{"sg-2", "sg-1"}, []string{"sg-1", "sg-2"})
+	changes.compareStrings("security_groups", []interface{}{"sg-1"}, []string{"sg-1", "sg-3"})
+	changes.compareStrings("ipv6_addresses", nil, []string{"::1"})
+
+	want := []drift.Change{
+		{Field: "instance_type", Expected: "t3.micro", Actual: "t3.large"},
+		{Field: "ebs_optimized", Expected: false, Actual: true},
+		{Field: "hop_limit", Expected: 1, Actual: 2},
+		{Field: "security_groups", Expected: []string{"sg-1"}, Actual: []string{"sg-1", "sg-3"}},
+	}
+	if !reflect.DeepEqual([]drift.Change(changes), want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+}
+
+func TestDiffTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected map[string]string
+		actual   map[string]string
+		want     []drift.Change
+	}{
+		{"equal", map[string]string{"Name": "web"}, map[string]string{"Name": "web"}, nil},
+		{
+			"changed, removed and added",
+			map[string]string{"Name": "web", "Team": "core"},
+			map[string]string{"Name": "api", "Owner": "ops"},
+			[]drift.Change{
+				{Field: "tags.Name", Expected: "web", Actual: "api"},
+				{Field: "tags.Owner", Expected: "absent", Actual: "ops"},
+				{Field: "tags.Team", Expected: "core", Actual: "absent"},
+			},
+		},
+		{"empty value is not absent", map[string]string{"Env": ""}, map[string]string{"Env": ""}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffTags(tt.expected, tt.actual); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffTags() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstanceProfileName(t *testing.T) {
+	tests := []struct {
+		profile *types.IamInstanceProfile
+		want    string
+	}{
+		{nil, ""},
+		{&types.IamInstanceProfile{Arn: aws.String("arn:aws:iam::123456789012:instance-profile/web")}, "web"},
+		{&types.IamInstanceProfile{Arn: aws.String("arn:aws:iam::123456789012:instance-profile/team/web")}, "web"},
+	}
+
+	for _, tt := range tests {
+		if got := instanceProfileName(tt.profile); got != tt.want {
+			t.Errorf("instanceProfileName() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestCheckEC2Instance(t *testing.T) {
+	instance := types.Instance{
+		InstanceId:     aws.String("i-1"),
+		InstanceType:   types.InstanceTypeT3Large,
+		SubnetId:       aws.String("subnet-1"),
+		EbsOptimized:   aws.Bool(true),
+		RootDeviceName: aws.String("/dev/xvda"),
+		BlockDeviceMappings: []types.InstanceBlockDeviceMapping{
+			{DeviceName: aws.String("/dev/xvda"), Ebs: &types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-1")}},
+		},
+		SecurityGroups:  []types.GroupIdentifier{{GroupId: aws.String("sg-2")}, {GroupId: aws.String("sg-1")}},
+		Monitoring:      &types.Monitoring{State: types.MonitoringStatePending},
+		State:           &types.InstanceState{Name: types.InstanceStateNameStopping},
+		MetadataOptions: &types.InstanceMetadataOptionsResponse{HttpTokens: types.HttpTokensStateOptional, HttpEndpoint: types.InstanceMetadataEndpointStateEnabled, HttpPutResponseHopLimit: aws.Int32(1)},
+		Tags:            []types.Tag{{Key: aws.String("Name"), Value: aws.String("web")}, {Key: aws.String("Owner"), Value: aws.String("ops")}},
+	}
+	index := &awsResourceIndex{
+		instances: map[string]types.Instance{"i-1": instance},
+		volumes: map[string]types.Volume{
+			"vol-1": {VolumeId: aws.String("vol-1"), Size: aws.Int32(20), VolumeType: types.VolumeTypeGp3, Encrypted: aws.Bool(false)},
+		},
+	}
+
+	resource := terraform.ResourceInstance{
+		Address: "aws_instance.web",
+		Type:    "aws_instance",
+		Name:    "web",
+		Attributes: map[string]interface{}{
+			"id":                     "i-1",
+			"instance_type":          "t3.micro",
+			"subnet_id":              "subnet-1",
+			"ebs_optimized":          true,
+			"vpc_security_group_ids": []interface{}{"sg-1", "sg-2"},
+			"monitoring":             true,
+			// The instance is stopping, which is not compared yet
+			"instance_state": "running",
+			"metadata_options": []interface{}{map[string]interface{}{
+				"http_tokens":                 "required",
+				"http_endpoint":               "enabled",
+				"http_put_response_hop_limit": float64(1),
+			}},
+			"root_block_device": []interface{}{map[string]interface{}{
+				"volume_size": float64(20),
+				"volume_type": "gp3",
+				"encrypted":   true,
+			}},
+			"tags": map[string]interface{}{"Name": "web"},
+		},
+	}
+
+	item, err := (&AWSDetector{}).checkEC2Instance(context.Background(), nil, index, resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item == nil {
+		t.Fatal("checkEC2Instance() found no drift")
+	}
+
+	var fields []string
+	for _, change := range item.Changes {
+		fields = append(fields, change.Field)
+	}
+	want := []string{"instance_type", "metadata_options.http_tokens", "root_block_device.encrypted", "tags.Owner"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("changed fields = %q, want %q", fields, want)
+	}
+	if item.Severity != "high" {
+		t.Errorf("severity = %s, want high", item.Severity)
+	}
+
+	resource.Attributes = map[string]interface{}{"id": "i-gone"}
+	item, err = (&AWSDetector{}).checkEC2Instance(context.Background(), nil, index, resource)
+	if err != nil || item == nil || item.Changes[0].Field != "existence" {
+		t.Errorf("checkEC2Instance() of a missing instance = %+v, %v, want existence drift", item, err)
+	}
+}
@@ -0,0 +1,119 @@
+package detectors
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// awsNotFoundCodes are the error codes that prove a resource does not exist
+var awsNotFoundCodes = map[string]bool{
+	"NoSuchBucket":                 true,
+	"InvalidGroup.NotFound":        true,
+	"InvalidInstanceID.NotFound":   true,
+	"InvalidVpcID.NotFound":        true,
+	"InvalidSubnetID.NotFound":     true,
+	"InvalidRouteTableID.NotFound": true,
+	"NoSuchEntity":                 true,
+	"ResourceNotFoundException":    true,
+	"DBInstanceNotFound":           true,
+	"DBInstanceNotFoundFault":      true,
+	"DBClusterNotFoundFault":       true,
+}
+
+// awsAmbiguousNotFoundCodes suggest a missing resource without proving it,
+// e.g. a bucket answering from another region or a HEAD request whose 404
+// carries no error code
+var awsAmbiguousNotFoundCodes = map[string]bool{
+	"NotFound":                     true,
+	"PermanentRedirect":            true,
+	"AuthorizationHeaderMalformed": true,
+}
+
+// awsThrottlingCodes are the error codes AWS services use for throttling
+var awsThrottlingCodes = map[string]bool{
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"ThrottledException":                     true,
+	"RequestThrottled":                       true,
+	"RequestThrottledException":              true,
+	"RequestLimitExceeded":                   true,
+	"TooManyRequestsException":               true,
+	"ProvisionedThroughputExceededException": true,
+	"SlowDown":                               true,
+	"BandwidthLimitExceeded":                 true,
+	"PriorRequestNotComplete":                true,
+	"EC2ThrottledException":                  true,
+}
+
+// awsAccessDeniedCodes are the error codes for missing permissions or
+// invalid credentials
+var awsAccessDeniedCodes = map[string]bool{
+	"AccessDenied":                true,
+	"AccessDeniedException":       true,
+	"UnauthorizedOperation":       true,
+	"AuthFailure":                 true,
+	"InvalidClientTokenId":        true,
+	"ExpiredToken":                true,
+	"ExpiredTokenException":       true,
+	"UnrecognizedClientException": true,
+	"AllAccessDisabled":           true,
+	"Forbidden":                   true,
+}
+
+// isAWSNotFound reports whether an error proves the resource does not exist.
+// Only these errors may turn into an existence drift; anything else leaves
+// the resource unverified.
+func isAWSNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && awsNotFoundCodes[apiErr.ErrorCode()]
+}
+
+// awsErrorCode returns the AWS error code of err, if any
+func awsErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+// ClassifyAWSError maps an AWS SDK error to a scan error class. Network
+// errors are classified the same way for any client.
+func ClassifyAWSError(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.ErrorCode(); {
+		case awsThrottlingCodes[code]:
+			return drift.ErrorClassThrottled
+		case awsAccessDeniedCodes[code]:
+			return drift.ErrorClassAccessDenied
+		case awsNotFoundCodes[code], awsAmbiguousNotFoundCodes[code]:
+			return drift.ErrorClassNotFoundAmbiguous
+		}
+	}
+
+	var respErr *smithyhttp.ResponseError
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return drift.ErrorClassThrottled
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return drift.ErrorClassAccessDenied
+		case http.StatusNotFound, http.StatusMovedPermanently:
+			return drift.ErrorClassNotFoundAmbiguous
+		}
+	}
+
+	var sendErr *smithyhttp.RequestSendError
+	var netErr net.Error
+	if errors.As(err, &sendErr) || errors.As(err, &netErr) {
+		return drift.ErrorClassNetwork
+	}
+
+	return drift.ErrorClassUnknown
+}
@@ -0,0 +1,80 @@
+package detectors
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+func apiError(code string) error {
+	return &smithy.GenericAPIError{Code: code, Message: "test"}
+}
+
+func responseError(status int, err error) error {
+	return &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+		Err:      err,
+	}
+}
+
+func TestClassifyAWSError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"throttling", apiError("Throttling"), drift.ErrorClassThrottled},
+		{"EC2 request limit", apiError("RequestLimitExceeded"), drift.ErrorClassThrottled},
+		{"S3 slow down", apiError("SlowDown"), drift.ErrorClassThrottled},
+		{"access denied", apiError("AccessDenied"), drift.ErrorClassAccessDenied},
+		{"EC2 unauthorized", apiError("UnauthorizedOperation"), drift.ErrorClassAccessDenied},
+		{"expired token", apiError("ExpiredToken"), drift.ErrorClassAccessDenied},
+		{"proven not found", apiError("NoSuchBucket"), drift.ErrorClassNotFoundAmbiguous},
+		{"redirect", apiError("PermanentRedirect"), drift.ErrorClassNotFoundAmbiguous},
+		{"wrapped code", fmt.Errorf("describe: %w", apiError("AccessDeniedException")), drift.ErrorClassAccessDenied},
+		{"code wins over status", responseError(http.StatusBadRequest, apiError("ThrottlingException")), drift.ErrorClassThrottled},
+		{"unknown code", apiError("ValidationError"), drift.ErrorClassUnknown},
+		{"status 429", responseError(http.StatusTooManyRequests, errors.New("no code")), drift.ErrorClassThrottled},
+		{"status 503", responseError(http.StatusServiceUnavailable, errors.New("no code")), drift.ErrorClassThrottled},
+		{"status 403", responseError(http.StatusForbidden, errors.New("no code")), drift.ErrorClassAccessDenied},
+		{"HEAD 404", responseError(http.StatusNotFound, errors.New("no code")), drift.ErrorClassNotFoundAmbiguous},
+		{"status 301", responseError(http.StatusMovedPermanently, errors.New("no code")), drift.ErrorClassNotFoundAmbiguous},
+		{"status 500", responseError(http.StatusInternalServerError, errors.New("no code")), drift.ErrorClassUnknown},
+		{"send error", &smithyhttp.RequestSendError{Err: errors.New("connection reset")}, drift.ErrorClassNetwork},
+		{"dial error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, drift.ErrorClassNetwork},
+		{"plain error", errors.New("boom"), drift.ErrorClassUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyAWSError(tt.err); got != tt.want {
+				t.Errorf("ClassifyAWSError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsAWSNotFound(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{apiError("InvalidInstanceID.NotFound"), true},
+		{fmt.Errorf("wrapped: %w", apiError("DBInstanceNotFound")), true},
+		// A bare 404 or a redirect does not prove the resource is gone
+		{apiError("NotFound"), false},
+		{apiError("PermanentRedirect"), false},
+		{apiError("AccessDenied"), false},
+		{errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		if got := isAWSNotFound(tt.err); got != tt.want {
+			t.Errorf("isAWSNotFound(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// iamPrincipalKinds maps the IAM resource types that belong to a role, user
+// or group to the principal kind and the attribute naming the principal
+var iamPrincipalKinds = map[string]struct{ kind, attr string }{
+	"aws_iam_role_policy":             {"role", "role"},
+	"aws_iam_role_policy_attachment":  {"role", "role"},
+	"aws_iam_user_policy":             {"user", "user"},
+	"aws_iam_user_policy_attachment":  {"user", "user"},
+	"aws_iam_group_policy":            {"group", "group"},
+	"aws_iam_group_policy_attachment": {"group", "group"},
+}
+
+// iamTags converts IAM tags to a map
+func iamTags(tags []types.Tag) map[string]string {
+	result := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		if tag.Key != nil {
+			result[*tag.Key] = aws.ToString(tag.Value)
+		}
+	}
+	return result
+}
+
+// iamName returns the name of an IAM role, user or group, which is also its
+// id in state
+func iamName(attrs map[string]interface{}) string {
+	if name := stringAttr(attrs, "name"); name != "" {
+		return name
+	}
+	return stringAttr(attrs, "id")
+}
+
+// iamAttachedPolicies lists the ARNs of the managed policies attached to a
+// role, user or group
+func iamAttachedPolicies(ctx context.Context, client *iam.Client, kind, name string) ([]string, error) {
+	var arns []string
+	collect := func(policies []types.AttachedPolicy) {
+		for _, policy := range policies {
+			arns = append(arns, aws.ToString(policy.PolicyArn))
+		}
+	}
+
+	switch kind {
+	case "role":
+		paginator := iam.NewListAttachedRolePoliciesPaginator(client, &iam.ListAttachedRolePoliciesInput{RoleName: &name})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list attached role policies: %w", err)
+			}
+			collect(page.AttachedPolicies)
+		}
+	case "user":
+		paginator := iam.NewListAttachedUserPoliciesPaginator(client, &iam.ListAttachedUserPoliciesInput{UserName: &name})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list attached user policies: %w", err)
+			}
+			collect(page.AttachedPolicies)
+		}
+	case "group":
+		paginator := iam.NewListAttachedGroupPoliciesPaginator(client, &iam.ListAttachedGroupPoliciesInput{GroupName: &name})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list attached group policies: %w", err)
+			}
+			collect(page.AttachedPolicies)
+		}
+	}
+
+	return arns, nil
+}
+
+// iamInlinePolicyNames lists the names of the inline policies of a role,
+// user or group
+func iamInlinePolicyNames(ctx context.Context, client *iam.Client, kind, name string) ([]string, error) {
+	var names []string
+
+	switch kind {
+	case "role":
+		paginator := iam.NewListRolePoliciesPaginator(client, &iam.ListRolePoliciesInput{RoleName: &name})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list role policies: %w", err)
+			}
+			names = append(names, page.PolicyNames...)
+		}
+	case "user":
+		paginator := iam.NewListUserPoliciesPaginator(client, &iam.ListUserPoliciesInput{UserName: &name})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list user policies: %w", err)
+			}
+			names = append(names, page.PolicyNames...)
+		}
+	case "group":
+		paginator := iam.NewListGroupPoliciesPaginator(client, &iam.ListGroupPoliciesInput{GroupName: &name})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list group policies: %w", err)
+			}
+			names = append(names, page.PolicyNames...)
+		}
+	}
+
+	return names, nil
+}
+
+// iamInlinePolicyDocument returns the document of an inline policy
+func iamInlinePolicyDocument(ctx context.Context, client *iam.Client, kind, name, policyName string) (string, error) {
+	var document *string
+
+	switch kind {
+	case "role":
+		out, err := client.GetRolePolicy(ctx, &iam.GetRolePolicyInput{RoleName: &name, PolicyName: &policyName})
+		if err != nil {
+			return "", fmt.Errorf("failed to get role policy: %w", err)
+		}
+		document = out.PolicyDocument
+	case "user":
+		out, err := client.GetUserPolicy(ctx, &iam.GetUserPolicyInput{UserName: &name, PolicyName: &policyName})
+		if err != nil {
+			return "", fmt.Errorf("failed to get user policy: %w", err)
+		}
+		document = out.PolicyDocument
+	case "group":
+		out, err := client.GetGroupPolicy(ctx, &iam.GetGroupPolicyInput{GroupName: &name, PolicyName: &policyName})
+		if err != nil {
+			return "", fmt.Errorf("failed to get group policy: %w", err)
+		}
+		document = out.PolicyDocument
+	}
+
+	return aws.ToString(document), nil
+}
+
+// iamGrantChanges reports managed policies attached to and inline policies
+// added to a role, user or group outside Terraform. The expected policies
+// are those of the principal's own attributes and of the attachment and
+// inline policy resources in the scanned states. Missing policies managed by
+// their own resources are reported by those resources. When some states
+// could not be loaded, an unexpected policy may be managed in one of them,
+// so its origin is unknown.
+func iamGrantChanges(ctx context.Context, client *iam.Client, kind, name string, attrs map[string]interface{}, related relatedResources, incompleteStates bool) ([]drift.Change, error) {
+	attachmentType := "aws_iam_" + kind + "_policy_attachment"
+	policyType := "aws_iam_" + kind + "_policy"
+
+	// Attachments managed exclusively through managed_policy_arns must all
+	// be present; those of attachment resources are checked on their own
+	exclusive := make(map[string]bool)
+	for _, arn := range stringListAttr(attrs, "managed_policy_arns") {
+		exclusive[arn] = true
+	}
+	expectedArns := make(map[string]bool)
+	for arn := range exclusive {
+		expectedArns[arn] = true
+	}
+	for _, attachment := range related.of(attachmentType, name) {
+		expectedArns[stringAttr(attachment.Attributes, "policy_arn")] = true
+	}
+	for _, attachment := range related.of("aws_iam_policy_attachment", kind+"s/"+name) {
+		expectedArns[stringAttr(attachment.Attributes, "policy_arn")] = true
+	}
+
+	expectedNames := make(map[string]bool)
+	for _, policy := range blockAttr(attrs, "inline_policy") {
+		if policyName := stringAttr(policy, "name"); policyName != "" {
+			expectedNames[policyName] = true
+		}
+	}
+	for _, policy := range related.of(policyType, name) {
+		expectedNames[stringAttr(policy.Attributes, "name")] = true
+	}
+
+	attached, err := iamAttachedPolicies(ctx, client, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	inline, err := iamInlinePolicyNames(ctx, client, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(attached)
+	sort.Strings(inline)
+
+	var origin string
+	if incompleteStates {
+		origin = drift.OriginUnknown
+	}
+
+	var changes []drift.Change
+	actualArns := make(map[string]bool, len(attached))
+	for _, arn := range attached {
+		actualArns[arn] = true
+		if !expectedArns[arn] {
+			changes = append(changes, drift.Change{Field: fmt.Sprintf("policy_attachment[%s]", arn), Expected: "absent", Actual: "present", Origin: origin})
+		}
+	}
+	missing := make([]string, 0, len(exclusive))
+	for arn := range exclusive {
+		if !actualArns[arn] {
+			missing = append(missing, arn)
+		}
+	}
+	sort.Strings(missing)
+	for _, arn := range missing {
+		changes = append(changes, drift.Change{Field: fmt.Sprintf("policy_attachment[%s]", arn), Expected: "present", Actual: "absent"})
+	}
+
+	for _, policyName := range inline {
+		if !expectedNames[policyName] {
+			changes = append(changes, drift.Change{Field: fmt.Sprintf("inline_policy[%s]", policyName), Expected: "absent", Actual: "present", Origin: origin})
+		}
+	}
+
+	return changes, nil
+}
+
+// iamGrantSeverity rates a policy attached or added outside Terraform as
+// high, since it grants new permissions. A policy that may be managed in a
+// state that failed to load is not rated.
+func iamGrantSeverity(change drift.Change) string {
+	if (strings.HasPrefix(change.Field, "policy_attachment[") || strings.HasPrefix(change.Field, "inline_policy[")) &&
+		change.Actual == "present" && change.Origin != drift.OriginUnknown {
+		return "high"
+	}
+	return ""
+}
+
+func (d *AWSDetector) checkIAMRole(ctx context.Context, clients *awsClients, related relatedResources, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	name := iamName(attrs)
+	if name == "" {
+		return nil, fmt.Errorf("role name not found")
+	}
+
+	out, err := clients.iam.GetRole(ctx, &iam.GetRoleInput{RoleName: &name})
+	if err != nil {
+		if isAWSNotFound(err) {
+			return existenceDrift(resource), nil
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+	role := out.Role
+
+	var changes changeSet
+	changes = append(changes, diffPolicy("assume_role_policy", stringAttr(attrs, "assume_role_policy"), aws.ToString(role.AssumeRolePolicyDocument))...)
+	changes.compareInt("max_session_duration", attrs["max_session_duration"], int(aws.ToInt32(role.MaxSessionDuration)))
+	changes.compareString("description", attrs["description"], aws.ToString(role.Description))
+
+	var boundary string
+	if role.PermissionsBoundary != nil {
+		boundary = aws.ToString(role.PermissionsBoundary.PermissionsBoundaryArn)
+	}
+	changes.compareString("permissions_boundary", attrs["permissions_boundary"], boundary)
+
+	grants, err := iamGrantChanges(ctx, clients.iam, "role", name, attrs, d.grantScope(related), d.incompleteStates)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, grants...)
+	changes = append(changes, d.tagChanges(attrs, iamTags(role.Tags))...)
+
+	return changesDrift(resource, name, changes), nil
+}
+
+func (d *AWSDetector) checkIAMUser(ctx context.Context, clients *awsClients, related relatedResources, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	name := iamName(attrs)
+	if name == "" {
+		return nil, fmt.Errorf("user name not found")
+	}
+
+	out, err := clients.iam.GetUser(ctx, &iam.GetUserInput{UserName: &name})
+	if err != nil {
+		if isAWSNotFound(err) {
+			return existenceDrift(resource), nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	user := out.User
+
+	var changes changeSet
+	changes.compareString("path", attrs["path"], aws.ToString(user.Path))
+
+	var boundary string
+	if user.PermissionsBoundary != nil {
+		boundary = aws.ToString(user.PermissionsBoundary.PermissionsBoundaryArn)
+	}
+	changes.compareString("permissions_boundary", attrs["permissions_boundary"], boundary)
+
+	grants, err := iamGrantChanges(ctx, clients.iam, "user", name, attrs, d.grantScope(related), d.incompleteStates)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, grants...)
+	changes = append(changes, d.tagChanges(attrs, iamTags(user.Tags))...)
+
+	return changesDrift(resource, name, changes), nil
+}
+
+func (d *AWSDetector) checkIAMGroup(ctx context.Context, clients *awsClients, related relatedResources, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	name := iamName(attrs)
+	if name == "" {
+		return nil, fmt.Errorf("group name not found")
+	}
+
+	out, err := clients.iam.GetGroup(ctx, &iam.GetGroupInput{GroupName: &name, MaxItems: aws.Int32(1)})
+	if err != nil {
+		if isAWSNotFound(err) {
+			return existenceDrift(resource), nil
+		}
+		return nil, fmt.Errorf("failed to get group: %w", err)
+	}
+
+	var changes changeSet
+	changes.compareString("path", attrs["path"], aws.ToString(out.Group.Path))
+
+	grants, err := iamGrantChanges(ctx, clients.iam, "group", name, attrs, d.grantScope(related), d.incompleteStates)
+	if err != nil {
+		return nil, err
+	}
+	changes = append(changes, grants...)
+
+	return changesDrift(resource, name, changes), nil
+}
+
+// checkIAMPolicy compares a managed policy's default version with state
+func (d *AWSDetector) checkIAMPolicy(ctx context.Context, clients *awsClients, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	arn := stringAttr(attrs, "arn")
+	if arn == "" {
+		arn = stringAttr(attrs, "id")
+	}
+	if arn == "" {
+		return nil, fmt.Errorf("policy ARN not found")
+	}
+
+	out, err := clients.iam.GetPolicy(ctx, &iam.GetPolicyInput{PolicyArn: &arn})
+	if err != nil {
+		if isAWSNotFound(err) {
+			return existenceDrift(resource), nil
+		}
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
+
+	version, err := clients.iam.GetPolicyVersion(ctx, &iam.GetPolicyVersionInput{
+		PolicyArn: &arn,
+		VersionId: out.Policy.DefaultVersionId,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy version: %w", err)
+	}
+
+	var changes changeSet
+	changes = append(changes, diffPolicy("policy", stringAttr(attrs, "policy"), aws.ToString(version.PolicyVersion.Document))...)
+	changes = append(changes, d.tagChanges(attrs, iamTags(out.Policy.Tags))...)
+
+	return changesDrift(resource, arn, changes), nil
+}
+
+// checkIAMInlinePolicy compares an inline policy of a role, user or group
+func (d *AWSDetector) checkIAMInlinePolicy(ctx context.Context, clients *awsClients, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	principal := iamPrincipalKinds[resource.Type]
+	name := stringAttr(attrs, principal.attr)
+	policyName := stringAttr(attrs, "name")
+	if name == "" || policyName == "" {
+		return nil, fmt.Errorf("%s or policy name not found", principal.kind)
+	}
+
+	document, err := iamInlinePolicyDocument(ctx, clients.iam, principal.kind, name, policyName)
+	if err != nil {
+		if isAWSNotFound(err) {
+			return existenceDrift(resource), nil
+		}
+		return nil, err
+	}
+
+	changes := diffPolicy("policy", stringAttr(attrs, "policy"), document)
+	return changesDrift(resource, name+":"+policyName, changes), nil
+}
+
+// checkIAMPolicyAttachment verifies a managed policy is still attached
+func (d *AWSDetector) checkIAMPolicyAttachment(ctx context.Context, clients *awsClients, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	principal := iamPrincipalKinds[resource.Type]
+	name := stringAttr(attrs, principal.attr)
+	arn := stringAttr(attrs, "policy_arn")
+	if name == "" || arn == "" {
+		return nil, fmt.Errorf("%s or policy ARN not found", principal.kind)
+	}
+
+	attached, err := iamAttachedPolicies(ctx, clients.iam, principal.kind, name)
+	if err != nil {
+		if isAWSNotFound(err) {
+			return existenceDrift(resource), nil
+		}
+		return nil, err
+	}
+	for _, attachedArn := range attached {
+		if attachedArn == arn {
+			return nil, nil
+		}
+	}
+	return existenceDrift(resource), nil
+}
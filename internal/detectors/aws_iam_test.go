@@ -0,0 +1,101 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+)
+
+// newTestIAMClient serves the attached and inline policies of a role
+func newTestIAMClient(t *testing.T, attachedArns []string) *iam.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		switch action := r.Form.Get("Action"); action {
+		case "ListAttachedRolePolicies":
+			fmt.Fprint(w, `<ListAttachedRolePoliciesResponse><ListAttachedRolePoliciesResult><AttachedPolicies>`)
+			for _, arn := range attachedArns {
+				fmt.Fprintf(w, `<member><PolicyArn>%s</PolicyArn></member>`, arn)
+			}
+			fmt.Fprint(w, `</AttachedPolicies><IsTruncated>false</IsTruncated></ListAttachedRolePoliciesResult></ListAttachedRolePoliciesResponse>`)
+		case "ListRolePolicies":
+			fmt.Fprint(w, `<ListRolePoliciesResponse><ListRolePoliciesResult><PolicyNames></PolicyNames><IsTruncated>false</IsTruncated></ListRolePoliciesResult></ListRolePoliciesResponse>`)
+		default:
+			http.Error(w, "unexpected action "+action, http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return iam.NewFromConfig(aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		BaseEndpoint: aws.String(server.URL),
+	})
+}
+
+func TestIAMGrantChanges(t *testing.T) {
+	const (
+		readOnly = "arn:aws:iam::aws:policy/ReadOnlyAccess"
+		admin    = "arn:aws:iam::aws:policy/AdministratorAccess"
+		billing  = "arn:aws:iam::aws:policy/job-function/Billing"
+	)
+	client := newTestIAMClient(t, []string{readOnly, admin, billing})
+
+	related := indexRelated([]terraform.ResourceInstance{
+		{Type: "aws_iam_role_policy_attachment", Attributes: map[string]interface{}{
+			"role": "app", "policy_arn": readOnly,
+		}},
+		{Type: "aws_iam_policy_attachment", Attributes: map[string]interface{}{
+			"roles": []interface{}{"app", "ops"}, "users": []interface{}{"alice"}, "policy_arn": billing,
+		}},
+		{Type: "aws_iam_policy_attachment", Attributes: map[string]interface{}{
+			"users": []interface{}{"app"}, "policy_arn": admin,
+		}},
+	})
+
+	tests := []struct {
+		name       string
+		incomplete bool
+		want       []drift.Change
+		severity   string
+	}{
+		{
+			name:     "all states loaded",
+			want:     []drift.Change{{Field: "policy_attachment[" + admin + "]", Expected: "absent", Actual: "present"}},
+			severity: "high",
+		},
+		{
+			name:       "some states failed to load",
+			incomplete: true,
+			want:       []drift.Change{{Field: "policy_attachment[" + admin + "]", Expected: "absent", Actual: "present", Origin: drift.OriginUnknown}},
+			severity:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := iamGrantChanges(context.Background(), client, "role", "app", map[string]interface{}{}, related, tt.incomplete)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("iamGrantChanges() = %+v, want %+v", got, tt.want)
+			}
+			if severity := iamGrantSeverity(got[0]); severity != tt.severity {
+				t.Errorf("iamGrantSeverity() = %q, want %q", severity, tt.severity)
+			}
+		})
+	}
+}
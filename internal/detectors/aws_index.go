@@ -0,0 +1,410 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// ec2FilterBatchSize is the maximum number of values in a single EC2 filter
+const ec2FilterBatchSize = 200
+
+// rdsFilterBatchSize is the maximum number of values in a single RDS filter
+const rdsFilterBatchSize = 100
+
+// liveInstanceStates excludes terminated instances, which EC2 keeps
+// returning for a while after they are gone
+var liveInstanceStates = []string{"pending", "running", "shutting-down", "stopping", "stopped"}
+
+// awsResourceIndex holds the live resources of one account and region,
+// fetched in batches before individual resources are compared
+type awsResourceIndex struct {
+	instances    map[string]types.Instance
+	instancesErr error
+
+	securityGroups    map[string]types.SecurityGroup
+	securityGroupsErr error
+
+	// volumes holds the root volumes of the indexed instances
+	volumes    map[string]types.Volume
+	volumesErr error
+
+	dbInstances    map[string]rdstypes.DBInstance
+	dbInstancesErr error
+
+	dbClusters    map[string]rdstypes.DBCluster
+	dbClustersErr error
+
+	vpcs    map[string]types.Vpc
+	vpcsErr error
+
+	subnets    map[string]types.Subnet
+	subnetsErr error
+
+	routeTables    map[string]types.RouteTable
+	routeTablesErr error
+
+	networkACLs    map[string]types.NetworkAcl
+	networkACLsErr error
+
+	internetGateways    map[string]types.InternetGateway
+	internetGatewaysErr error
+
+	natGateways    map[string]types.NatGateway
+	natGatewaysErr error
+
+	peeringConnections    map[string]types.VpcPeeringConnection
+	peeringConnectionsErr error
+}
+
+// buildAWSIndex collects the IDs of the resources in one account and region
+// and describes them in paginated batches
+func buildAWSIndex(ctx context.Context, clients *awsClients, resources []terraform.ResourceInstance) *awsResourceIndex {
+	var instanceIDs, groupIDs, dbInstanceIDs, dbClusterIDs []string
+	var vpcIDs, subnetIDs, aclIDs, igwIDs, natIDs, peeringIDs []string
+	routeTableIDs := make(map[string]bool)
+	for _, resource := range resources {
+		id, _ := resource.Attributes["id"].(string)
+		if id == "" {
+			continue
+		}
+		switch resource.Type {
+		case "aws_instance":
+			instanceIDs = append(instanceIDs, id)
+		case "aws_security_group":
+			groupIDs = append(groupIDs, id)
+		case "aws_db_instance":
+			// The id of a DB instance is its resource ID in recent provider
+			// versions; the identifier is what the API filters on
+			dbInstanceIDs = append(dbInstanceIDs, dbInstanceIdentifier(resource))
+		case "aws_rds_cluster":
+			dbClusterIDs = append(dbClusterIDs, dbClusterIdentifier(resource))
+		case "aws_vpc":
+			vpcIDs = append(vpcIDs, id)
+		case "aws_subnet":
+			subnetIDs = append(subnetIDs, id)
+		case "aws_route_table":
+			routeTableIDs[id] = true
+		case "aws_route":
+			// Routes are looked up in their table, which may be managed
+			// elsewhere
+			if tableID := stringAttr(resource.Attributes, "route_table_id"); tableID != "" {
+				routeTableIDs[tableID] = true
+			}
+		case "aws_network_acl":
+			aclIDs = append(aclIDs, id)
+		case "aws_internet_gateway":
+			igwIDs = append(igwIDs, id)
+		case "aws_nat_gateway":
+			natIDs = append(natIDs, id)
+		case "aws_vpc_peering_connection":
+			peeringIDs = append(peeringIDs, id)
+		}
+	}
+
+	index := &awsResourceIndex{}
+	if len(instanceIDs) > 0 {
+		index.instances, index.instancesErr = describeInstances(ctx, clients.ec2, instanceIDs)
+	}
+	var volumeIDs []string
+	for _, instance := range index.instances {
+		if id := rootVolumeID(instance); id != "" {
+			volumeIDs = append(volumeIDs, id)
+		}
+	}
+	if len(volumeIDs) > 0 {
+		index.volumes, index.volumesErr = describeVolumes(ctx, clients.ec2, volumeIDs)
+	}
+	if len(groupIDs) > 0 {
+		index.securityGroups, index.securityGroupsErr = describeSecurityGroups(ctx, clients.ec2, groupIDs)
+	}
+
+	if len(dbInstanceIDs) > 0 {
+		index.dbInstances, index.dbInstancesErr = describeDBInstances(ctx, clients.rds, dbInstanceIDs)
+	}
+	if len(dbClusterIDs) > 0 {
+		index.dbClusters, index.dbClustersErr = describeDBClusters(ctx, clients.rds, dbClusterIDs)
+	}
+
+	if len(vpcIDs) > 0 {
+		index.vpcs, index.vpcsErr = describeVpcs(ctx, clients.ec2, vpcIDs)
+	}
+	if len(subnetIDs) > 0 {
+		index.subnets, index.subnetsErr = describeSubnets(ctx, clients.ec2, subnetIDs)
+	}
+	if len(routeTableIDs) > 0 {
+		ids := make([]string, 0, len(routeTableIDs))
+		for id := range routeTableIDs {
+			ids = append(ids, id)
+		}
+		index.routeTables, index.routeTablesErr = describeRouteTables(ctx, clients.ec2, ids)
+	}
+	if len(aclIDs) > 0 {
+		index.networkACLs, index.networkACLsErr = describeNetworkACLs(ctx, clients.ec2, aclIDs)
+	}
+	if len(igwIDs) > 0 {
+		index.internetGateways, index.internetGatewaysErr = describeInternetGateways(ctx, clients.ec2, igwIDs)
+	}
+	if len(natIDs) > 0 {
+		index.natGateways, index.natGatewaysErr = describeNatGateways(ctx, clients.ec2, natIDs)
+	}
+	if len(peeringIDs) > 0 {
+		index.peeringConnections, index.peeringConnectionsErr = describePeeringConnections(ctx, clients.ec2, peeringIDs)
+	}
+
+	log.Debugf("Indexed %d/%d instances, %d/%d security groups, %d/%d DB instances and %d/%d DB clusters",
+		len(index.instances), len(instanceIDs), len(index.securityGroups), len(groupIDs),
+		len(index.dbInstances), len(dbInstanceIDs), len(index.dbClusters), len(dbClusterIDs))
+	log.Debugf("Indexed %d/%d VPCs, %d/%d subnets, %d/%d route tables, %d/%d network ACLs, %d/%d internet gateways, %d/%d NAT gateways and %d/%d peering connections",
+		len(index.vpcs), len(vpcIDs), len(index.subnets), len(subnetIDs), len(index.routeTables), len(routeTableIDs),
+		len(index.networkACLs), len(aclIDs), len(index.internetGateways), len(igwIDs),
+		len(index.natGateways), len(natIDs), len(index.peeringConnections), len(peeringIDs))
+	return index
+}
+
+// pages adapts an SDK paginator, whose page and option types differ per
+// service and operation, to the items describeByID indexes
+type pages[T any] struct {
+	more func() bool
+	next func(ctx context.Context) ([]T, error)
+}
+
+// pagesOf builds pages from the HasMorePages and NextPage methods of a
+// paginator and a function returning the items of one page
+func pagesOf[O, T, Opt any](more func() bool, next func(context.Context, ...Opt) (O, error), items func(O) []T) pages[T] {
+	return pages[T]{
+		more: more,
+		next: func(ctx context.Context) ([]T, error) {
+			page, err := next(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return items(page), nil
+		},
+	}
+}
+
+// describeByID describes resources by ID in batches of at most batchSize,
+// pages through each batch and indexes the results by the ID key returns
+func describeByID[T any](ctx context.Context, what string, ids []string, batchSize int, paginate func(batch []string) pages[T], key func(T) *string) (map[string]T, error) {
+	result := make(map[string]T, len(ids))
+
+	for _, batch := range batches(ids, batchSize) {
+		paginator := paginate(batch)
+		for paginator.more() {
+			items, err := paginator.next(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe %s: %w", what, err)
+			}
+			for _, item := range items {
+				result[aws.ToString(key(item))] = item
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// describeInstances fetches instances by ID. IDs go in an instance-id filter
+// rather than InstanceIds so that a single missing instance does not fail
+// the whole batch.
+func describeInstances(ctx context.Context, client *ec2.Client, ids []string) (map[string]types.Instance, error) {
+	return describeByID(ctx, "instances", ids, ec2FilterBatchSize, func(batch []string) pages[types.Instance] {
+		paginator := ec2.NewDescribeInstancesPaginator(client, &ec2.DescribeInstancesInput{
+			Filters: []types.Filter{
+				{Name: aws.String("instance-id"), Values: batch},
+				{Name: aws.String("instance-state-name"), Values: liveInstanceStates},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *ec2.DescribeInstancesOutput) []types.Instance {
+			var instances []types.Instance
+			for _, reservation := range page.Reservations {
+				instances = append(instances, reservation.Instances...)
+			}
+			return instances
+		})
+	}, func(instance types.Instance) *string { return instance.InstanceId })
+}
+
+// describeSecurityGroups fetches security groups by ID using a group-id filter
+func describeSecurityGroups(ctx context.Context, client *ec2.Client, ids []string) (map[string]types.SecurityGroup, error) {
+	return describeByID(ctx, "security groups", ids, ec2FilterBatchSize, func(batch []string) pages[types.SecurityGroup] {
+		paginator := ec2.NewDescribeSecurityGroupsPaginator(client, &ec2.DescribeSecurityGroupsInput{
+			Filters: []types.Filter{
+				{Name: aws.String("group-id"), Values: batch},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *ec2.DescribeSecurityGroupsOutput) []types.SecurityGroup {
+			return page.SecurityGroups
+		})
+	}, func(group types.SecurityGroup) *string { return group.GroupId })
+}
+
+// describeVolumes fetches EBS volumes by ID using a volume-id filter
+func describeVolumes(ctx context.Context, client *ec2.Client, ids []string) (map[string]types.Volume, error) {
+	return describeByID(ctx, "volumes", ids, ec2FilterBatchSize, func(batch []string) pages[types.Volume] {
+		paginator := ec2.NewDescribeVolumesPaginator(client, &ec2.DescribeVolumesInput{
+			Filters: []types.Filter{
+				{Name: aws.String("volume-id"), Values: batch},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *ec2.DescribeVolumesOutput) []types.Volume {
+			return page.Volumes
+		})
+	}, func(volume types.Volume) *string { return volume.VolumeId })
+}
+
+// describeDBInstances fetches DB instances by identifier using a
+// db-instance-id filter, which unlike DBInstanceIdentifier takes many values
+// and does not fail on missing ones
+func describeDBInstances(ctx context.Context, client *rds.Client, ids []string) (map[string]rdstypes.DBInstance, error) {
+	return describeByID(ctx, "DB instances", ids, rdsFilterBatchSize, func(batch []string) pages[rdstypes.DBInstance] {
+		paginator := rds.NewDescribeDBInstancesPaginator(client, &rds.DescribeDBInstancesInput{
+			Filters: []rdstypes.Filter{
+				{Name: aws.String("db-instance-id"), Values: batch},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *rds.DescribeDBInstancesOutput) []rdstypes.DBInstance {
+			return page.DBInstances
+		})
+	}, func(instance rdstypes.DBInstance) *string { return instance.DBInstanceIdentifier })
+}
+
+// describeDBClusters fetches DB clusters by identifier using a db-cluster-id filter
+func describeDBClusters(ctx context.Context, client *rds.Client, ids []string) (map[string]rdstypes.DBCluster, error) {
+	return describeByID(ctx, "DB clusters", ids, rdsFilterBatchSize, func(batch []string) pages[rdstypes.DBCluster] {
+		paginator := rds.NewDescribeDBClustersPaginator(client, &rds.DescribeDBClustersInput{
+			Filters: []rdstypes.Filter{
+				{Name: aws.String("db-cluster-id"), Values: batch},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *rds.DescribeDBClustersOutput) []rdstypes.DBCluster {
+			return page.DBClusters
+		})
+	}, func(cluster rdstypes.DBCluster) *string { return cluster.DBClusterIdentifier })
+}
+
+// describeVpcs fetches VPCs by ID using a vpc-id filter
+func describeVpcs(ctx context.Context, client *ec2.Client, ids []string) (map[string]types.Vpc, error) {
+	return describeByID(ctx, "VPCs", ids, ec2FilterBatchSize, func(batch []string) pages[types.Vpc] {
+		paginator := ec2.NewDescribeVpcsPaginator(client, &ec2.DescribeVpcsInput{
+			Filters: []types.Filter{
+				{Name: aws.String("vpc-id"), Values: batch},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *ec2.DescribeVpcsOutput) []types.Vpc {
+			return page.Vpcs
+		})
+	}, func(vpc types.Vpc) *string { return vpc.VpcId })
+}
+
+// describeSubnets fetches subnets by ID using a subnet-id filter
+func describeSubnets(ctx context.Context, client *ec2.Client, ids []string) (map[string]types.Subnet, error) {
+	return describeByID(ctx, "subnets", ids, ec2FilterBatchSize, func(batch []string) pages[types.Subnet] {
+		paginator := ec2.NewDescribeSubnetsPaginator(client, &ec2.DescribeSubnetsInput{
+			Filters: []types.Filter{
+				{Name: aws.String("subnet-id"), Values: batch},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *ec2.DescribeSubnetsOutput) []types.Subnet {
+			return page.Subnets
+		})
+	}, func(subnet types.Subnet) *string { return subnet.SubnetId })
+}
+
+// describeRouteTables fetches route tables by ID using a route-table-id filter
+func describeRouteTables(ctx context.Context, client *ec2.Client, ids []string) (map[string]types.RouteTable, error) {
+	return describeByID(ctx, "route tables", ids, ec2FilterBatchSize, func(batch []string) pages[types.RouteTable] {
+		paginator := ec2.NewDescribeRouteTablesPaginator(client, &ec2.DescribeRouteTablesInput{
+			Filters: []types.Filter{
+				{Name: aws.String("route-table-id"), Values: batch},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *ec2.DescribeRouteTablesOutput) []types.RouteTable {
+			return page.RouteTables
+		})
+	}, func(table types.RouteTable) *string { return table.RouteTableId })
+}
+
+// describeNetworkACLs fetches network ACLs by ID using a network-acl-id filter
+func describeNetworkACLs(ctx context.Context, client *ec2.Client, ids []string) (map[string]types.NetworkAcl, error) {
+	return describeByID(ctx, "network ACLs", ids, ec2FilterBatchSize, func(batch []string) pages[types.NetworkAcl] {
+		paginator := ec2.NewDescribeNetworkAclsPaginator(client, &ec2.DescribeNetworkAclsInput{
+			Filters: []types.Filter{
+				{Name: aws.String("network-acl-id"), Values: batch},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *ec2.DescribeNetworkAclsOutput) []types.NetworkAcl {
+			return page.NetworkAcls
+		})
+	}, func(acl types.NetworkAcl) *string { return acl.NetworkAclId })
+}
+
+// describeInternetGateways fetches internet gateways by ID using an
+// internet-gateway-id filter
+func describeInternetGateways(ctx context.Context, client *ec2.Client, ids []string) (map[string]types.InternetGateway, error) {
+	return describeByID(ctx, "internet gateways", ids, ec2FilterBatchSize, func(batch []string) pages[types.InternetGateway] {
+		paginator := ec2.NewDescribeInternetGatewaysPaginator(client, &ec2.DescribeInternetGatewaysInput{
+			Filters: []types.Filter{
+				{Name: aws.String("internet-gateway-id"), Values: batch},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *ec2.DescribeInternetGatewaysOutput) []types.InternetGateway {
+			return page.InternetGateways
+		})
+	}, func(gateway types.InternetGateway) *string { return gateway.InternetGatewayId })
+}
+
+// describeNatGateways fetches NAT gateways by ID using a nat-gateway-id
+// filter. Deleted gateways, which EC2 keeps returning for about an hour,
+// are left out.
+func describeNatGateways(ctx context.Context, client *ec2.Client, ids []string) (map[string]types.NatGateway, error) {
+	return describeByID(ctx, "NAT gateways", ids, ec2FilterBatchSize, func(batch []string) pages[types.NatGateway] {
+		paginator := ec2.NewDescribeNatGatewaysPaginator(client, &ec2.DescribeNatGatewaysInput{
+			Filter: []types.Filter{
+				{Name: aws.String("nat-gateway-id"), Values: batch},
+				{Name: aws.String("state"), Values: []string{"pending", "available"}},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *ec2.DescribeNatGatewaysOutput) []types.NatGateway {
+			return page.NatGateways
+		})
+	}, func(gateway types.NatGateway) *string { return gateway.NatGatewayId })
+}
+
+// describePeeringConnections fetches VPC peering connections by ID using a
+// vpc-peering-connection-id filter. Deleted, rejected and expired
+// connections are left out.
+func describePeeringConnections(ctx context.Context, client *ec2.Client, ids []string) (map[string]types.VpcPeeringConnection, error) {
+	return describeByID(ctx, "VPC peering connections", ids, ec2FilterBatchSize, func(batch []string) pages[types.VpcPeeringConnection] {
+		paginator := ec2.NewDescribeVpcPeeringConnectionsPaginator(client, &ec2.DescribeVpcPeeringConnectionsInput{
+			Filters: []types.Filter{
+				{Name: aws.String("vpc-peering-connection-id"), Values: batch},
+				{Name: aws.String("status-code"), Values: []string{"initiating-request", "pending-acceptance", "provisioning", "active"}},
+			},
+		})
+		return pagesOf(paginator.HasMorePages, paginator.NextPage, func(page *ec2.DescribeVpcPeeringConnectionsOutput) []types.VpcPeeringConnection {
+			return page.VpcPeeringConnections
+		})
+	}, func(connection types.VpcPeeringConnection) *string { return connection.VpcPeeringConnectionId })
+}
+
+// batches splits ids into slices of at most size elements
+func batches(ids []string, size int) [][]string {
+	var result [][]string
+	for len(ids) > size {
+		result = append(result, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		result = append(result, ids)
+	}
+	return result
+}
@@ -0,0 +1,154 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+)
+
+func TestBatches(t *testing.T) {
+	ids := func(n int) []string {
+		result := make([]string, n)
+		for i := range result {
+			result[i] = fmt.Sprintf("i-%d", i)
+		}
+		return result
+	}
+
+	tests := []struct {
+		n    int
+		size int
+		want []int
+	}{
+		{0, 200, nil},
+		{1, 200, []int{1}},
+		{200, 200, []int{200}},
+		{201, 200, []int{200, 1}},
+		{450, 200, []int{200, 200, 50}},
+	}
+
+	for _, tt := range tests {
+		var sizes []int
+		for _, batch := range batches(ids(tt.n), tt.size) {
+			sizes = append(sizes, len(batch))
+		}
+		if !reflect.DeepEqual(sizes, tt.want) {
+			t.Errorf("batches(%d ids, %d) sizes = %v, want %v", tt.n, tt.size, sizes, tt.want)
+		}
+	}
+}
+
+// ec2Stub answers DescribeInstances and DescribeSecurityGroups with the
+// requested IDs that exist, recording the number of IDs in each request
+type ec2Stub struct {
+	mu       sync.Mutex
+	existing map[string]bool
+	requests map[string][]int
+}
+
+func (s *ec2Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := r.Form.Get("Action")
+
+	var ids []string
+	for key, values := range r.Form {
+		if strings.HasPrefix(key, "Filter.") && strings.Contains(key, ".Value.") &&
+			strings.HasSuffix(r.Form.Get(strings.SplitN(key, ".Value.", 2)[0]+".Name"), "-id") {
+			ids = append(ids, values...)
+		}
+	}
+
+	s.mu.Lock()
+	s.requests[action] = append(s.requests[action], len(ids))
+	s.mu.Unlock()
+
+	var items strings.Builder
+	for _, id := range ids {
+		if !s.existing[id] {
+			continue
+		}
+		switch action {
+		case "DescribeInstances":
+			fmt.Fprintf(&items, "<item><instancesSet><item><instanceId>%s</instanceId></item></instancesSet></item>", id)
+		case "DescribeSecurityGroups":
+			fmt.Fprintf(&items, "<item><groupId>%s</groupId></item>", id)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	switch action {
+	case "DescribeInstances":
+		fmt.Fprintf(w, "<DescribeInstancesResponse><reservationSet>%s</reservationSet></DescribeInstancesResponse>", items.String())
+	case "DescribeSecurityGroups":
+		fmt.Fprintf(w, "<DescribeSecurityGroupsResponse><securityGroupInfo>%s</securityGroupInfo></DescribeSecurityGroupsResponse>", items.String())
+	default:
+		http.Error(w, "unexpected action "+action, http.StatusBadRequest)
+	}
+}
+
+func newTestEC2Client(t *testing.T, handler http.Handler) *ec2.Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return ec2.NewFromConfig(aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		BaseEndpoint: aws.String(server.URL),
+	})
+}
+
+func TestBuildAWSIndexBatches(t *testing.T) {
+	stub := &ec2Stub{existing: make(map[string]bool), requests: make(map[string][]int)}
+
+	var resources []terraform.ResourceInstance
+	for i := 0; i < 450; i++ {
+		id := fmt.Sprintf("i-%04d", i)
+		if i%3 != 0 {
+			stub.existing[id] = true
+		}
+		resources = append(resources, terraform.ResourceInstance{Type: "aws_instance", Attributes: map[string]interface{}{"id": id}})
+	}
+	for _, id := range []string{"sg-1", "sg-2"} {
+		resources = append(resources, terraform.ResourceInstance{Type: "aws_security_group", Attributes: map[string]interface{}{"id": id}})
+	}
+	stub.existing["sg-2"] = true
+	// Resources without an ID, such as ones still being created, are skipped
+	resources = append(resources, terraform.ResourceInstance{Type: "aws_instance", Attributes: map[string]interface{}{}})
+
+	index := buildAWSIndex(context.Background(), &awsClients{ec2: newTestEC2Client(t, stub)}, resources)
+
+	if index.instancesErr != nil || index.securityGroupsErr != nil {
+		t.Fatalf("buildAWSIndex() errors = %v, %v", index.instancesErr, index.securityGroupsErr)
+	}
+	if got := stub.requests["DescribeInstances"]; !reflect.DeepEqual(got, []int{200, 200, 50}) {
+		t.Errorf("DescribeInstances batch sizes = %v, want [200 200 50]", got)
+	}
+	if got := stub.requests["DescribeSecurityGroups"]; !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("DescribeSecurityGroups batch sizes = %v, want [2]", got)
+	}
+	if len(index.instances) != 300 {
+		t.Errorf("indexed %d instances, want 300", len(index.instances))
+	}
+	if _, found := index.instances["i-0000"]; found {
+		t.Error("missing instance i-0000 is in the index")
+	}
+	if _, found := index.instances["i-0001"]; !found {
+		t.Error("instance i-0001 is not in the index")
+	}
+	if _, found := index.securityGroups["sg-2"]; !found || len(index.securityGroups) != 1 {
+		t.Errorf("indexed security groups = %v, want only sg-2", index.securityGroups)
+	}
+}
@@ -0,0 +1,141 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// redacted stands in for environment variable values, which often hold
+// secrets and must not end up in reports or notifications
+const redacted = "(redacted)"
+
+// diffEnvironment reports environment variables added, removed or changed
+// outside Terraform without revealing their values
+func diffEnvironment(expected, actual map[string]string) []drift.Change {
+	keys := make([]string, 0, len(expected)+len(actual))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	for key := range actual {
+		if _, ok := expected[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []drift.Change
+	for _, key := range keys {
+		want, inState := expected[key]
+		got, inCloud := actual[key]
+		field := "environment.variables." + key
+		switch {
+		case !inState:
+			changes = append(changes, drift.Change{Field: field, Expected: "absent", Actual: redacted})
+		case !inCloud:
+			changes = append(changes, drift.Change{Field: field, Expected: redacted, Actual: "absent"})
+		case want != got:
+			changes = append(changes, drift.Change{Field: field, Expected: redacted, Actual: "(changed, redacted)"})
+		}
+	}
+	return changes
+}
+
+func (d *AWSDetector) checkLambdaFunction(ctx context.Context, clients *awsClients, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	functionName := stringAttr(attrs, "function_name")
+	if functionName == "" {
+		return nil, fmt.Errorf("function name not found")
+	}
+
+	out, err := clients.lambda.GetFunction(ctx, &lambda.GetFunctionInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		if isAWSNotFound(err) {
+			return existenceDrift(resource), nil
+		}
+		return nil, fmt.Errorf("failed to get function: %w", err)
+	}
+	function := out.Configuration
+	if function == nil {
+		return nil, fmt.Errorf("function %s has no configuration", functionName)
+	}
+
+	var changes changeSet
+
+	// Code deployed outside Terraform, e.g. edited in the console, shows as
+	// a different hash. code_sha256 is the hash the provider read back after
+	// the last apply; source_code_hash is set by the configuration and may be
+	// computed differently, so it is only used when code_sha256 is missing.
+	// Container image functions have no package hash.
+	if stringAttr(attrs, "package_type") != "Image" {
+		field := "code_sha256"
+		expectedHash := stringAttr(attrs, field)
+		if expectedHash == "" {
+			field = "source_code_hash"
+			expectedHash = stringAttr(attrs, field)
+		}
+		if expectedHash != "" && expectedHash != aws.ToString(function.CodeSha256) {
+			changes.add(field, expectedHash, aws.ToString(function.CodeSha256))
+		}
+	}
+
+	changes.compareString("runtime", attrs["runtime"], string(function.Runtime))
+	changes.compareString("handler", attrs["handler"], aws.ToString(function.Handler))
+	changes.compareInt("memory_size", attrs["memory_size"], int(aws.ToInt32(function.MemorySize)))
+	changes.compareInt("timeout", attrs["timeout"], int(aws.ToInt32(function.Timeout)))
+	changes.compareString("role", attrs["role"], aws.ToString(function.Role))
+
+	// Layers apply in order, so their order matters
+	var layers []string
+	for _, layer := range function.Layers {
+		layers = append(layers, aws.ToString(layer.Arn))
+	}
+	if list, ok := attrs["layers"].([]interface{}); ok {
+		var expectedLayers []string
+		for _, v := range list {
+			if s, ok := v.(string); ok {
+				expectedLayers = append(expectedLayers, s)
+			}
+		}
+		if strings.Join(expectedLayers, ",") != strings.Join(layers, ",") {
+			changes.add("layers", expectedLayers, layers)
+		}
+	}
+
+	if vpc := firstBlock(attrs, "vpc_config"); vpc != nil {
+		var subnets, groups []string
+		if function.VpcConfig != nil {
+			subnets = function.VpcConfig.SubnetIds
+			groups = function.VpcConfig.SecurityGroupIds
+		}
+		changes.compareStrings("vpc_config.subnet_ids", vpc["subnet_ids"], subnets)
+		changes.compareStrings("vpc_config.security_group_ids", vpc["security_group_ids"], groups)
+	} else if function.VpcConfig != nil && aws.ToString(function.VpcConfig.VpcId) != "" {
+		changes.add("vpc_config.vpc_id", "", aws.ToString(function.VpcConfig.VpcId))
+	}
+
+	// -1 means no reserved concurrency
+	concurrency := -1
+	if out.Concurrency != nil && out.Concurrency.ReservedConcurrentExecutions != nil {
+		concurrency = int(*out.Concurrency.ReservedConcurrentExecutions)
+	}
+	changes.compareInt("reserved_concurrent_executions", attrs["reserved_concurrent_executions"], concurrency)
+
+	var variables map[string]string
+	if function.Environment != nil {
+		variables = function.Environment.Variables
+	}
+	changes = append(changes, diffEnvironment(stringMapAttr(firstBlock(attrs, "environment"), "variables"), variables)...)
+
+	changes = append(changes, d.tagChanges(attrs, out.Tags)...)
+
+	return changesDrift(resource, functionName, changes), nil
+}
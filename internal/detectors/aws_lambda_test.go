@@ -0,0 +1,178 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+func TestDiffEnvironment(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected map[string]string
+		actual   map[string]string
+		want     []drift.Change
+	}{
+		{"equal", map[string]string{"STAGE": "prod"}, map[string]string{"STAGE": "prod"}, nil},
+		{
+			"added, removed and changed",
+			map[string]string{"DB_PASSWORD": "hunter2", "STAGE": "prod"},
+			map[string]string{"STAGE": "dev", "API_KEY": "sk-live-123"},
+			[]drift.Change{
+				{Field: "environment.variables.API_KEY", Expected: "absent", Actual: redacted},
+				{Field: "environment.variables.DB_PASSWORD", Expected: redacted, Actual: "absent"},
+				{Field: "environment.variables.STAGE", Expected: redacted, Actual: "(changed, redacted)"},
+			},
+		},
+		{"no environment", nil, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffEnvironment(tt.expected, tt.actual)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffEnvironment() = %+v, want %+v", got, tt.want)
+			}
+			for _, change := range got {
+				for _, secret := range []string{"hunter2", "sk-live-123", "prod", "dev"} {
+					if fmt.Sprint(change.Expected) == secret || fmt.Sprint(change.Actual) == secret {
+						t.Errorf("change %s reveals the value %q", change.Field, secret)
+					}
+				}
+			}
+		})
+	}
+}
+
+// newTestLambdaClient serves GetFunction with the given configuration JSON
+func newTestLambdaClient(t *testing.T, configuration string) *lambda.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/2015-03-31/functions/") {
+			http.Error(w, "unexpected request "+r.URL.Path, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"Configuration": %s, "Tags": {}}`, configuration)
+	}))
+	t.Cleanup(server.Close)
+
+	return lambda.NewFromConfig(aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		BaseEndpoint: aws.String(server.URL),
+	})
+}
+
+func TestCheckLambdaFunction(t *testing.T) {
+	client := newTestLambdaClient(t, `{
+		"FunctionName": "api",
+		"Runtime": "python3.12",
+		"Handler": "app.handler",
+		"MemorySize": 256,
+		"Timeout": 30,
+		"CodeSha256": "deployed=",
+		"Layers": [{"Arn": "arn:aws:lambda:us-east-1:123456789012:layer:b:1"}, {"Arn": "arn:aws:lambda:us-east-1:123456789012:layer:a:1"}],
+		"Environment": {"Variables": {"STAGE": "prod", "TOKEN": "from-console"}}
+	}`)
+
+	resource := terraform.ResourceInstance{
+		Address: "aws_lambda_function.api",
+		Type:    "aws_lambda_function",
+		Name:    "api",
+		Attributes: map[string]interface{}{
+			"function_name":    "api",
+			"runtime":          "python3.12",
+			"handler":          "app.handler",
+			"memory_size":      float64(512),
+			"timeout":          float64(30),
+			"source_code_hash": "deployed=",
+			"layers": []interface{}{
+				"arn:aws:lambda:us-east-1:123456789012:layer:a:1",
+				"arn:aws:lambda:us-east-1:123456789012:layer:b:1",
+			},
+			"environment": []interface{}{map[string]interface{}{
+				"variables": map[string]interface{}{"STAGE": "prod"},
+			}},
+		},
+	}
+
+	item, err := (&AWSDetector{}).checkLambdaFunction(context.Background(), &awsClients{lambda: client}, resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item == nil {
+		t.Fatal("checkLambdaFunction() found no drift")
+	}
+
+	var fields []string
+	for _, change := range item.Changes {
+		fields = append(fields, change.Field)
+	}
+	want := []string{"memory_size", "layers", "environment.variables.TOKEN"}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("changed fields = %q, want %q", fields, want)
+	}
+	for _, change := range item.Changes {
+		if change.Actual == "from-console" {
+			t.Errorf("change %s reveals an environment variable value", change.Field)
+		}
+	}
+}
+
+func TestCheckLambdaFunctionCodeHash(t *testing.T) {
+	client := newTestLambdaClient(t, `{"FunctionName": "api", "CodeSha256": "deployed="}`)
+
+	tests := []struct {
+		name  string
+		attrs map[string]interface{}
+		want  []drift.Change
+	}{
+		{
+			name:  "code_sha256 matches",
+			attrs: map[string]interface{}{"code_sha256": "deployed=", "source_code_hash": "computed-by-config="},
+		},
+		{
+			name:  "code_sha256 differs",
+			attrs: map[string]interface{}{"code_sha256": "applied=", "source_code_hash": "applied="},
+			want:  []drift.Change{{Field: "code_sha256", Expected: "applied=", Actual: "deployed="}},
+		},
+		{
+			name:  "source_code_hash without code_sha256",
+			attrs: map[string]interface{}{"source_code_hash": "applied="},
+			want:  []drift.Change{{Field: "source_code_hash", Expected: "applied=", Actual: "deployed="}},
+		},
+		{
+			name:  "container image",
+			attrs: map[string]interface{}{"package_type": "Image", "code_sha256": "image-digest"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.attrs["function_name"] = "api"
+			resource := terraform.ResourceInstance{Address: "aws_lambda_function.api", Type: "aws_lambda_function", Attributes: tt.attrs}
+
+			item, err := (&AWSDetector{}).checkLambdaFunction(context.Background(), &awsClients{lambda: client}, resource)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []drift.Change
+			if item != nil {
+				got = item.Changes
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changes = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
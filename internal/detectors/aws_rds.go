@@ -0,0 +1,156 @@
+package detectors
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// dbInstanceIdentifier returns the identifier of an aws_db_instance, which
+// older provider versions only record as its id
+func dbInstanceIdentifier(resource terraform.ResourceInstance) string {
+	if id := stringAttr(resource.Attributes, "identifier"); id != "" {
+		return id
+	}
+	return stringAttr(resource.Attributes, "id")
+}
+
+// dbClusterIdentifier returns the identifier of an aws_rds_cluster
+func dbClusterIdentifier(resource terraform.ResourceInstance) string {
+	if id := stringAttr(resource.Attributes, "cluster_identifier"); id != "" {
+		return id
+	}
+	return stringAttr(resource.Attributes, "id")
+}
+
+// pending returns the value a modification waiting for the maintenance
+// window will set, or the current value. A change Terraform applied without
+// apply_immediately is not drift.
+func pending[T any](current, pendingValue *T) *T {
+	if pendingValue != nil {
+		return pendingValue
+	}
+	return current
+}
+
+// compareEngineVersion compares engine versions. engine_version_actual is
+// the running version when the state has it; otherwise a configured major
+// or minor version such as "14" matches any release within it, since RDS
+// applies minor upgrades on its own.
+func compareEngineVersion(changes *changeSet, attrs map[string]interface{}, actual string) {
+	if version := stringAttr(attrs, "engine_version_actual"); version != "" {
+		changes.compareString("engine_version", version, actual)
+		return
+	}
+	version := stringAttr(attrs, "engine_version")
+	if version != "" && version != actual && !strings.HasPrefix(actual, version+".") {
+		changes.add("engine_version", version, actual)
+	}
+}
+
+// rdsTags converts RDS tags to a map
+func rdsTags(tags []types.Tag) map[string]string {
+	result := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		if tag.Key != nil {
+			result[*tag.Key] = aws.ToString(tag.Value)
+		}
+	}
+	return result
+}
+
+// rdsSecurityGroupIDs lists the VPC security groups of a DB instance or cluster
+func rdsSecurityGroupIDs(groups []types.VpcSecurityGroupMembership) []string {
+	var ids []string
+	for _, group := range groups {
+		ids = append(ids, aws.ToString(group.VpcSecurityGroupId))
+	}
+	return ids
+}
+
+func (d *AWSDetector) checkDBInstance(index *awsResourceIndex, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	identifier := dbInstanceIdentifier(resource)
+	if identifier == "" {
+		return nil, fmt.Errorf("DB instance identifier not found")
+	}
+	if index.dbInstancesErr != nil {
+		return nil, index.dbInstancesErr
+	}
+
+	instance, found := index.dbInstances[identifier]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	attrs := resource.Attributes
+	var changes changeSet
+
+	modified := instance.PendingModifiedValues
+	if modified == nil {
+		modified = &types.PendingModifiedValues{}
+	}
+
+	changes.compareString("instance_class", attrs["instance_class"], aws.ToString(pending(instance.DBInstanceClass, modified.DBInstanceClass)))
+	compareEngineVersion(&changes, attrs, aws.ToString(pending(instance.EngineVersion, modified.EngineVersion)))
+	changes.compareInt("allocated_storage", attrs["allocated_storage"], int(aws.ToInt32(pending(instance.AllocatedStorage, modified.AllocatedStorage))))
+	changes.compareString("storage_type", attrs["storage_type"], aws.ToString(pending(instance.StorageType, modified.StorageType)))
+	changes.compareInt("iops", attrs["iops"], int(aws.ToInt32(pending(instance.Iops, modified.Iops))))
+	changes.compareBool("multi_az", attrs["multi_az"], aws.ToBool(pending(instance.MultiAZ, modified.MultiAZ)))
+	changes.compareBool("publicly_accessible", attrs["publicly_accessible"], aws.ToBool(instance.PubliclyAccessible))
+	changes.compareInt("backup_retention_period", attrs["backup_retention_period"], int(aws.ToInt32(pending(instance.BackupRetentionPeriod, modified.BackupRetentionPeriod))))
+	changes.compareBool("deletion_protection", attrs["deletion_protection"], aws.ToBool(instance.DeletionProtection))
+	changes.compareBool("storage_encrypted", attrs["storage_encrypted"], aws.ToBool(instance.StorageEncrypted))
+	changes.compareStrings("vpc_security_group_ids", attrs["vpc_security_group_ids"], rdsSecurityGroupIDs(instance.VpcSecurityGroups))
+
+	var parameterGroup string
+	if len(instance.DBParameterGroups) > 0 {
+		parameterGroup = aws.ToString(instance.DBParameterGroups[0].DBParameterGroupName)
+	}
+	changes.compareString("parameter_group_name", attrs["parameter_group_name"], parameterGroup)
+
+	changes = append(changes, d.tagChanges(attrs, rdsTags(instance.TagList))...)
+
+	return changesDrift(resource, identifier, changes), nil
+}
+
+func (d *AWSDetector) checkRDSCluster(index *awsResourceIndex, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	identifier := dbClusterIdentifier(resource)
+	if identifier == "" {
+		return nil, fmt.Errorf("DB cluster identifier not found")
+	}
+	if index.dbClustersErr != nil {
+		return nil, index.dbClustersErr
+	}
+
+	cluster, found := index.dbClusters[identifier]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	attrs := resource.Attributes
+	var changes changeSet
+
+	modified := cluster.PendingModifiedValues
+	if modified == nil {
+		modified = &types.ClusterPendingModifiedValues{}
+	}
+
+	compareEngineVersion(&changes, attrs, aws.ToString(pending(cluster.EngineVersion, modified.EngineVersion)))
+	changes.compareString("db_cluster_instance_class", attrs["db_cluster_instance_class"], aws.ToString(cluster.DBClusterInstanceClass))
+	changes.compareInt("allocated_storage", attrs["allocated_storage"], int(aws.ToInt32(pending(cluster.AllocatedStorage, modified.AllocatedStorage))))
+	changes.compareString("storage_type", attrs["storage_type"], aws.ToString(pending(cluster.StorageType, modified.StorageType)))
+	changes.compareInt("iops", attrs["iops"], int(aws.ToInt32(pending(cluster.Iops, modified.Iops))))
+	changes.compareInt("backup_retention_period", attrs["backup_retention_period"], int(aws.ToInt32(pending(cluster.BackupRetentionPeriod, modified.BackupRetentionPeriod))))
+	changes.compareBool("deletion_protection", attrs["deletion_protection"], aws.ToBool(cluster.DeletionProtection))
+	changes.compareBool("storage_encrypted", attrs["storage_encrypted"], aws.ToBool(cluster.StorageEncrypted))
+	changes.compareString("db_cluster_parameter_group_name", attrs["db_cluster_parameter_group_name"], aws.ToString(cluster.DBClusterParameterGroup))
+	changes.compareStrings("vpc_security_group_ids", attrs["vpc_security_group_ids"], rdsSecurityGroupIDs(cluster.VpcSecurityGroups))
+
+	changes = append(changes, d.tagChanges(attrs, rdsTags(cluster.TagList))...)
+
+	return changesDrift(resource, identifier, changes), nil
+}
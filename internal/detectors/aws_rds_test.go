@@ -0,0 +1,119 @@
+package detectors
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+func TestCompareEngineVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		attrs  map[string]interface{}
+		actual string
+		want   bool
+	}{
+		{"same version", map[string]interface{}{"engine_version": "14.9"}, "14.9", false},
+		{"major version matches minor release", map[string]interface{}{"engine_version": "14"}, "14.10", false},
+		{"prefix is not a release", map[string]interface{}{"engine_version": "1"}, "14.10", true},
+		{"other major version", map[string]interface{}{"engine_version": "14"}, "15.4", true},
+		{"actual version recorded", map[string]interface{}{"engine_version": "14", "engine_version_actual": "14.9"}, "14.10", true},
+		{"actual version matches", map[string]interface{}{"engine_version": "14", "engine_version_actual": "14.10"}, "14.10", false},
+		{"no version in state", map[string]interface{}{}, "14.10", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes changeSet
+			compareEngineVersion(&changes, tt.attrs, tt.actual)
+			if got := len(changes) > 0; got != tt.want {
+				t.Errorf("compareEngineVersion() changes = %+v, want drift %v", changes, tt.want)
+			}
+		})
+	}
+}
+
+func TestDBIdentifiers(t *testing.T) {
+	instance := terraform.ResourceInstance{Attributes: map[string]interface{}{"id": "db-ABCDEFGH", "identifier": "main"}}
+	legacy := terraform.ResourceInstance{Attributes: map[string]interface{}{"id": "main"}}
+	cluster := terraform.ResourceInstance{Attributes: map[string]interface{}{"id": "aurora", "cluster_identifier": "aurora"}}
+
+	if got := dbInstanceIdentifier(instance); got != "main" {
+		t.Errorf("dbInstanceIdentifier() = %q, want main", got)
+	}
+	if got := dbInstanceIdentifier(legacy); got != "main" {
+		t.Errorf("dbInstanceIdentifier() of an old state = %q, want main", got)
+	}
+	if got := dbClusterIdentifier(cluster); got != "aurora" {
+		t.Errorf("dbClusterIdentifier() = %q, want aurora", got)
+	}
+}
+
+func TestCheckDBInstancePendingModifications(t *testing.T) {
+	attrs := map[string]interface{}{
+		"identifier":              "main",
+		"instance_class":          "db.r6g.large",
+		"allocated_storage":       float64(100),
+		"multi_az":                true,
+		"backup_retention_period": float64(7),
+		"publicly_accessible":     false,
+	}
+
+	tests := []struct {
+		name       string
+		pending    *types.PendingModifiedValues
+		wantFields []string
+	}{
+		{
+			name:       "no pending modifications",
+			wantFields: []string{"instance_class", "allocated_storage", "multi_az"},
+		},
+		{
+			name: "applied changes waiting for the maintenance window",
+			pending: &types.PendingModifiedValues{
+				DBInstanceClass:  aws.String("db.r6g.large"),
+				AllocatedStorage: aws.Int32(100),
+				MultiAZ:          aws.Bool(true),
+			},
+		},
+		{
+			name:       "pending change to another value",
+			pending:    &types.PendingModifiedValues{DBInstanceClass: aws.String("db.r6g.xlarge")},
+			wantFields: []string{"instance_class", "allocated_storage", "multi_az"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := &awsResourceIndex{dbInstances: map[string]types.DBInstance{
+				"main": {
+					DBInstanceIdentifier:  aws.String("main"),
+					DBInstanceClass:       aws.String("db.t3.medium"),
+					AllocatedStorage:      aws.Int32(50),
+					MultiAZ:               aws.Bool(false),
+					BackupRetentionPeriod: aws.Int32(7),
+					PubliclyAccessible:    aws.Bool(false),
+					PendingModifiedValues: tt.pending,
+				},
+			}}
+			resource := terraform.ResourceInstance{Address: "aws_db_instance.main", Type: "aws_db_instance", Name: "main", Attributes: attrs}
+
+			item, err := (&AWSDetector{}).checkDBInstance(index, resource)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fields []string
+			if item != nil {
+				for _, change := range item.Changes {
+					fields = append(fields, change.Field)
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("changed fields = %q, want %q", fields, tt.wantFields)
+			}
+		})
+	}
+}
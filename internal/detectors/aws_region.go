@@ -0,0 +1,160 @@
+package detectors
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	log "github.com/sirupsen/logrus"
+)
+
+// awsRegionPattern extracts the region from an availability zone, including
+// Local Zones and Wavelength Zones such as us-west-2-lax-1a
+var awsRegionPattern = regexp.MustCompile(`^([a-z]{2}(?:-gov|-iso[a-z]?)?-[a-z]+-\d+)`)
+
+// awsGlobalRegion keys the token bucket of global services, whose quota is
+// shared by all regions of an account
+const awsGlobalRegion = "global"
+
+// awsClients holds the service clients for one region
+type awsClients struct {
+	ec2    *ec2.Client
+	s3     *s3.Client
+	rds    *rds.Client
+	lambda *lambda.Client
+	iam    *iam.Client
+}
+
+// awsRegionClients creates and caches service clients per region
+type awsRegionClients struct {
+	cfg     aws.Config
+	policy  *APIPolicy
+	mu      sync.Mutex
+	clients map[string]*awsClients
+}
+
+func newAWSRegionClients(cfg aws.Config, regions []string, policy *APIPolicy) *awsRegionClients {
+	c := &awsRegionClients{
+		cfg:     cfg,
+		policy:  policy,
+		clients: make(map[string]*awsClients),
+	}
+	for _, region := range regions {
+		c.forRegion(region)
+	}
+	return c
+}
+
+// forRegion returns the clients for a region, creating them on first use
+func (c *awsRegionClients) forRegion(region string) *awsClients {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if clients, ok := c.clients[region]; ok {
+		return clients
+	}
+
+	retryer := awsRetryer(c.policy)
+	rateLimit := awsRateLimit(c.policy.Limiter("aws", region))
+	globalRateLimit := awsRateLimit(c.policy.Limiter("aws", awsLimiterRegion(iam.ServiceID, region)))
+
+	clients := &awsClients{
+		ec2: ec2.NewFromConfig(c.cfg, func(o *ec2.Options) {
+			o.Region = region
+			o.Retryer = retryer
+			o.APIOptions = append(o.APIOptions, rateLimit)
+		}),
+		s3: s3.NewFromConfig(c.cfg, func(o *s3.Options) {
+			o.Region = region
+			o.Retryer = retryer
+			o.APIOptions = append(o.APIOptions, rateLimit)
+		}),
+		rds: rds.NewFromConfig(c.cfg, func(o *rds.Options) {
+			o.Region = region
+			o.Retryer = retryer
+			o.APIOptions = append(o.APIOptions, rateLimit)
+		}),
+		lambda: lambda.NewFromConfig(c.cfg, func(o *lambda.Options) {
+			o.Region = region
+			o.Retryer = retryer
+			o.APIOptions = append(o.APIOptions, rateLimit)
+		}),
+		iam: iam.NewFromConfig(c.cfg, func(o *iam.Options) {
+			o.Region = region
+			o.Retryer = retryer
+			o.APIOptions = append(o.APIOptions, globalRateLimit)
+		}),
+	}
+	c.clients[region] = clients
+	return clients
+}
+
+// newSTSClient creates an STS client with the policy's retries and rate limit
+func newSTSClient(cfg aws.Config, policy *APIPolicy) *sts.Client {
+	return sts.NewFromConfig(cfg, func(o *sts.Options) {
+		o.Retryer = awsRetryer(policy)
+		o.APIOptions = append(o.APIOptions, awsRateLimit(policy.Limiter("aws", awsLimiterRegion(sts.ServiceID, o.Region))))
+	})
+}
+
+// awsLimiterRegion returns the region whose token bucket paces calls to a
+// service. IAM, and STS when called on its global endpoint, are global
+// services and share one bucket across regions.
+func awsLimiterRegion(service, region string) string {
+	switch {
+	case service == iam.ServiceID:
+		return awsGlobalRegion
+	case service == sts.ServiceID && (region == "" || region == "aws-global"):
+		return awsGlobalRegion
+	}
+	return region
+}
+
+// resourceRegion determines the region a resource lives in from its state
+// attributes: the region in its ARN, an explicit region attribute, its
+// availability zone, and finally the region configured for the provider
+// alias that manages it. defaultRegion is used when none of these apply.
+func resourceRegion(resource terraform.ResourceInstance, aliasRegions map[string]string, defaultRegion string) string {
+	if arn, ok := resource.Attributes["arn"].(string); ok {
+		if region := regionFromARN(arn); region != "" {
+			return region
+		}
+	}
+
+	if region, ok := resource.Attributes["region"].(string); ok && region != "" {
+		return region
+	}
+
+	if az, ok := resource.Attributes["availability_zone"].(string); ok {
+		if m := awsRegionPattern.FindStringSubmatch(az); m != nil {
+			return m[1]
+		}
+	}
+
+	if alias := resource.ProviderAlias(); alias != "" {
+		if region, ok := aliasRegions[alias]; ok {
+			return region
+		}
+		log.Debugf("No region configured for provider alias %q of %s, using %s", alias, resource.Address, defaultRegion)
+	}
+
+	return defaultRegion
+}
+
+// regionFromARN returns the region field of an ARN, which is empty for
+// global services such as S3 buckets and IAM
+func regionFromARN(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[3]
+}
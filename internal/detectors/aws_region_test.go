@@ -0,0 +1,96 @@
+package detectors
+
+import (
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestResourceRegion(t *testing.T) {
+	const (
+		defaultProvider = `provider["registry.terraform.io/hashicorp/aws"]`
+		westProvider    = `provider["registry.terraform.io/hashicorp/aws"].west`
+		otherProvider   = `provider["registry.terraform.io/hashicorp/aws"].other`
+	)
+	aliasRegions := map[string]string{"west": "us-west-2"}
+
+	tests := []struct {
+		name     string
+		provider string
+		attrs    map[string]interface{}
+		want     string
+	}{
+		{"arn", westProvider, map[string]interface{}{"arn": "arn:aws:lambda:eu-west-1:123456789012:function:f"}, "eu-west-1"},
+		{"global arn falls through", defaultProvider, map[string]interface{}{"arn": "arn:aws:s3:::bucket", "region": "eu-central-1"}, "eu-central-1"},
+		{"region attribute", westProvider, map[string]interface{}{"region": "ap-south-1"}, "ap-south-1"},
+		{"availability zone", defaultProvider, map[string]interface{}{"availability_zone": "eu-west-1b"}, "eu-west-1"},
+		{"local zone", defaultProvider, map[string]interface{}{"availability_zone": "us-west-2-lax-1a"}, "us-west-2"},
+		{"govcloud zone", defaultProvider, map[string]interface{}{"availability_zone": "us-gov-west-1a"}, "us-gov-west-1"},
+		{"provider alias", westProvider, map[string]interface{}{"id": "sg-1"}, "us-west-2"},
+		{"unknown alias", otherProvider, map[string]interface{}{"id": "sg-1"}, "us-east-1"},
+		{"default provider", defaultProvider, map[string]interface{}{"id": "sg-1"}, "us-east-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := terraform.ResourceInstance{Address: "aws_x.y", Provider: tt.provider, Attributes: tt.attrs}
+			if got := resourceRegion(resource, aliasRegions, "us-east-1"); got != tt.want {
+				t.Errorf("resourceRegion() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegionFromARN(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{"arn:aws:ec2:us-east-1:123456789012:instance/i-1", "us-east-1"},
+		{"arn:aws:iam::123456789012:role/admin", ""},
+		{"arn:aws:s3:::bucket", ""},
+		{"i-1234", ""},
+	}
+
+	for _, tt := range tests {
+		if got := regionFromARN(tt.arn); got != tt.want {
+			t.Errorf("regionFromARN(%q) = %q, want %q", tt.arn, got, tt.want)
+		}
+	}
+}
+
+func TestAWSLimiterRegion(t *testing.T) {
+	tests := []struct {
+		service string
+		region  string
+		want    string
+	}{
+		{"EC2", "eu-west-1", "eu-west-1"},
+		{"IAM", "eu-west-1", "global"},
+		{"IAM", "us-east-1", "global"},
+		{"STS", "eu-west-1", "eu-west-1"},
+		{"STS", "aws-global", "global"},
+		{"STS", "", "global"},
+	}
+
+	for _, tt := range tests {
+		if got := awsLimiterRegion(tt.service, tt.region); got != tt.want {
+			t.Errorf("awsLimiterRegion(%s, %q) = %q, want %q", tt.service, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestAWSRegionClientsShareGlobalBucket(t *testing.T) {
+	policy := NewAPIPolicy(APIPolicyConfig{})
+	newAWSRegionClients(aws.Config{}, []string{"us-east-1", "eu-west-1"}, policy)
+
+	for _, key := range []string{"aws/us-east-1", "aws/eu-west-1", "aws/global"} {
+		if _, ok := policy.buckets[key]; !ok {
+			t.Errorf("no token bucket for %s", key)
+		}
+	}
+	if len(policy.buckets) != 3 {
+		t.Errorf("created %d token buckets, want 3", len(policy.buckets))
+	}
+}
@@ -0,0 +1,63 @@
+package detectors
+
+import (
+	"github.com/MeowTux/drift-detector/internal/terraform"
+)
+
+// relatedParentAttrs lists resource types that configure part of another
+// resource, with the attribute holding the ID of the resource they belong to
+var relatedParentAttrs = map[string]string{
+	"aws_security_group_rule":             "security_group_id",
+	"aws_vpc_security_group_ingress_rule": "security_group_id",
+	"aws_vpc_security_group_egress_rule":  "security_group_id",
+	"aws_iam_role_policy":                 "role",
+	"aws_iam_role_policy_attachment":      "role",
+	"aws_iam_user_policy":                 "user",
+	"aws_iam_user_policy_attachment":      "user",
+	"aws_iam_group_policy":                "group",
+	"aws_iam_group_policy_attachment":     "group",
+	"aws_route":                           "route_table_id",
+	"aws_network_acl_rule":                "network_acl_id",
+}
+
+// relatedParentListAttrs lists resource types that configure part of many
+// resources, with the attributes listing them. The attribute name prefixes
+// the parent ID, since the lists name different kinds of resources.
+var relatedParentListAttrs = map[string][]string{
+	"aws_iam_policy_attachment": {"roles", "users", "groups"},
+}
+
+// relatedResources indexes standalone resources of a state by the resource
+// they belong to, so that a check can merge them into the expected state
+type relatedResources map[string][]terraform.ResourceInstance
+
+// indexRelated collects the related resources among a state's resources
+func indexRelated(resources []terraform.ResourceInstance) relatedResources {
+	related := make(relatedResources)
+	for _, resource := range resources {
+		for _, attr := range relatedParentListAttrs[resource.Type] {
+			for _, parentID := range stringListAttr(resource.Attributes, attr) {
+				key := resource.Type + "/" + attr + "/" + parentID
+				related[key] = append(related[key], resource)
+			}
+		}
+
+		attr, ok := relatedParentAttrs[resource.Type]
+		if _, isBucketConfig := s3ConfigChecks[resource.Type]; isBucketConfig {
+			attr, ok = "bucket", true
+		}
+		if !ok {
+			continue
+		}
+		if parentID := stringAttr(resource.Attributes, attr); parentID != "" {
+			key := resource.Type + "/" + parentID
+			related[key] = append(related[key], resource)
+		}
+	}
+	return related
+}
+
+// of returns the related resources of a type that belong to parentID
+func (r relatedResources) of(resourceType, parentID string) []terraform.ResourceInstance {
+	return r[resourceType+"/"+parentID]
+}
@@ -0,0 +1,51 @@
+package detectors
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// awsRetryer applies the API policy's attempts and jittered backoff to SDK
+// calls. Throttling, 5xx and connection errors are retried.
+func awsRetryer(policy *APIPolicy) aws.Retryer {
+	return retry.NewStandard(func(o *retry.StandardOptions) {
+		o.MaxAttempts = policy.MaxAttempts()
+		o.MaxBackoff = policy.retry.MaxDelay
+		o.Backoff = retry.BackoffDelayerFunc(func(attempt int, err error) (time.Duration, error) {
+			return policy.Backoff(attempt), nil
+		})
+		// Retries are paced by the per-region token bucket instead of the
+		// SDK's retry quota, which would fail calls outright under heavy
+		// throttling
+		o.RateLimiter = unlimitedRetries{}
+	})
+}
+
+// awsRateLimit returns middleware that takes a token from the bucket before
+// every attempt of a call, including retries
+func awsRateLimit(bucket *TokenBucket) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("DriftDetectorRateLimit",
+			func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+				if err := bucket.Wait(ctx); err != nil {
+					return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+				}
+				return next.HandleFinalize(ctx, in)
+			}), middleware.After)
+	}
+}
+
+// unlimitedRetries is a retry.RateLimiter that never runs out of tokens
+type unlimitedRetries struct{}
+
+func (unlimitedRetries) GetToken(context.Context, uint) (func() error, error) {
+	return func() error { return nil }, nil
+}
+
+func (unlimitedRetries) AddTokens(uint) error {
+	return nil
+}
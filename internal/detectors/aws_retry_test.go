@@ -0,0 +1,106 @@
+package detectors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const testCallerIdentity = `<GetCallerIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <GetCallerIdentityResult><Account>111122223333</Account></GetCallerIdentityResult>
+</GetCallerIdentityResponse>`
+
+const testThrottled = `<ErrorResponse><Error><Type>Sender</Type><Code>Throttling</Code><Message>Rate exceeded</Message></Error></ErrorResponse>`
+
+// newRetryTestClient returns an STS client using the policy's retryer and
+// rate limit against a server that throttles the first failures calls
+func newRetryTestClient(t *testing.T, policy *APIPolicy, failures int32) (*sts.Client, *int32) {
+	t.Helper()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/xml")
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(testThrottled))
+			return
+		}
+		w.Write([]byte(testCallerIdentity))
+	}))
+	t.Cleanup(srv.Close)
+
+	client := sts.NewFromConfig(aws.Config{
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("test", "test", ""),
+		BaseEndpoint: aws.String(srv.URL),
+		Retryer:      func() aws.Retryer { return awsRetryer(policy) },
+	}, func(o *sts.Options) {
+		o.APIOptions = append(o.APIOptions, awsRateLimit(policy.Limiter("aws", "us-east-1")))
+	})
+	return client, &calls
+}
+
+func TestAWSRetryerRetriesThrottling(t *testing.T) {
+	policy := NewAPIPolicy(APIPolicyConfig{Retry: RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	}})
+	client, calls := newRetryTestClient(t, policy, 3)
+
+	if _, err := client.GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{}); err != nil {
+		t.Fatalf("GetCallerIdentity() = %v, want success on the fourth attempt", err)
+	}
+	if *calls != 4 {
+		t.Errorf("made %d calls, want 4", *calls)
+	}
+}
+
+func TestAWSRetryerGivesUp(t *testing.T) {
+	policy := NewAPIPolicy(APIPolicyConfig{Retry: RetryPolicy{
+		MaxAttempts: 2,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	}})
+	client, calls := newRetryTestClient(t, policy, 100)
+
+	_, err := client.GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{})
+	if err == nil {
+		t.Fatal("GetCallerIdentity() succeeded, want a throttling error")
+	}
+	if class := ClassifyAWSError(err); class != drift.ErrorClassThrottled {
+		t.Errorf("ClassifyAWSError() = %q, want throttled", class)
+	}
+	if *calls != 2 {
+		t.Errorf("made %d calls, want 2", *calls)
+	}
+}
+
+func TestAWSRateLimitPacesAttempts(t *testing.T) {
+	policy := NewAPIPolicy(APIPolicyConfig{
+		Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		RateLimits: map[string]ProviderRateLimit{
+			"aws": {RateLimit: RateLimit{RequestsPerSecond: 20, Burst: 1}},
+		},
+	})
+	client, calls := newRetryTestClient(t, policy, 2)
+
+	// Three attempts with a burst of one wait for two tokens at 20/s
+	start := time.Now()
+	if _, err := client.GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("3 attempts took %v, want the rate limit to apply to retries", elapsed)
+	}
+	if *calls != 3 {
+		t.Errorf("made %d calls, want 3", *calls)
+	}
+}
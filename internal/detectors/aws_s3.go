@@ -0,0 +1,480 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3ConfigCheck compares one split-out bucket configuration resource with
+// the live bucket
+type s3ConfigCheck func(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error)
+
+// s3ConfigChecks are the bucket configuration resources of AWS provider v4
+// and later, which carry the bucket name in their bucket attribute
+var s3ConfigChecks = map[string]s3ConfigCheck{
+	"aws_s3_bucket_versioning":                           checkS3Versioning,
+	"aws_s3_bucket_server_side_encryption_configuration": checkS3Encryption,
+	"aws_s3_bucket_public_access_block":                  checkS3PublicAccessBlock,
+	"aws_s3_bucket_policy":                               checkS3Policy,
+	"aws_s3_bucket_acl":                                  checkS3ACL,
+	"aws_s3_bucket_ownership_controls":                   checkS3OwnershipControls,
+	"aws_s3_bucket_lifecycle_configuration":              checkS3Lifecycle,
+	"aws_s3_bucket_logging":                              checkS3Logging,
+	"aws_s3_bucket_cors_configuration":                   checkS3CORS,
+	"aws_s3_bucket_replication_configuration":            checkS3Replication,
+	"aws_s3_bucket_object_lock_configuration":            checkS3ObjectLock,
+}
+
+// s3Missing reports whether err says a bucket has no configuration of the
+// requested kind, which S3 signals with a per-kind error code
+func s3Missing(err error, code string) bool {
+	return err != nil && awsErrorCode(err) == code
+}
+
+func (d *AWSDetector) checkS3Bucket(ctx context.Context, clients *awsClients, related relatedResources, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	bucketName, ok := resource.Attributes["bucket"].(string)
+	if !ok {
+		return nil, fmt.Errorf("bucket name not found")
+	}
+
+	var changes changeSet
+
+	// Check bucket versioning
+	versioning, err := clients.s3.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{
+		Bucket: &bucketName,
+	})
+	if err != nil {
+		if !isAWSNotFound(err) {
+			// Access denied, throttling or network trouble says nothing
+			// about whether the bucket exists
+			return nil, fmt.Errorf("failed to get bucket versioning: %w", err)
+		}
+		return existenceDrift(resource), nil
+	}
+
+	// The inline versioning and encryption attributes are computed when
+	// split-out resources manage them, which are checked on their own
+	if len(related.of("aws_s3_bucket_versioning", bucketName)) == 0 {
+		expectedVersioning := firstBlock(resource.Attributes, "versioning")
+		if expectedVersioning != nil {
+			enabled, _ := expectedVersioning["enabled"].(bool)
+			if enabled && versioning.Status != "Enabled" {
+				changes.add("versioning", "Enabled", string(versioning.Status))
+			}
+		}
+	}
+
+	if len(related.of("aws_s3_bucket_server_side_encryption_configuration", bucketName)) == 0 {
+		// Check encryption
+		encryption, err := clients.s3.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{
+			Bucket: &bucketName,
+		})
+		if err != nil && !s3Missing(err, "ServerSideEncryptionConfigurationNotFoundError") {
+			return nil, fmt.Errorf("failed to get bucket encryption: %w", err)
+		}
+
+		expectedEncryption := blockAttr(resource.Attributes, "server_side_encryption_configuration")
+		if len(expectedEncryption) > 0 && err != nil {
+			changes.add("encryption", "enabled", "disabled")
+		} else if len(expectedEncryption) == 0 && encryption != nil {
+			changes.add("encryption", "disabled", "enabled")
+		}
+	}
+
+	// Check tags
+	if _, ok := resource.Attributes["tags_all"]; ok || resource.Attributes["tags"] != nil {
+		tagging, err := clients.s3.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{
+			Bucket: &bucketName,
+		})
+		if err != nil && !s3Missing(err, "NoSuchTagSet") {
+			return nil, fmt.Errorf("failed to get bucket tags: %w", err)
+		}
+		actualTags := make(map[string]string)
+		if tagging != nil {
+			for _, tag := range tagging.TagSet {
+				actualTags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+			}
+		}
+		changes = append(changes, d.tagChanges(resource.Attributes, actualTags)...)
+	}
+
+	return changesDrift(resource, bucketName, changes), nil
+}
+
+// checkS3BucketConfig checks a split-out bucket configuration resource
+func (d *AWSDetector) checkS3BucketConfig(ctx context.Context, clients *awsClients, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	bucketName := stringAttr(resource.Attributes, "bucket")
+	if bucketName == "" {
+		return nil, fmt.Errorf("bucket name not found")
+	}
+
+	changes, err := s3ConfigChecks[resource.Type](ctx, clients.s3, bucketName, resource.Attributes)
+	if err != nil {
+		if isAWSNotFound(err) {
+			return existenceDrift(resource), nil
+		}
+		return nil, err
+	}
+
+	return changesDrift(resource, bucketName, changes), nil
+}
+
+func checkS3Versioning(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error) {
+	out, err := client.GetBucketVersioning(ctx, &s3.GetBucketVersioningInput{Bucket: &bucket})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bucket versioning: %w", err)
+	}
+
+	var changes changeSet
+	expected := firstBlock(attrs, "versioning_configuration")
+
+	// A bucket that never had versioning enabled has no status
+	status := string(out.Status)
+	if status == "" {
+		status = "Disabled"
+	}
+	changes.compareString("versioning_configuration.status", expected["status"], status)
+	if mfaDelete := stringAttr(expected, "mfa_delete"); mfaDelete != "" {
+		actual := string(out.MFADelete)
+		if actual == "" {
+			actual = "Disabled"
+		}
+		changes.compareString("versioning_configuration.mfa_delete", mfaDelete, actual)
+	}
+	return changes, nil
+}
+
+func checkS3Encryption(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error) {
+	out, err := client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: &bucket})
+	if err != nil && !s3Missing(err, "ServerSideEncryptionConfigurationNotFoundError") {
+		return nil, fmt.Errorf("failed to get bucket encryption: %w", err)
+	}
+
+	var algorithm, keyID string
+	var bucketKey bool
+	if out != nil && out.ServerSideEncryptionConfiguration != nil && len(out.ServerSideEncryptionConfiguration.Rules) > 0 {
+		rule := out.ServerSideEncryptionConfiguration.Rules[0]
+		if rule.ApplyServerSideEncryptionByDefault != nil {
+			algorithm = string(rule.ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+			keyID = aws.ToString(rule.ApplyServerSideEncryptionByDefault.KMSMasterKeyID)
+		}
+		bucketKey = aws.ToBool(rule.BucketKeyEnabled)
+	}
+
+	var changes changeSet
+	rule := firstBlock(attrs, "rule")
+	defaults := firstBlock(rule, "apply_server_side_encryption_by_default")
+	changes.compareString("rule.apply_server_side_encryption_by_default.sse_algorithm", defaults["sse_algorithm"], algorithm)
+	changes.compareString("rule.apply_server_side_encryption_by_default.kms_master_key_id", defaults["kms_master_key_id"], keyID)
+	changes.compareBool("rule.bucket_key_enabled", rule["bucket_key_enabled"], bucketKey)
+	return changes, nil
+}
+
+func checkS3PublicAccessBlock(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error) {
+	out, err := client.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: &bucket})
+	if err != nil && !s3Missing(err, "NoSuchPublicAccessBlockConfiguration") {
+		return nil, fmt.Errorf("failed to get public access block: %w", err)
+	}
+
+	// A removed public access block blocks nothing
+	config := &types.PublicAccessBlockConfiguration{}
+	if out != nil && out.PublicAccessBlockConfiguration != nil {
+		config = out.PublicAccessBlockConfiguration
+	}
+
+	var changes changeSet
+	changes.compareBool("block_public_acls", attrs["block_public_acls"], aws.ToBool(config.BlockPublicAcls))
+	changes.compareBool("block_public_policy", attrs["block_public_policy"], aws.ToBool(config.BlockPublicPolicy))
+	changes.compareBool("ignore_public_acls", attrs["ignore_public_acls"], aws.ToBool(config.IgnorePublicAcls))
+	changes.compareBool("restrict_public_buckets", attrs["restrict_public_buckets"], aws.ToBool(config.RestrictPublicBuckets))
+	return changes, nil
+}
+
+func checkS3Policy(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error) {
+	out, err := client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{Bucket: &bucket})
+	if err != nil && !s3Missing(err, "NoSuchBucketPolicy") {
+		return nil, fmt.Errorf("failed to get bucket policy: %w", err)
+	}
+
+	var actual string
+	if out != nil {
+		actual = aws.ToString(out.Policy)
+	}
+
+	return diffPolicy("policy", stringAttr(attrs, "policy"), actual), nil
+}
+
+// s3Grant describes a grant as "permission grantee"
+func s3Grant(permission, granteeType, id, uri, email string) string {
+	grantee := id
+	if uri != "" {
+		grantee = uri
+	} else if email != "" {
+		grantee = email
+	}
+	return fmt.Sprintf("%s %s:%s", permission, granteeType, grantee)
+}
+
+func checkS3ACL(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error) {
+	out, err := client.GetBucketAcl(ctx, &s3.GetBucketAclInput{Bucket: &bucket})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bucket ACL: %w", err)
+	}
+
+	var changes changeSet
+
+	policy := firstBlock(attrs, "access_control_policy")
+	if policy == nil {
+		return changes, nil
+	}
+
+	var expected []interface{}
+	for _, grant := range blockAttr(policy, "grant") {
+		grantee := firstBlock(grant, "grantee")
+		expected = append(expected, s3Grant(stringAttr(grant, "permission"), stringAttr(grantee, "type"),
+			stringAttr(grantee, "id"), stringAttr(grantee, "uri"), stringAttr(grantee, "email_address")))
+	}
+
+	var actual []string
+	for _, grant := range out.Grants {
+		if grant.Grantee == nil {
+			continue
+		}
+		grantee := grant.Grantee
+		actual = append(actual, s3Grant(string(grant.Permission), string(grantee.Type),
+			aws.ToString(grantee.ID), aws.ToString(grantee.URI), aws.ToString(grantee.EmailAddress)))
+	}
+
+	changes.compareStrings("access_control_policy.grant", expected, actual)
+	return changes, nil
+}
+
+func checkS3OwnershipControls(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error) {
+	out, err := client.GetBucketOwnershipControls(ctx, &s3.GetBucketOwnershipControlsInput{Bucket: &bucket})
+	if err != nil && !s3Missing(err, "OwnershipControlsNotFoundError") {
+		return nil, fmt.Errorf("failed to get bucket ownership controls: %w", err)
+	}
+
+	var ownership string
+	if out != nil && out.OwnershipControls != nil && len(out.OwnershipControls.Rules) > 0 {
+		ownership = string(out.OwnershipControls.Rules[0].ObjectOwnership)
+	}
+
+	var changes changeSet
+	changes.compareString("rule.object_ownership", firstBlock(attrs, "rule")["object_ownership"], ownership)
+	return changes, nil
+}
+
+func checkS3Lifecycle(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error) {
+	out, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{Bucket: &bucket})
+	if err != nil && !s3Missing(err, "NoSuchLifecycleConfiguration") {
+		return nil, fmt.Errorf("failed to get bucket lifecycle configuration: %w", err)
+	}
+
+	expected := make(map[string]map[string]interface{})
+	for _, rule := range blockAttr(attrs, "rule") {
+		fields := map[string]interface{}{"status": rule["status"]}
+		if v, ok := firstBlock(rule, "expiration")["days"]; ok {
+			fields["expiration.days"] = v
+		}
+		if v, ok := firstBlock(rule, "noncurrent_version_expiration")["noncurrent_days"]; ok {
+			fields["noncurrent_version_expiration.noncurrent_days"] = v
+		}
+		if v, ok := firstBlock(rule, "abort_incomplete_multipart_upload")["days_after_initiation"]; ok {
+			fields["abort_incomplete_multipart_upload.days_after_initiation"] = v
+		}
+		expected[stringAttr(rule, "id")] = fields
+	}
+
+	actual := make(map[string]map[string]interface{})
+	if out != nil {
+		for _, rule := range out.Rules {
+			fields := map[string]interface{}{"status": string(rule.Status)}
+			if rule.Expiration != nil {
+				fields["expiration.days"] = int(aws.ToInt32(rule.Expiration.Days))
+			}
+			if rule.NoncurrentVersionExpiration != nil {
+				fields["noncurrent_version_expiration.noncurrent_days"] = int(aws.ToInt32(rule.NoncurrentVersionExpiration.NoncurrentDays))
+			}
+			if rule.AbortIncompleteMultipartUpload != nil {
+				fields["abort_incomplete_multipart_upload.days_after_initiation"] = int(aws.ToInt32(rule.AbortIncompleteMultipartUpload.DaysAfterInitiation))
+			}
+			actual[aws.ToString(rule.ID)] = fields
+		}
+	}
+
+	return diffRules("rule", expected, actual), nil
+}
+
+func checkS3Logging(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error) {
+	out, err := client.GetBucketLogging(ctx, &s3.GetBucketLoggingInput{Bucket: &bucket})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bucket logging: %w", err)
+	}
+
+	var targetBucket, targetPrefix string
+	if out.LoggingEnabled != nil {
+		targetBucket = aws.ToString(out.LoggingEnabled.TargetBucket)
+		targetPrefix = aws.ToString(out.LoggingEnabled.TargetPrefix)
+	}
+
+	var changes changeSet
+	changes.compareString("target_bucket", attrs["target_bucket"], targetBucket)
+	changes.compareString("target_prefix", attrs["target_prefix"], targetPrefix)
+	return changes, nil
+}
+
+// corsRule summarizes a CORS rule for comparison as a set
+func corsRule(methods, origins, headers, expose []string, maxAge int) string {
+	for _, list := range [][]string{methods, origins, headers, expose} {
+		sort.Strings(list)
+	}
+	return fmt.Sprintf("methods=%s origins=%s headers=%s expose=%s max_age=%d",
+		strings.Join(methods, ","), strings.Join(origins, ","), strings.Join(headers, ","), strings.Join(expose, ","), maxAge)
+}
+
+func checkS3CORS(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error) {
+	out, err := client.GetBucketCors(ctx, &s3.GetBucketCorsInput{Bucket: &bucket})
+	if err != nil && !s3Missing(err, "NoSuchCORSConfiguration") {
+		return nil, fmt.Errorf("failed to get bucket CORS configuration: %w", err)
+	}
+
+	var expected []interface{}
+	for _, rule := range blockAttr(attrs, "cors_rule") {
+		expected = append(expected, corsRule(stringListAttr(rule, "allowed_methods"), stringListAttr(rule, "allowed_origins"),
+			stringListAttr(rule, "allowed_headers"), stringListAttr(rule, "expose_headers"), intAttr(rule, "max_age_seconds")))
+	}
+
+	var actual []string
+	if out != nil {
+		for _, rule := range out.CORSRules {
+			actual = append(actual, corsRule(rule.AllowedMethods, rule.AllowedOrigins,
+				rule.AllowedHeaders, rule.ExposeHeaders, int(aws.ToInt32(rule.MaxAgeSeconds))))
+		}
+	}
+
+	var changes changeSet
+	changes.compareStrings("cors_rule", expected, actual)
+	return changes, nil
+}
+
+func checkS3Replication(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error) {
+	out, err := client.GetBucketReplication(ctx, &s3.GetBucketReplicationInput{Bucket: &bucket})
+	if err != nil && !s3Missing(err, "ReplicationConfigurationNotFoundError") {
+		return nil, fmt.Errorf("failed to get bucket replication: %w", err)
+	}
+
+	var role string
+	actual := make(map[string]map[string]interface{})
+	if out != nil && out.ReplicationConfiguration != nil {
+		role = aws.ToString(out.ReplicationConfiguration.Role)
+		for _, rule := range out.ReplicationConfiguration.Rules {
+			fields := map[string]interface{}{"status": string(rule.Status)}
+			if rule.Destination != nil {
+				fields["destination.bucket"] = aws.ToString(rule.Destination.Bucket)
+				fields["destination.storage_class"] = string(rule.Destination.StorageClass)
+			}
+			actual[aws.ToString(rule.ID)] = fields
+		}
+	}
+
+	expected := make(map[string]map[string]interface{})
+	for _, rule := range blockAttr(attrs, "rule") {
+		destination := firstBlock(rule, "destination")
+		fields := map[string]interface{}{
+			"status":             rule["status"],
+			"destination.bucket": destination["bucket"],
+		}
+		// The storage class defaults to the source object's
+		if class := stringAttr(destination, "storage_class"); class != "" {
+			fields["destination.storage_class"] = class
+		}
+		expected[stringAttr(rule, "id")] = fields
+	}
+
+	var changes changeSet
+	changes.compareString("role", attrs["role"], role)
+	changes = append(changes, diffRules("rule", expected, actual)...)
+	return changes, nil
+}
+
+func checkS3ObjectLock(ctx context.Context, client *s3.Client, bucket string, attrs map[string]interface{}) (changeSet, error) {
+	out, err := client.GetObjectLockConfiguration(ctx, &s3.GetObjectLockConfigurationInput{Bucket: &bucket})
+	if err != nil && !s3Missing(err, "ObjectLockConfigurationNotFoundError") {
+		return nil, fmt.Errorf("failed to get object lock configuration: %w", err)
+	}
+
+	var enabled, mode string
+	var days, years int
+	if out != nil && out.ObjectLockConfiguration != nil {
+		config := out.ObjectLockConfiguration
+		enabled = string(config.ObjectLockEnabled)
+		if config.Rule != nil && config.Rule.DefaultRetention != nil {
+			retention := config.Rule.DefaultRetention
+			mode = string(retention.Mode)
+			days = int(aws.ToInt32(retention.Days))
+			years = int(aws.ToInt32(retention.Years))
+		}
+	}
+
+	var changes changeSet
+	retention := firstBlock(firstBlock(attrs, "rule"), "default_retention")
+	changes.compareString("object_lock_enabled", attrs["object_lock_enabled"], enabled)
+	changes.compareString("rule.default_retention.mode", retention["mode"], mode)
+	changes.compareInt("rule.default_retention.days", retention["days"], days)
+	changes.compareInt("rule.default_retention.years", retention["years"], years)
+	return changes, nil
+}
+
+// diffRules compares rules keyed by ID, such as lifecycle or replication
+// rules, reporting rules added or removed outside Terraform and the fields
+// that differ. Only fields recorded in state are compared.
+func diffRules(field string, expected, actual map[string]map[string]interface{}) changeSet {
+	ids := make([]string, 0, len(expected)+len(actual))
+	for id := range expected {
+		ids = append(ids, id)
+	}
+	for id := range actual {
+		if _, ok := expected[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var changes changeSet
+	for _, id := range ids {
+		ruleField := fmt.Sprintf("%s[%s]", field, id)
+		want, inState := expected[id]
+		got, inCloud := actual[id]
+		switch {
+		case !inState:
+			changes.add(ruleField, "absent", "present")
+		case !inCloud:
+			changes.add(ruleField, "present", "absent")
+		default:
+			names := make([]string, 0, len(want))
+			for name := range want {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if want[name] == nil {
+					continue
+				}
+				// State numbers are float64, API numbers int
+				if fmt.Sprint(want[name]) != fmt.Sprint(got[name]) {
+					changes.add(ruleField+"."+name, want[name], got[name])
+				}
+			}
+		}
+	}
+	return changes
+}
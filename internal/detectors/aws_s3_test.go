@@ -0,0 +1,182 @@
+package detectors
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+func TestDiffRules(t *testing.T) {
+	expected := map[string]map[string]interface{}{
+		"expire-logs": {"status": "Enabled", "expiration.days": float64(30)},
+		"abort":       {"status": "Enabled", "abort_incomplete_multipart_upload.days_after_initiation": float64(7)},
+		"archive":     {"status": "Enabled", "expiration.days": nil},
+	}
+	actual := map[string]map[string]interface{}{
+		"expire-logs": {"status": "Enabled", "expiration.days": 30},
+		"abort":       {"status": "Disabled", "abort_incomplete_multipart_upload.days_after_initiation": 7},
+		"manual":      {"status": "Enabled"},
+	}
+
+	want := changeSet{
+		{Field: "rule[abort].status", Expected: "Enabled", Actual: "Disabled"},
+		{Field: "rule[archive]", Expected: "present", Actual: "absent"},
+		{Field: "rule[manual]", Expected: "absent", Actual: "present"},
+	}
+	if got := diffRules("rule", expected, actual); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffRules() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCORSRuleIgnoresOrder(t *testing.T) {
+	a := corsRule([]string{"PUT", "GET"}, []string{"https://b", "https://a"}, nil, nil, 300)
+	b := corsRule([]string{"GET", "PUT"}, []string{"https://a", "https://b"}, nil, nil, 300)
+	if a != b {
+		t.Errorf("corsRule() = %q and %q, want equal", a, b)
+	}
+	if c := corsRule([]string{"GET", "PUT"}, []string{"https://a", "https://b"}, nil, nil, 600); c == a {
+		t.Errorf("corsRule() with another max age = %q, want it to differ", c)
+	}
+}
+
+func TestS3Grant(t *testing.T) {
+	tests := []struct {
+		permission, granteeType, id, uri, email string
+		want                                    string
+	}{
+		{"FULL_CONTROL", "CanonicalUser", "abc123", "", "", "FULL_CONTROL CanonicalUser:abc123"},
+		{"READ", "Group", "", "http://acs.amazonaws.com/groups/global/AllUsers", "", "READ Group:http://acs.amazonaws.com/groups/global/AllUsers"},
+		{"WRITE", "AmazonCustomerByEmail", "", "", "ops@example.com", "WRITE AmazonCustomerByEmail:ops@example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := s3Grant(tt.permission, tt.granteeType, tt.id, tt.uri, tt.email); got != tt.want {
+			t.Errorf("s3Grant() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+// s3Stub serves a bucket with versioning suspended and no encryption, tags
+// or other configuration, recording the configuration kinds requested
+type s3Stub struct {
+	mu        sync.Mutex
+	requested []string
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	kinds := make([]string, 0, len(query))
+	for kind := range query {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	s.mu.Lock()
+	s.requested = append(s.requested, kinds...)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/xml")
+	switch {
+	case query.Has("versioning"):
+		w.Write([]byte(`<VersioningConfiguration><Status>Suspended</Status></VersioningConfiguration>`))
+	case query.Has("encryption"):
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<Error><Code>ServerSideEncryptionConfigurationNotFoundError</Code><Message>none</Message></Error>`))
+	case query.Has("tagging"):
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<Error><Code>NoSuchTagSet</Code><Message>none</Message></Error>`))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func TestCheckS3BucketSkipsSplitConfiguration(t *testing.T) {
+	bucket := terraform.ResourceInstance{
+		Address: "aws_s3_bucket.logs",
+		Type:    "aws_s3_bucket",
+		Name:    "logs",
+		Attributes: map[string]interface{}{
+			"bucket":     "logs",
+			"versioning": []interface{}{map[string]interface{}{"enabled": true}},
+			"server_side_encryption_configuration": []interface{}{map[string]interface{}{
+				"rule": []interface{}{map[string]interface{}{}},
+			}},
+		},
+	}
+	split := func(resourceType string) terraform.ResourceInstance {
+		return terraform.ResourceInstance{Type: resourceType, Attributes: map[string]interface{}{"bucket": "logs"}}
+	}
+
+	tests := []struct {
+		name          string
+		related       []terraform.ResourceInstance
+		wantFields    []string
+		wantRequested []string
+	}{
+		{
+			name:          "inline configuration",
+			wantFields:    []string{"versioning", "encryption"},
+			wantRequested: []string{"versioning", "encryption"},
+		},
+		{
+			name:          "split versioning",
+			related:       []terraform.ResourceInstance{split("aws_s3_bucket_versioning")},
+			wantFields:    []string{"encryption"},
+			wantRequested: []string{"versioning", "encryption"},
+		},
+		{
+			name: "split versioning and encryption",
+			related: []terraform.ResourceInstance{
+				split("aws_s3_bucket_versioning"),
+				split("aws_s3_bucket_server_side_encryption_configuration"),
+			},
+			wantRequested: []string{"versioning"},
+		},
+		{
+			name:          "split configuration of another bucket",
+			related:       []terraform.ResourceInstance{{Type: "aws_s3_bucket_versioning", Attributes: map[string]interface{}{"bucket": "other"}}},
+			wantFields:    []string{"versioning", "encryption"},
+			wantRequested: []string{"versioning", "encryption"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &s3Stub{}
+			server := httptest.NewServer(stub)
+			defer server.Close()
+
+			client := s3.NewFromConfig(aws.Config{
+				Region:       "us-east-1",
+				Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+				BaseEndpoint: aws.String(server.URL),
+			}, func(o *s3.Options) { o.UsePathStyle = true })
+
+			item, err := (&AWSDetector{}).checkS3Bucket(context.Background(), &awsClients{s3: client}, indexRelated(tt.related), bucket)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fields []string
+			if item != nil {
+				for _, change := range item.Changes {
+					fields = append(fields, change.Field)
+				}
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("changed fields = %q, want %q", fields, tt.wantFields)
+			}
+			if !reflect.DeepEqual(stub.requested, tt.wantRequested) {
+				t.Errorf("requested %q, want %q", stub.requested, tt.wantRequested)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package detectors
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// sgProtocolNames normalizes protocol numbers to the names EC2 returns
+var sgProtocolNames = map[string]string{
+	"6":    "tcp",
+	"17":   "udp",
+	"1":    "icmp",
+	"58":   "icmpv6",
+	"all":  "-1",
+	"-1":   "-1",
+	"tcp":  "tcp",
+	"udp":  "udp",
+	"icmp": "icmp",
+}
+
+// sgRule is a single normalized security group rule: one protocol and port
+// range for one source or destination
+type sgRule struct {
+	direction   string
+	protocol    string
+	fromPort    int
+	toPort      int
+	peer        string
+	description string
+}
+
+func newSGRule(direction, protocol string, fromPort, toPort int, peer, description string) sgRule {
+	protocol = strings.ToLower(protocol)
+	if name, ok := sgProtocolNames[protocol]; ok {
+		protocol = name
+	}
+	if protocol == "-1" {
+		fromPort, toPort = 0, 0
+	}
+	return sgRule{
+		direction:   direction,
+		protocol:    protocol,
+		fromPort:    fromPort,
+		toPort:      toPort,
+		peer:        peer,
+		description: description,
+	}
+}
+
+// key identifies the rule regardless of its description, e.g.
+// ingress[tcp 22-22 cidr:0.0.0.0/0]
+func (r sgRule) key() string {
+	ports := "all"
+	if r.protocol != "-1" {
+		ports = fmt.Sprintf("%d-%d", r.fromPort, r.toPort)
+	}
+	protocol := r.protocol
+	if protocol == "-1" {
+		protocol = "all"
+	}
+	return fmt.Sprintf("%s[%s %s %s]", r.direction, protocol, ports, r.peer)
+}
+
+// sgRuleSet is a set of rules keyed by sgRule.key
+type sgRuleSet map[string]sgRule
+
+func (s sgRuleSet) add(rule sgRule) {
+	if existing, ok := s[rule.key()]; ok && rule.description == "" {
+		rule.description = existing.description
+	}
+	s[rule.key()] = rule
+}
+
+// sgPeers lists the sources or destinations of a rule in state, one peer
+// per CIDR, prefix list or referenced group
+func sgPeers(groupID string, attrs map[string]interface{}) []string {
+	var peers []string
+	for _, cidr := range stringListAttr(attrs, "cidr_blocks") {
+		peers = append(peers, "cidr:"+cidr)
+	}
+	for _, cidr := range stringListAttr(attrs, "ipv6_cidr_blocks") {
+		peers = append(peers, "ipv6:"+cidr)
+	}
+	for _, id := range stringListAttr(attrs, "prefix_list_ids") {
+		peers = append(peers, "pl:"+id)
+	}
+	for _, group := range stringListAttr(attrs, "security_groups") {
+		peers = append(peers, sgPeer(group))
+	}
+	if id := stringAttr(attrs, "source_security_group_id"); id != "" {
+		peers = append(peers, sgPeer(id))
+	}
+	if boolAttr(attrs, "self") {
+		peers = append(peers, sgPeer(groupID))
+	}
+	return peers
+}
+
+// sgPeer names a referenced security group, dropping the owner account of
+// cross-account references ("123456789012/sg-1234")
+func sgPeer(group string) string {
+	if i := strings.LastIndex(group, "/"); i >= 0 {
+		group = group[i+1:]
+	}
+	return "sg:" + group
+}
+
+// expectedSGRules builds the rule set of a security group from its inline
+// ingress and egress blocks and the standalone rule resources attached to it
+func expectedSGRules(groupID string, resource terraform.ResourceInstance, related relatedResources) sgRuleSet {
+	rules := make(sgRuleSet)
+
+	for _, direction := range []string{"ingress", "egress"} {
+		for _, block := range blockAttr(resource.Attributes, direction) {
+			for _, peer := range sgPeers(groupID, block) {
+				rules.add(newSGRule(direction, stringAttr(block, "protocol"),
+					intAttr(block, "from_port"), intAttr(block, "to_port"), peer, stringAttr(block, "description")))
+			}
+		}
+	}
+
+	for _, rule := range related.of("aws_security_group_rule", groupID) {
+		attrs := rule.Attributes
+		for _, peer := range sgPeers(groupID, attrs) {
+			rules.add(newSGRule(stringAttr(attrs, "type"), stringAttr(attrs, "protocol"),
+				intAttr(attrs, "from_port"), intAttr(attrs, "to_port"), peer, stringAttr(attrs, "description")))
+		}
+	}
+
+	for direction, ruleType := range map[string]string{
+		"ingress": "aws_vpc_security_group_ingress_rule",
+		"egress":  "aws_vpc_security_group_egress_rule",
+	} {
+		for _, rule := range related.of(ruleType, groupID) {
+			attrs := rule.Attributes
+			var peer string
+			switch {
+			case stringAttr(attrs, "cidr_ipv4") != "":
+				peer = "cidr:" + stringAttr(attrs, "cidr_ipv4")
+			case stringAttr(attrs, "cidr_ipv6") != "":
+				peer = "ipv6:" + stringAttr(attrs, "cidr_ipv6")
+			case stringAttr(attrs, "prefix_list_id") != "":
+				peer = "pl:" + stringAttr(attrs, "prefix_list_id")
+			case stringAttr(attrs, "referenced_security_group_id") != "":
+				peer = sgPeer(stringAttr(attrs, "referenced_security_group_id"))
+			default:
+				continue
+			}
+			rules.add(newSGRule(direction, stringAttr(attrs, "ip_protocol"),
+				intAttr(attrs, "from_port"), intAttr(attrs, "to_port"), peer, stringAttr(attrs, "description")))
+		}
+	}
+
+	return rules
+}
+
+// actualSGRules expands the permissions EC2 returns into individual rules
+func actualSGRules(group types.SecurityGroup) sgRuleSet {
+	rules := make(sgRuleSet)
+
+	expand := func(direction string, permissions []types.IpPermission) {
+		for _, p := range permissions {
+			protocol := aws.ToString(p.IpProtocol)
+			from := int(aws.ToInt32(p.FromPort))
+			to := int(aws.ToInt32(p.ToPort))
+			for _, r := range p.IpRanges {
+				rules.add(newSGRule(direction, protocol, from, to, "cidr:"+aws.ToString(r.CidrIp), aws.ToString(r.Description)))
+			}
+			for _, r := range p.Ipv6Ranges {
+				rules.add(newSGRule(direction, protocol, from, to, "ipv6:"+aws.ToString(r.CidrIpv6), aws.ToString(r.Description)))
+			}
+			for _, r := range p.PrefixListIds {
+				rules.add(newSGRule(direction, protocol, from, to, "pl:"+aws.ToString(r.PrefixListId), aws.ToString(r.Description)))
+			}
+			for _, r := range p.UserIdGroupPairs {
+				rules.add(newSGRule(direction, protocol, from, to, sgPeer(aws.ToString(r.GroupId)), aws.ToString(r.Description)))
+			}
+		}
+	}
+	expand("ingress", group.IpPermissions)
+	expand("egress", group.IpPermissionsEgress)
+
+	return rules
+}
+
+// diffSGRules reports rules added outside Terraform, rules removed from the
+// group and changed descriptions, ordered by rule
+func diffSGRules(expected, actual sgRuleSet) []drift.Change {
+	keys := make(map[string]bool, len(expected)+len(actual))
+	for key := range expected {
+		keys[key] = true
+	}
+	for key := range actual {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var changes []drift.Change
+	for _, key := range sorted {
+		want, inState := expected[key]
+		got, inCloud := actual[key]
+		switch {
+		case !inState:
+			changes = append(changes, drift.Change{Field: key, Expected: "absent", Actual: "present"})
+		case !inCloud:
+			changes = append(changes, drift.Change{Field: key, Expected: "present", Actual: "absent"})
+		case want.description != got.description:
+			changes = append(changes, drift.Change{
+				Field:    key + ".description",
+				Expected: want.description,
+				Actual:   got.description,
+			})
+		}
+	}
+	return changes
+}
+
+// sgRuleSeverity rates a rule change: a port opened to the internet outside
+// Terraform is critical, other added or removed rules are high. Network ACL
+// entries share the field names and are rated high too. It returns "" for
+// changes that are not security group or network ACL rules.
+func sgRuleSeverity(change drift.Change) string {
+	ingress := strings.HasPrefix(change.Field, "ingress[")
+	if !ingress && !strings.HasPrefix(change.Field, "egress[") {
+		return ""
+	}
+	if strings.HasSuffix(change.Field, ".description") {
+		return ""
+	}
+	if ingress && change.Actual == "present" &&
+		(strings.HasSuffix(change.Field, " cidr:0.0.0.0/0]") || strings.HasSuffix(change.Field, " ipv6:::/0]")) {
+		return "critical"
+	}
+	return "high"
+}
+
+func (d *AWSDetector) checkSecurityGroup(index *awsResourceIndex, related relatedResources, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	sgID, ok := resource.Attributes["id"].(string)
+	if !ok {
+		return nil, fmt.Errorf("security group ID not found")
+	}
+	if index.securityGroupsErr != nil {
+		return nil, index.securityGroupsErr
+	}
+
+	sg, found := index.securityGroups[sgID]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	expected := expectedSGRules(sgID, resource, related)
+	actual := actualSGRules(sg)
+	changes := diffSGRules(expected, actual)
+
+	return changesDrift(resource, sgID, changes), nil
+}
@@ -0,0 +1,193 @@
+package detectors
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func sortedKeys(rules sgRuleSet) []string {
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestNewSGRuleNormalization(t *testing.T) {
+	tests := []struct {
+		name     string
+		protocol string
+		from, to int
+		want     string
+	}{
+		{"protocol name", "tcp", 22, 22, "ingress[tcp 22-22 cidr:10.0.0.0/8]"},
+		{"upper case", "TCP", 443, 443, "ingress[tcp 443-443 cidr:10.0.0.0/8]"},
+		{"tcp number", "6", 80, 80, "ingress[tcp 80-80 cidr:10.0.0.0/8]"},
+		{"udp number", "17", 53, 53, "ingress[udp 53-53 cidr:10.0.0.0/8]"},
+		{"icmp number", "1", 8, 0, "ingress[icmp 8-0 cidr:10.0.0.0/8]"},
+		{"all as -1 drops ports", "-1", 0, 65535, "ingress[all all cidr:10.0.0.0/8]"},
+		{"all by name", "all", 0, 0, "ingress[all all cidr:10.0.0.0/8]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := newSGRule("ingress", tt.protocol, tt.from, tt.to, "cidr:10.0.0.0/8", "")
+			if got := rule.key(); got != tt.want {
+				t.Errorf("key() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSGPeers(t *testing.T) {
+	attrs := map[string]interface{}{
+		"cidr_blocks":              []interface{}{"10.0.0.0/8", "0.0.0.0/0"},
+		"ipv6_cidr_blocks":         []interface{}{"::/0"},
+		"prefix_list_ids":          []interface{}{"pl-123"},
+		"security_groups":          []interface{}{"sg-peer", "111122223333/sg-cross"},
+		"source_security_group_id": "sg-source",
+		"self":                     true,
+	}
+	want := []string{
+		"cidr:0.0.0.0/0", "cidr:10.0.0.0/8", "ipv6:::/0", "pl:pl-123",
+		"sg:sg-cross", "sg:sg-peer", "sg:sg-source", "sg:sg-self",
+	}
+	if got := sgPeers("sg-self", attrs); !reflect.DeepEqual(got, want) {
+		t.Errorf("sgPeers() = %v, want %v", got, want)
+	}
+}
+
+func TestExpectedSGRulesMergesStandaloneRules(t *testing.T) {
+	group := terraform.ResourceInstance{
+		Type: "aws_security_group",
+		Attributes: map[string]interface{}{
+			"id": "sg-1",
+			"ingress": []interface{}{
+				map[string]interface{}{
+					"protocol": "tcp", "from_port": float64(443), "to_port": float64(443),
+					"cidr_blocks": []interface{}{"0.0.0.0/0"}, "description": "https",
+				},
+			},
+			"egress": []interface{}{
+				map[string]interface{}{
+					"protocol": "-1", "from_port": float64(0), "to_port": float64(0),
+					"cidr_blocks": []interface{}{"0.0.0.0/0"},
+				},
+			},
+		},
+	}
+	related := indexRelated([]terraform.ResourceInstance{
+		{Type: "aws_security_group_rule", Attributes: map[string]interface{}{
+			"security_group_id": "sg-1", "type": "ingress", "protocol": "6",
+			"from_port": float64(22), "to_port": float64(22), "self": true,
+		}},
+		{Type: "aws_vpc_security_group_ingress_rule", Attributes: map[string]interface{}{
+			"security_group_id": "sg-1", "ip_protocol": "tcp",
+			"from_port": float64(5432), "to_port": float64(5432),
+			"referenced_security_group_id": "111122223333/sg-app", "description": "db",
+		}},
+		{Type: "aws_vpc_security_group_egress_rule", Attributes: map[string]interface{}{
+			"security_group_id": "sg-1", "ip_protocol": "-1", "cidr_ipv6": "::/0",
+		}},
+		// A rule of another group is left out
+		{Type: "aws_security_group_rule", Attributes: map[string]interface{}{
+			"security_group_id": "sg-2", "type": "ingress", "protocol": "tcp",
+			"from_port": float64(80), "to_port": float64(80), "cidr_blocks": []interface{}{"0.0.0.0/0"},
+		}},
+	})
+
+	rules := expectedSGRules("sg-1", group, related)
+	want := []string{
+		"egress[all all cidr:0.0.0.0/0]",
+		"egress[all all ipv6:::/0]",
+		"ingress[tcp 22-22 sg:sg-1]",
+		"ingress[tcp 443-443 cidr:0.0.0.0/0]",
+		"ingress[tcp 5432-5432 sg:sg-app]",
+	}
+	if got := sortedKeys(rules); !reflect.DeepEqual(got, want) {
+		t.Errorf("rules = %v, want %v", got, want)
+	}
+	if d := rules["ingress[tcp 5432-5432 sg:sg-app]"].description; d != "db" {
+		t.Errorf("description = %q, want db", d)
+	}
+}
+
+func TestActualSGRulesMatchesState(t *testing.T) {
+	group := types.SecurityGroup{
+		IpPermissions: []types.IpPermission{{
+			IpProtocol: aws.String("tcp"), FromPort: aws.Int32(443), ToPort: aws.Int32(443),
+			IpRanges: []types.IpRange{{CidrIp: aws.String("0.0.0.0/0"), Description: aws.String("https")}},
+		}},
+		IpPermissionsEgress: []types.IpPermission{{
+			// EC2 reports -1 ports for all protocols
+			IpProtocol: aws.String("-1"), FromPort: aws.Int32(-1), ToPort: aws.Int32(-1),
+			IpRanges:         []types.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+			UserIdGroupPairs: []types.UserIdGroupPair{{GroupId: aws.String("sg-peer"), UserId: aws.String("111122223333")}},
+		}},
+	}
+	expected := make(sgRuleSet)
+	expected.add(newSGRule("ingress", "6", 443, 443, "cidr:0.0.0.0/0", "https"))
+	expected.add(newSGRule("egress", "all", 0, 0, "cidr:0.0.0.0/0", ""))
+	expected.add(newSGRule("egress", "-1", 0, 0, sgPeer("111122223333/sg-peer"), ""))
+
+	if changes := diffSGRules(expected, actualSGRules(group)); len(changes) != 0 {
+		t.Errorf("diffSGRules() = %+v, want no changes", changes)
+	}
+}
+
+func TestDiffSGRules(t *testing.T) {
+	expected := make(sgRuleSet)
+	expected.add(newSGRule("ingress", "tcp", 443, 443, "cidr:10.0.0.0/8", "https"))
+	expected.add(newSGRule("ingress", "tcp", 8080, 8080, "sg:sg-lb", ""))
+
+	actual := make(sgRuleSet)
+	actual.add(newSGRule("ingress", "tcp", 443, 443, "cidr:10.0.0.0/8", "changed"))
+	actual.add(newSGRule("ingress", "tcp", 22, 22, "cidr:0.0.0.0/0", ""))
+
+	want := []drift.Change{
+		{Field: "ingress[tcp 22-22 cidr:0.0.0.0/0]", Expected: "absent", Actual: "present"},
+		{Field: "ingress[tcp 443-443 cidr:10.0.0.0/8].description", Expected: "https", Actual: "changed"},
+		{Field: "ingress[tcp 8080-8080 sg:sg-lb]", Expected: "present", Actual: "absent"},
+	}
+	if got := diffSGRules(expected, actual); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffSGRules() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSGRuleSeverity(t *testing.T) {
+	tests := []struct {
+		name   string
+		change drift.Change
+		want   string
+	}{
+		{"ingress opened to the internet", drift.Change{Field: "ingress[tcp 22-22 cidr:0.0.0.0/0]", Expected: "absent", Actual: "present"}, "critical"},
+		{"ingress opened to all IPv6", drift.Change{Field: "ingress[all all ipv6:::/0]", Expected: "absent", Actual: "present"}, "critical"},
+		{"internet rule removed", drift.Change{Field: "ingress[tcp 443-443 cidr:0.0.0.0/0]", Expected: "present", Actual: "absent"}, "high"},
+		{"egress to the internet", drift.Change{Field: "egress[all all cidr:0.0.0.0/0]", Expected: "absent", Actual: "present"}, "high"},
+		{"private ingress added", drift.Change{Field: "ingress[tcp 22-22 cidr:10.0.0.0/8]", Expected: "absent", Actual: "present"}, "high"},
+		{"description only", drift.Change{Field: "ingress[tcp 22-22 cidr:0.0.0.0/0].description", Expected: "a", Actual: "b"}, ""},
+		{"not a rule", drift.Change{Field: "tags.Name", Expected: "a", Actual: "b"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sgRuleSeverity(tt.change); got != tt.want {
+				t.Errorf("sgRuleSeverity() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The rule severity raises the item severity over the field tables
+	changes := []drift.Change{
+		{Field: "tags.Name", Expected: "a", Actual: "b"},
+		{Field: "ingress[tcp 3389-3389 cidr:0.0.0.0/0]", Expected: "absent", Actual: "present"},
+	}
+	if got := determineSeverity(changes); got != "critical" {
+		t.Errorf("determineSeverity() = %q, want critical", got)
+	}
+}
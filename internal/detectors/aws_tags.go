@@ -0,0 +1,107 @@
+package detectors
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// awsSystemTags matches tags AWS itself writes, which the Terraform provider
+// never manages
+const awsSystemTags = "aws:*"
+
+// tagFilter matches tag keys that are managed outside Terraform
+type tagFilter []*regexp.Regexp
+
+// newTagFilter compiles glob patterns of tag keys, where * matches any
+// sequence of characters including "/"
+func newTagFilter(patterns []string) tagFilter {
+	var filter tagFilter
+	for _, pattern := range append([]string{awsSystemTags}, patterns...) {
+		expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+		filter = append(filter, regexp.MustCompile("^"+expr+"$"))
+	}
+	return filter
+}
+
+// ignores reports whether a tag key is managed outside Terraform
+func (f tagFilter) ignores(key string) bool {
+	for _, re := range f {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// without returns the tags whose keys are not ignored
+func (f tagFilter) without(tags map[string]string) map[string]string {
+	result := make(map[string]string, len(tags))
+	for key, value := range tags {
+		if !f.ignores(key) {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// expectedTags returns the tags Terraform manages for a resource: tags_all,
+// which includes the provider's default_tags, or tags for resources and
+// provider versions without it
+func expectedTags(attrs map[string]interface{}) map[string]string {
+	if _, ok := attrs["tags_all"].(map[string]interface{}); ok {
+		return stringMapAttr(attrs, "tags_all")
+	}
+	return stringMapAttr(attrs, "tags")
+}
+
+// tagChanges compares the tags of a resource in state with its live tags,
+// leaving out keys managed outside Terraform
+func (d *AWSDetector) tagChanges(attrs map[string]interface{}, actual map[string]string) []drift.Change {
+	return diffTags(d.ignoreTags.without(expectedTags(attrs)), d.ignoreTags.without(actual))
+}
+
+// diffTags reports tags missing from the cloud, tags with a different value
+// and tags added outside Terraform, ordered by key
+func diffTags(expected, actual map[string]string) []drift.Change {
+	keys := make([]string, 0, len(expected)+len(actual))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	for key := range actual {
+		if _, ok := expected[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []drift.Change
+	for _, key := range keys {
+		want, inState := expected[key]
+		got, inCloud := actual[key]
+		switch {
+		case !inState:
+			changes = append(changes, drift.Change{Field: "tags." + key, Expected: "absent", Actual: got})
+		case !inCloud:
+			changes = append(changes, drift.Change{Field: "tags." + key, Expected: want, Actual: "absent"})
+		case want != got:
+			changes = append(changes, drift.Change{Field: "tags." + key, Expected: want, Actual: got})
+		}
+	}
+	return changes
+}
+
+// ec2Tags converts EC2 tags to a map
+func ec2Tags(tags []types.Tag) map[string]string {
+	result := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		if tag.Key != nil {
+			result[*tag.Key] = aws.ToString(tag.Value)
+		}
+	}
+	return result
+}
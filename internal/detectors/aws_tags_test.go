@@ -0,0 +1,99 @@
+package detectors
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+)
+
+func TestTagFilterIgnores(t *testing.T) {
+	filter := newTagFilter([]string{"kubernetes.io/cluster/*", "CostCenter", "team-*-owner"})
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"aws:cloudformation:stack-name", true},
+		{"aws:autoscaling:groupName", true},
+		{"kubernetes.io/cluster/prod", true},
+		{"kubernetes.io/cluster/prod/extra", true},
+		{"kubernetes.io/role/elb", false},
+		{"CostCenter", true},
+		{"costcenter", false},
+		{"CostCenterCode", false},
+		{"team-core-owner", true},
+		{"team.core-owner", false},
+		{"Name", false},
+		{"awsx:thing", false},
+	}
+
+	for _, tt := range tests {
+		if got := filter.ignores(tt.key); got != tt.want {
+			t.Errorf("ignores(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestTagChanges(t *testing.T) {
+	tests := []struct {
+		name   string
+		ignore []string
+		attrs  map[string]interface{}
+		actual map[string]string
+		want   []drift.Change
+	}{
+		{
+			name: "tags_all includes default tags",
+			attrs: map[string]interface{}{
+				"tags":     map[string]interface{}{"Name": "web"},
+				"tags_all": map[string]interface{}{"Name": "web", "Env": "prod"},
+			},
+			actual: map[string]string{"Name": "web", "Env": "prod"},
+		},
+		{
+			name: "default tag changed",
+			attrs: map[string]interface{}{
+				"tags":     map[string]interface{}{"Name": "web"},
+				"tags_all": map[string]interface{}{"Name": "web", "Env": "prod"},
+			},
+			actual: map[string]string{"Name": "web", "Env": "dev"},
+			want:   []drift.Change{{Field: "tags.Env", Expected: "prod", Actual: "dev"}},
+		},
+		{
+			name:   "tags without tags_all",
+			attrs:  map[string]interface{}{"tags": map[string]interface{}{"Name": "web"}},
+			actual: map[string]string{"Name": "web", "Owner": "ops"},
+			want:   []drift.Change{{Field: "tags.Owner", Expected: "absent", Actual: "ops"}},
+		},
+		{
+			name:   "aws tags are always ignored",
+			attrs:  map[string]interface{}{"tags_all": map[string]interface{}{"Name": "web"}},
+			actual: map[string]string{"Name": "web", "aws:cloudformation:stack-id": "arn:aws:cloudformation:stack"},
+		},
+		{
+			name:   "ignored keys on either side",
+			ignore: []string{"kubernetes.io/*", "LastScanned"},
+			attrs: map[string]interface{}{
+				"tags_all": map[string]interface{}{"Name": "web", "LastScanned": "monday"},
+			},
+			actual: map[string]string{"Name": "web", "LastScanned": "friday", "kubernetes.io/cluster/prod": "owned"},
+		},
+		{
+			name:   "ignore patterns do not hide other keys",
+			ignore: []string{"kubernetes.io/*"},
+			attrs:  map[string]interface{}{"tags_all": map[string]interface{}{"Name": "web"}},
+			actual: map[string]string{"Name": "web", "Team": "core"},
+			want:   []drift.Change{{Field: "tags.Team", Expected: "absent", Actual: "core"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &AWSDetector{ignoreTags: newTagFilter(tt.ignore)}
+			if got := d.tagChanges(tt.attrs, tt.actual); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tagChanges() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
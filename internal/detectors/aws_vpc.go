@@ -0,0 +1,573 @@
+package detectors
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// routeTargetAttrs are the state attributes of a route that name its target.
+// A route has exactly one.
+var routeTargetAttrs = []string{
+	"gateway_id",
+	"nat_gateway_id",
+	"transit_gateway_id",
+	"vpc_peering_connection_id",
+	"egress_only_gateway_id",
+	"carrier_gateway_id",
+	"local_gateway_id",
+	"core_network_arn",
+	"network_interface_id",
+	"vpc_endpoint_id",
+}
+
+// routeSet maps route destinations (a CIDR block or prefix list ID) to
+// their target
+type routeSet map[string]string
+
+// stateRoute returns the destination and target of a route block of an
+// aws_route_table, or of an aws_route when prefix is "destination_"
+func stateRoute(attrs map[string]interface{}, prefix string) (string, string) {
+	var destination, target string
+	for _, name := range []string{prefix + "cidr_block", prefix + "ipv6_cidr_block", "destination_prefix_list_id"} {
+		if destination = stringAttr(attrs, name); destination != "" {
+			break
+		}
+	}
+	for _, name := range routeTargetAttrs {
+		if target = stringAttr(attrs, name); target != "" {
+			break
+		}
+	}
+	return destination, target
+}
+
+// actualRoutes collects the routes of a route table that Terraform manages.
+// The local route, routes propagated from virtual private gateways and
+// routes of gateway VPC endpoints are left out, as the provider does.
+func actualRoutes(table types.RouteTable) routeSet {
+	routes := make(routeSet)
+	for _, route := range table.Routes {
+		if route.Origin == types.RouteOriginCreateRouteTable || route.Origin == types.RouteOriginEnableVgwRoutePropagation {
+			continue
+		}
+		gateway := aws.ToString(route.GatewayId)
+		if gateway == "local" {
+			continue
+		}
+		// Prefix list routes to gateway endpoints are managed through the
+		// endpoint. Routes to Gateway Load Balancer endpoints also come back
+		// with the endpoint as GatewayId, which the state records as
+		// vpc_endpoint_id; both hold the same ID, so they compare as is.
+		if route.DestinationPrefixListId != nil && strings.HasPrefix(gateway, "vpce-") {
+			continue
+		}
+
+		destination := aws.ToString(route.DestinationCidrBlock)
+		if destination == "" {
+			destination = aws.ToString(route.DestinationIpv6CidrBlock)
+		}
+		if destination == "" {
+			destination = aws.ToString(route.DestinationPrefixListId)
+		}
+
+		// A route to an instance also names its network interface, which is
+		// what the state records
+		var target string
+		for _, id := range []*string{
+			route.GatewayId,
+			route.NatGatewayId,
+			route.TransitGatewayId,
+			route.VpcPeeringConnectionId,
+			route.EgressOnlyInternetGatewayId,
+			route.CarrierGatewayId,
+			route.LocalGatewayId,
+			route.CoreNetworkArn,
+			route.NetworkInterfaceId,
+		} {
+			if target = aws.ToString(id); target != "" {
+				break
+			}
+		}
+		routes[destination] = target
+	}
+	return routes
+}
+
+// diffRoutes reports routes added, removed or pointed at another target,
+// one change per route ordered by destination. Destinations in skip are
+// managed by aws_route resources, which are checked on their own.
+func diffRoutes(expected, actual routeSet, skip map[string]bool) []drift.Change {
+	destinations := make([]string, 0, len(expected)+len(actual))
+	for destination := range expected {
+		destinations = append(destinations, destination)
+	}
+	for destination := range actual {
+		if _, ok := expected[destination]; !ok {
+			destinations = append(destinations, destination)
+		}
+	}
+	sort.Strings(destinations)
+
+	var changes []drift.Change
+	for _, destination := range destinations {
+		if skip[destination] {
+			continue
+		}
+		want, inState := expected[destination]
+		got, inCloud := actual[destination]
+		field := fmt.Sprintf("route[%s]", destination)
+		switch {
+		case !inState:
+			changes = append(changes, drift.Change{Field: field, Expected: "absent", Actual: got})
+		case !inCloud:
+			changes = append(changes, drift.Change{Field: field, Expected: want, Actual: "absent"})
+		case want != got:
+			changes = append(changes, drift.Change{Field: field, Expected: want, Actual: got})
+		}
+	}
+	return changes
+}
+
+// routeSeverity rates a route change: a default route sent to an internet
+// gateway outside Terraform exposes the subnets behind the table and is
+// critical, other route changes are high. It returns "" for changes that
+// are not routes.
+func routeSeverity(change drift.Change) string {
+	if !strings.HasPrefix(change.Field, "route[") {
+		return ""
+	}
+	target, _ := change.Actual.(string)
+	if (change.Field == "route[0.0.0.0/0]" || change.Field == "route[::/0]") && strings.HasPrefix(target, "igw-") {
+		return "critical"
+	}
+	return "high"
+}
+
+// naclEntry describes a network ACL entry, e.g. "allow tcp 22-22 0.0.0.0/0"
+func naclEntry(action, protocol string, fromPort, toPort, icmpType, icmpCode int, cidr string) string {
+	protocol = strings.ToLower(protocol)
+	if name, ok := sgProtocolNames[protocol]; ok {
+		protocol = name
+	}
+	switch protocol {
+	case "-1":
+		return fmt.Sprintf("%s all %s", action, cidr)
+	case "tcp", "udp":
+		return fmt.Sprintf("%s %s %d-%d %s", action, protocol, fromPort, toPort, cidr)
+	case "icmp", "icmpv6":
+		return fmt.Sprintf("%s %s type %d code %d %s", action, protocol, icmpType, icmpCode, cidr)
+	default:
+		return fmt.Sprintf("%s %s %s", action, protocol, cidr)
+	}
+}
+
+// naclEntryKey names an entry by direction and rule number, e.g. ingress[100]
+func naclEntryKey(egress bool, ruleNumber int) string {
+	if egress {
+		return fmt.Sprintf("egress[%d]", ruleNumber)
+	}
+	return fmt.Sprintf("ingress[%d]", ruleNumber)
+}
+
+// naclDefaultRules are the rule numbers of the catch-all deny entries every
+// network ACL has and the state does not record: 32767 for IPv4 and 32768
+// for IPv6
+var naclDefaultRules = map[int]bool{32767: true, 32768: true}
+
+// expectedNACLEntries merges the inline entries of an aws_network_acl with
+// its aws_network_acl_rule resources
+func expectedNACLEntries(resource terraform.ResourceInstance, related relatedResources) map[string]string {
+	attrs := resource.Attributes
+	entries := make(map[string]string)
+	for _, direction := range []string{"ingress", "egress"} {
+		for _, block := range blockAttr(attrs, direction) {
+			cidr := stringAttr(block, "cidr_block")
+			if cidr == "" {
+				cidr = stringAttr(block, "ipv6_cidr_block")
+			}
+			entries[naclEntryKey(direction == "egress", intAttr(block, "rule_no"))] = naclEntry(
+				stringAttr(block, "action"), stringAttr(block, "protocol"),
+				intAttr(block, "from_port"), intAttr(block, "to_port"),
+				intAttr(block, "icmp_type"), intAttr(block, "icmp_code"), cidr)
+		}
+	}
+	for _, rule := range related.of("aws_network_acl_rule", stringAttr(attrs, "id")) {
+		ruleAttrs := rule.Attributes
+		cidr := stringAttr(ruleAttrs, "cidr_block")
+		if cidr == "" {
+			cidr = stringAttr(ruleAttrs, "ipv6_cidr_block")
+		}
+		entries[naclEntryKey(boolAttr(ruleAttrs, "egress"), intAttr(ruleAttrs, "rule_number"))] = naclEntry(
+			stringAttr(ruleAttrs, "rule_action"), stringAttr(ruleAttrs, "protocol"),
+			intAttr(ruleAttrs, "from_port"), intAttr(ruleAttrs, "to_port"),
+			intAttr(ruleAttrs, "icmp_type"), intAttr(ruleAttrs, "icmp_code"), cidr)
+	}
+	return entries
+}
+
+// actualNACLEntries describes the entries of a network ACL
+func actualNACLEntries(acl types.NetworkAcl) map[string]string {
+	entries := make(map[string]string)
+	for _, entry := range acl.Entries {
+		ruleNumber := int(aws.ToInt32(entry.RuleNumber))
+		if naclDefaultRules[ruleNumber] {
+			continue
+		}
+		var fromPort, toPort, icmpType, icmpCode int
+		if entry.PortRange != nil {
+			fromPort, toPort = int(aws.ToInt32(entry.PortRange.From)), int(aws.ToInt32(entry.PortRange.To))
+		}
+		if entry.IcmpTypeCode != nil {
+			icmpType, icmpCode = int(aws.ToInt32(entry.IcmpTypeCode.Type)), int(aws.ToInt32(entry.IcmpTypeCode.Code))
+		}
+		cidr := aws.ToString(entry.CidrBlock)
+		if cidr == "" {
+			cidr = aws.ToString(entry.Ipv6CidrBlock)
+		}
+		entries[naclEntryKey(aws.ToBool(entry.Egress), ruleNumber)] = naclEntry(
+			string(entry.RuleAction), aws.ToString(entry.Protocol), fromPort, toPort, icmpType, icmpCode, cidr)
+	}
+	return entries
+}
+
+// diffNACLEntries reports entries added, removed or changed, one change per
+// rule number. The field names follow security group rules, so
+// sgRuleSeverity rates them high.
+func diffNACLEntries(expected, actual map[string]string) []drift.Change {
+	keys := make([]string, 0, len(expected)+len(actual))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	for key := range actual {
+		if _, ok := expected[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []drift.Change
+	for _, key := range keys {
+		want, inState := expected[key]
+		got, inCloud := actual[key]
+		switch {
+		case !inState:
+			changes = append(changes, drift.Change{Field: key, Expected: "absent", Actual: got})
+		case !inCloud:
+			changes = append(changes, drift.Change{Field: key, Expected: want, Actual: "absent"})
+		case want != got:
+			changes = append(changes, drift.Change{Field: key, Expected: want, Actual: got})
+		}
+	}
+	return changes
+}
+
+// associatedIPv6Block returns the IPv6 CIDR block currently associated with
+// a VPC or subnet
+func associatedIPv6Block(blocks map[string]string) string {
+	for block, state := range blocks {
+		if state == "associated" {
+			return block
+		}
+	}
+	return ""
+}
+
+func (d *AWSDetector) checkVPC(ctx context.Context, clients *awsClients, index *awsResourceIndex, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	vpcID := stringAttr(attrs, "id")
+	if vpcID == "" {
+		return nil, fmt.Errorf("VPC ID not found")
+	}
+	if index.vpcsErr != nil {
+		return nil, index.vpcsErr
+	}
+
+	vpc, found := index.vpcs[vpcID]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	var changes changeSet
+	changes.compareString("cidr_block", attrs["cidr_block"], aws.ToString(vpc.CidrBlock))
+	changes.compareString("instance_tenancy", attrs["instance_tenancy"], string(vpc.InstanceTenancy))
+
+	ipv6 := make(map[string]string)
+	for _, association := range vpc.Ipv6CidrBlockAssociationSet {
+		if association.Ipv6CidrBlockState != nil {
+			ipv6[aws.ToString(association.Ipv6CidrBlock)] = string(association.Ipv6CidrBlockState.State)
+		}
+	}
+	changes.compareString("ipv6_cidr_block", attrs["ipv6_cidr_block"], associatedIPv6Block(ipv6))
+
+	// DNS settings are only returned one attribute at a time
+	for _, attribute := range []struct {
+		field string
+		name  types.VpcAttributeName
+	}{
+		{"enable_dns_support", types.VpcAttributeNameEnableDnsSupport},
+		{"enable_dns_hostnames", types.VpcAttributeNameEnableDnsHostnames},
+	} {
+		if _, ok := attrs[attribute.field].(bool); !ok {
+			continue
+		}
+		out, err := clients.ec2.DescribeVpcAttribute(ctx, &ec2.DescribeVpcAttributeInput{
+			VpcId:     aws.String(vpcID),
+			Attribute: attribute.name,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe VPC attribute %s: %w", attribute.name, err)
+		}
+		value := out.EnableDnsSupport
+		if attribute.name == types.VpcAttributeNameEnableDnsHostnames {
+			value = out.EnableDnsHostnames
+		}
+		if value != nil {
+			changes.compareBool(attribute.field, attrs[attribute.field], aws.ToBool(value.Value))
+		}
+	}
+
+	changes = append(changes, d.tagChanges(attrs, ec2Tags(vpc.Tags))...)
+
+	return changesDrift(resource, vpcID, changes), nil
+}
+
+func (d *AWSDetector) checkSubnet(index *awsResourceIndex, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	subnetID := stringAttr(attrs, "id")
+	if subnetID == "" {
+		return nil, fmt.Errorf("subnet ID not found")
+	}
+	if index.subnetsErr != nil {
+		return nil, index.subnetsErr
+	}
+
+	subnet, found := index.subnets[subnetID]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	var changes changeSet
+	changes.compareString("vpc_id", attrs["vpc_id"], aws.ToString(subnet.VpcId))
+	changes.compareString("cidr_block", attrs["cidr_block"], aws.ToString(subnet.CidrBlock))
+	changes.compareString("availability_zone", attrs["availability_zone"], aws.ToString(subnet.AvailabilityZone))
+	changes.compareBool("map_public_ip_on_launch", attrs["map_public_ip_on_launch"], aws.ToBool(subnet.MapPublicIpOnLaunch))
+	changes.compareBool("assign_ipv6_address_on_creation", attrs["assign_ipv6_address_on_creation"], aws.ToBool(subnet.AssignIpv6AddressOnCreation))
+
+	ipv6 := make(map[string]string)
+	for _, association := range subnet.Ipv6CidrBlockAssociationSet {
+		if association.Ipv6CidrBlockState != nil {
+			ipv6[aws.ToString(association.Ipv6CidrBlock)] = string(association.Ipv6CidrBlockState.State)
+		}
+	}
+	changes.compareString("ipv6_cidr_block", attrs["ipv6_cidr_block"], associatedIPv6Block(ipv6))
+
+	changes = append(changes, d.tagChanges(attrs, ec2Tags(subnet.Tags))...)
+
+	return changesDrift(resource, subnetID, changes), nil
+}
+
+func (d *AWSDetector) checkRouteTable(index *awsResourceIndex, related relatedResources, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	tableID := stringAttr(attrs, "id")
+	if tableID == "" {
+		return nil, fmt.Errorf("route table ID not found")
+	}
+	if index.routeTablesErr != nil {
+		return nil, index.routeTablesErr
+	}
+
+	table, found := index.routeTables[tableID]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	var changes changeSet
+	changes.compareString("vpc_id", attrs["vpc_id"], aws.ToString(table.VpcId))
+
+	var vgws []string
+	for _, vgw := range table.PropagatingVgws {
+		vgws = append(vgws, aws.ToString(vgw.GatewayId))
+	}
+	changes.compareStrings("propagating_vgws", attrs["propagating_vgws"], vgws)
+
+	// The route attribute is refreshed with the routes of aws_route
+	// resources too, so those are left to the aws_route checks
+	expected := make(routeSet)
+	for _, block := range blockAttr(attrs, "route") {
+		if destination, target := stateRoute(block, ""); destination != "" {
+			expected[destination] = target
+		}
+	}
+	owned := make(map[string]bool)
+	for _, route := range related.of("aws_route", tableID) {
+		if destination, _ := stateRoute(route.Attributes, "destination_"); destination != "" {
+			owned[destination] = true
+		}
+	}
+	changes = append(changes, diffRoutes(expected, actualRoutes(table), owned)...)
+
+	changes = append(changes, d.tagChanges(attrs, ec2Tags(table.Tags))...)
+
+	return changesDrift(resource, tableID, changes), nil
+}
+
+// checkRoute checks a route managed by an aws_route resource in its table
+func (d *AWSDetector) checkRoute(index *awsResourceIndex, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	tableID := stringAttr(attrs, "route_table_id")
+	destination, target := stateRoute(attrs, "destination_")
+	if tableID == "" || destination == "" {
+		return nil, fmt.Errorf("route table ID or destination not found")
+	}
+	if index.routeTablesErr != nil {
+		return nil, index.routeTablesErr
+	}
+
+	table, found := index.routeTables[tableID]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+	actual, found := actualRoutes(table)[destination]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	changes := diffRoutes(routeSet{destination: target}, routeSet{destination: actual}, nil)
+	return changesDrift(resource, tableID+"_"+destination, changes), nil
+}
+
+func (d *AWSDetector) checkNetworkACL(index *awsResourceIndex, related relatedResources, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	aclID := stringAttr(attrs, "id")
+	if aclID == "" {
+		return nil, fmt.Errorf("network ACL ID not found")
+	}
+	if index.networkACLsErr != nil {
+		return nil, index.networkACLsErr
+	}
+
+	acl, found := index.networkACLs[aclID]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	var changes changeSet
+	changes.compareString("vpc_id", attrs["vpc_id"], aws.ToString(acl.VpcId))
+
+	var subnets []string
+	for _, association := range acl.Associations {
+		subnets = append(subnets, aws.ToString(association.SubnetId))
+	}
+	changes.compareStrings("subnet_ids", attrs["subnet_ids"], subnets)
+
+	changes = append(changes, diffNACLEntries(expectedNACLEntries(resource, related), actualNACLEntries(acl))...)
+	changes = append(changes, d.tagChanges(attrs, ec2Tags(acl.Tags))...)
+
+	return changesDrift(resource, aclID, changes), nil
+}
+
+func (d *AWSDetector) checkInternetGateway(index *awsResourceIndex, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	gatewayID := stringAttr(attrs, "id")
+	if gatewayID == "" {
+		return nil, fmt.Errorf("internet gateway ID not found")
+	}
+	if index.internetGatewaysErr != nil {
+		return nil, index.internetGatewaysErr
+	}
+
+	gateway, found := index.internetGateways[gatewayID]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	var vpcID string
+	for _, attachment := range gateway.Attachments {
+		if attachment.State == types.AttachmentStatusAttached || attachment.State == "available" {
+			vpcID = aws.ToString(attachment.VpcId)
+		}
+	}
+
+	var changes changeSet
+	changes.compareString("vpc_id", attrs["vpc_id"], vpcID)
+	changes = append(changes, d.tagChanges(attrs, ec2Tags(gateway.Tags))...)
+
+	return changesDrift(resource, gatewayID, changes), nil
+}
+
+func (d *AWSDetector) checkNatGateway(index *awsResourceIndex, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	gatewayID := stringAttr(attrs, "id")
+	if gatewayID == "" {
+		return nil, fmt.Errorf("NAT gateway ID not found")
+	}
+	if index.natGatewaysErr != nil {
+		return nil, index.natGatewaysErr
+	}
+
+	gateway, found := index.natGateways[gatewayID]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	var changes changeSet
+	changes.compareString("subnet_id", attrs["subnet_id"], aws.ToString(gateway.SubnetId))
+	changes.compareString("connectivity_type", attrs["connectivity_type"], string(gateway.ConnectivityType))
+
+	var allocationID string
+	for _, address := range gateway.NatGatewayAddresses {
+		if aws.ToBool(address.IsPrimary) || allocationID == "" {
+			allocationID = aws.ToString(address.AllocationId)
+		}
+	}
+	changes.compareString("allocation_id", attrs["allocation_id"], allocationID)
+
+	changes = append(changes, d.tagChanges(attrs, ec2Tags(gateway.Tags))...)
+
+	return changesDrift(resource, gatewayID, changes), nil
+}
+
+func (d *AWSDetector) checkPeeringConnection(index *awsResourceIndex, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
+	attrs := resource.Attributes
+	connectionID := stringAttr(attrs, "id")
+	if connectionID == "" {
+		return nil, fmt.Errorf("VPC peering connection ID not found")
+	}
+	if index.peeringConnectionsErr != nil {
+		return nil, index.peeringConnectionsErr
+	}
+
+	connection, found := index.peeringConnections[connectionID]
+	if !found {
+		return existenceDrift(resource), nil
+	}
+
+	var changes changeSet
+	if requester := connection.RequesterVpcInfo; requester != nil {
+		changes.compareString("vpc_id", attrs["vpc_id"], aws.ToString(requester.VpcId))
+	}
+	if accepter := connection.AccepterVpcInfo; accepter != nil {
+		changes.compareString("peer_vpc_id", attrs["peer_vpc_id"], aws.ToString(accepter.VpcId))
+		changes.compareString("peer_owner_id", attrs["peer_owner_id"], aws.ToString(accepter.OwnerId))
+		// peer_region is only recorded for cross-region connections by older
+		// provider versions
+		if stringAttr(attrs, "peer_region") != "" {
+			changes.compareString("peer_region", attrs["peer_region"], aws.ToString(accepter.Region))
+		}
+	}
+	if connection.Status != nil {
+		changes.compareString("accept_status", attrs["accept_status"], string(connection.Status.Code))
+	}
+
+	changes = append(changes, d.tagChanges(attrs, ec2Tags(connection.Tags))...)
+
+	return changesDrift(resource, connectionID, changes), nil
+}
@@ -0,0 +1,196 @@
+package detectors
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+func TestStateRoute(t *testing.T) {
+	tests := []struct {
+		name                string
+		attrs               map[string]interface{}
+		prefix              string
+		destination, target string
+	}{
+		{"table route block", map[string]interface{}{"cidr_block": "0.0.0.0/0", "gateway_id": "igw-1", "nat_gateway_id": ""}, "", "0.0.0.0/0", "igw-1"},
+		{"IPv6 route block", map[string]interface{}{"cidr_block": "", "ipv6_cidr_block": "::/0", "egress_only_gateway_id": "eigw-1"}, "", "::/0", "eigw-1"},
+		{"aws_route", map[string]interface{}{"destination_cidr_block": "10.1.0.0/16", "vpc_peering_connection_id": "pcx-1"}, "destination_", "10.1.0.0/16", "pcx-1"},
+		{"prefix list", map[string]interface{}{"destination_prefix_list_id": "pl-1", "transit_gateway_id": "tgw-1"}, "destination_", "pl-1", "tgw-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			destination, target := stateRoute(tt.attrs, tt.prefix)
+			if destination != tt.destination || target != tt.target {
+				t.Errorf("stateRoute() = %q, %q, want %q, %q", destination, target, tt.destination, tt.target)
+			}
+		})
+	}
+}
+
+func TestActualRoutesSkipsUnmanagedRoutes(t *testing.T) {
+	table := types.RouteTable{Routes: []types.Route{
+		{DestinationCidrBlock: aws.String("10.0.0.0/16"), GatewayId: aws.String("local"), Origin: types.RouteOriginCreateRouteTable},
+		{DestinationCidrBlock: aws.String("192.168.0.0/16"), GatewayId: aws.String("vgw-1"), Origin: types.RouteOriginEnableVgwRoutePropagation},
+		{DestinationPrefixListId: aws.String("pl-s3"), GatewayId: aws.String("vpce-1"), Origin: types.RouteOriginCreateRoute},
+		// Gateway Load Balancer endpoints are routed to through GatewayId
+		{DestinationCidrBlock: aws.String("10.1.0.0/16"), GatewayId: aws.String("vpce-gwlb"), Origin: types.RouteOriginCreateRoute},
+		{DestinationPrefixListId: aws.String("pl-corp"), TransitGatewayId: aws.String("tgw-1"), Origin: types.RouteOriginCreateRoute},
+		{DestinationCidrBlock: aws.String("0.0.0.0/0"), NatGatewayId: aws.String("nat-1"), Origin: types.RouteOriginCreateRoute},
+		{DestinationIpv6CidrBlock: aws.String("::/0"), EgressOnlyInternetGatewayId: aws.String("eigw-1"), Origin: types.RouteOriginCreateRoute},
+		// A route to an instance is recorded by its network interface
+		{DestinationCidrBlock: aws.String("10.9.0.0/16"), InstanceId: aws.String("i-1"), NetworkInterfaceId: aws.String("eni-1"), Origin: types.RouteOriginCreateRoute},
+	}}
+	want := routeSet{"0.0.0.0/0": "nat-1", "::/0": "eigw-1", "10.9.0.0/16": "eni-1", "10.1.0.0/16": "vpce-gwlb", "pl-corp": "tgw-1"}
+	got := actualRoutes(table)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("actualRoutes() = %v, want %v", got, want)
+	}
+
+	// The state records a Gateway Load Balancer endpoint as vpc_endpoint_id
+	destination, target := stateRoute(map[string]interface{}{"cidr_block": "10.1.0.0/16", "gateway_id": "", "vpc_endpoint_id": "vpce-gwlb"}, "")
+	if got[destination] != target {
+		t.Errorf("route to %s = %q, state has %q", destination, got[destination], target)
+	}
+}
+
+func TestDiffRoutes(t *testing.T) {
+	expected := routeSet{"0.0.0.0/0": "nat-1", "10.1.0.0/16": "pcx-1", "10.2.0.0/16": "tgw-1"}
+	actual := routeSet{"0.0.0.0/0": "igw-1", "10.2.0.0/16": "tgw-1", "10.3.0.0/16": "pcx-3", "10.4.0.0/16": "pcx-4"}
+	skip := map[string]bool{"10.4.0.0/16": true}
+
+	want := []drift.Change{
+		{Field: "route[0.0.0.0/0]", Expected: "nat-1", Actual: "igw-1"},
+		{Field: "route[10.1.0.0/16]", Expected: "pcx-1", Actual: "absent"},
+		{Field: "route[10.3.0.0/16]", Expected: "absent", Actual: "pcx-3"},
+	}
+	if got := diffRoutes(expected, actual, skip); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffRoutes() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRouteSeverity(t *testing.T) {
+	tests := []struct {
+		name   string
+		change drift.Change
+		want   string
+	}{
+		{"default route to an internet gateway", drift.Change{Field: "route[0.0.0.0/0]", Expected: "nat-1", Actual: "igw-1"}, "critical"},
+		{"IPv6 default route to an internet gateway", drift.Change{Field: "route[::/0]", Expected: "absent", Actual: "igw-1"}, "critical"},
+		{"default route removed", drift.Change{Field: "route[0.0.0.0/0]", Expected: "igw-1", Actual: "absent"}, "high"},
+		{"default route to a NAT gateway", drift.Change{Field: "route[0.0.0.0/0]", Expected: "absent", Actual: "nat-1"}, "high"},
+		{"private route to an internet gateway", drift.Change{Field: "route[10.0.0.0/8]", Expected: "absent", Actual: "igw-1"}, "high"},
+		{"not a route", drift.Change{Field: "vpc_id", Expected: "vpc-1", Actual: "vpc-2"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := routeSeverity(tt.change); got != tt.want {
+				t.Errorf("routeSeverity() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNACLEntry(t *testing.T) {
+	tests := []struct {
+		name               string
+		action, protocol   string
+		from, to           int
+		icmpType, icmpCode int
+		cidr               string
+		want               string
+	}{
+		{"tcp by name", "allow", "tcp", 22, 22, 0, 0, "10.0.0.0/8", "allow tcp 22-22 10.0.0.0/8"},
+		{"tcp by number", "allow", "6", 443, 443, 0, 0, "0.0.0.0/0", "allow tcp 443-443 0.0.0.0/0"},
+		{"udp by number", "deny", "17", 53, 53, 0, 0, "0.0.0.0/0", "deny udp 53-53 0.0.0.0/0"},
+		{"all protocols ignore ports", "allow", "-1", 0, 65535, 0, 0, "0.0.0.0/0", "allow all 0.0.0.0/0"},
+		{"all by name", "allow", "all", 0, 0, 0, 0, "0.0.0.0/0", "allow all 0.0.0.0/0"},
+		{"icmp by number", "allow", "1", 0, 0, 8, -1, "0.0.0.0/0", "allow icmp type 8 code -1 0.0.0.0/0"},
+		{"icmpv6", "allow", "58", 0, 0, -1, -1, "::/0", "allow icmpv6 type -1 code -1 ::/0"},
+		{"other protocol number", "allow", "50", 0, 0, 0, 0, "10.0.0.0/8", "allow 50 10.0.0.0/8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := naclEntry(tt.action, tt.protocol, tt.from, tt.to, tt.icmpType, tt.icmpCode, tt.cidr)
+			if got != tt.want {
+				t.Errorf("naclEntry() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNACLEntriesStateMatchesCloud(t *testing.T) {
+	acl := terraform.ResourceInstance{
+		Type: "aws_network_acl",
+		Attributes: map[string]interface{}{
+			"id": "acl-1",
+			"ingress": []interface{}{
+				map[string]interface{}{
+					"rule_no": float64(100), "action": "allow", "protocol": "6",
+					"from_port": float64(443), "to_port": float64(443), "cidr_block": "0.0.0.0/0",
+				},
+			},
+			"egress": []interface{}{
+				map[string]interface{}{
+					"rule_no": float64(100), "action": "allow", "protocol": "-1",
+					"from_port": float64(0), "to_port": float64(0), "cidr_block": "0.0.0.0/0",
+				},
+			},
+		},
+	}
+	related := indexRelated([]terraform.ResourceInstance{
+		{Type: "aws_network_acl_rule", Attributes: map[string]interface{}{
+			"network_acl_id": "acl-1", "rule_number": float64(200), "egress": false,
+			"rule_action": "deny", "protocol": "tcp", "from_port": float64(22), "to_port": float64(22),
+			"cidr_block": "", "ipv6_cidr_block": "::/0",
+		}},
+	})
+
+	cloud := types.NetworkAcl{Entries: []types.NetworkAclEntry{
+		{RuleNumber: aws.Int32(100), Egress: aws.Bool(false), RuleAction: types.RuleActionAllow, Protocol: aws.String("6"),
+			PortRange: &types.PortRange{From: aws.Int32(443), To: aws.Int32(443)}, CidrBlock: aws.String("0.0.0.0/0")},
+		{RuleNumber: aws.Int32(200), Egress: aws.Bool(false), RuleAction: types.RuleActionDeny, Protocol: aws.String("6"),
+			PortRange: &types.PortRange{From: aws.Int32(22), To: aws.Int32(22)}, Ipv6CidrBlock: aws.String("::/0")},
+		{RuleNumber: aws.Int32(100), Egress: aws.Bool(true), RuleAction: types.RuleActionAllow, Protocol: aws.String("-1"),
+			CidrBlock: aws.String("0.0.0.0/0")},
+		// The default deny entries are not in state
+		{RuleNumber: aws.Int32(32767), Egress: aws.Bool(false), RuleAction: types.RuleActionDeny, Protocol: aws.String("-1"),
+			CidrBlock: aws.String("0.0.0.0/0")},
+		{RuleNumber: aws.Int32(32767), Egress: aws.Bool(true), RuleAction: types.RuleActionDeny, Protocol: aws.String("-1"),
+			CidrBlock: aws.String("0.0.0.0/0")},
+		// Nor are their IPv6 counterparts in VPCs with an IPv6 block
+		{RuleNumber: aws.Int32(32768), Egress: aws.Bool(false), RuleAction: types.RuleActionDeny, Protocol: aws.String("-1"),
+			Ipv6CidrBlock: aws.String("::/0")},
+		{RuleNumber: aws.Int32(32768), Egress: aws.Bool(true), RuleAction: types.RuleActionDeny, Protocol: aws.String("-1"),
+			Ipv6CidrBlock: aws.String("::/0")},
+	}}
+
+	expected := expectedNACLEntries(acl, related)
+	if changes := diffNACLEntries(expected, actualNACLEntries(cloud)); len(changes) != 0 {
+		t.Errorf("diffNACLEntries() = %+v, want no changes", changes)
+	}
+
+	cloud.Entries = append(cloud.Entries, types.NetworkAclEntry{
+		RuleNumber: aws.Int32(110), Egress: aws.Bool(false), RuleAction: types.RuleActionAllow, Protocol: aws.String("6"),
+		PortRange: &types.PortRange{From: aws.Int32(3389), To: aws.Int32(3389)}, CidrBlock: aws.String("0.0.0.0/0"),
+	})
+	cloud.Entries[0].RuleAction = types.RuleActionDeny
+
+	want := []drift.Change{
+		{Field: "ingress[100]", Expected: "allow tcp 443-443 0.0.0.0/0", Actual: "deny tcp 443-443 0.0.0.0/0"},
+		{Field: "ingress[110]", Expected: "absent", Actual: "allow tcp 3389-3389 0.0.0.0/0"},
+	}
+	got := diffNACLEntries(expected, actualNACLEntries(cloud))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffNACLEntries() = %+v, want %+v", got, want)
+	}
+	for _, change := range got {
+		if severity := sgRuleSeverity(change); severity != "high" {
+			t.Errorf("sgRuleSeverity(%s) = %q, want high", change.Field, severity)
+		}
+	}
+}
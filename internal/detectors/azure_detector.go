@@ -36,7 +36,7 @@ func (d *AzureDetector) Detect(ctx context.Context, state *terraform.State) ([]d
 
 	log.Debugf("Detecting drift in Azure resources for subscription: %s", d.subscriptionID)
 
-	for _, resource := range state.Resources {
+	for _, resource := range state.ManagedInstances() {
 		// Only check Azure resources
 		if !isAzureResource(resource.Type) {
 			continue
@@ -46,7 +46,7 @@ func (d *AzureDetector) Detect(ctx context.Context, state *terraform.State) ([]d
 		case "azurerm_virtual_machine":
 			drift, err := d.checkVirtualMachine(ctx, resource)
 			if err != nil {
-				log.Warnf("Error checking virtual machine %s: %v", resource.Name, err)
+				log.Warnf("Error checking virtual machine %s: %v", resource.Address, err)
 				continue
 			}
 			if drift != nil {
@@ -56,7 +56,7 @@ func (d *AzureDetector) Detect(ctx context.Context, state *terraform.State) ([]d
 		case "azurerm_storage_account":
 			drift, err := d.checkStorageAccount(ctx, resource)
 			if err != nil {
-				log.Warnf("Error checking storage account %s: %v", resource.Name, err)
+				log.Warnf("Error checking storage account %s: %v", resource.Address, err)
 				continue
 			}
 			if drift != nil {
@@ -68,9 +68,9 @@ func (d *AzureDetector) Detect(ctx context.Context, state *terraform.State) ([]d
 	return drifts, nil
 }
 
-func (d *AzureDetector) checkVirtualMachine(ctx context.Context, resource terraform.Resource) (*drift.DriftItem, error) {
+func (d *AzureDetector) checkVirtualMachine(ctx context.Context, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
 	// Implementation for Azure VM drift detection
-	log.Debug("Checking Azure virtual machine:", resource.Name)
+	log.Debug("Checking Azure virtual machine:", resource.Address)
 	
 	// In production, this would:
 	// 1. Use Azure SDK to get VM details
@@ -80,9 +80,9 @@ func (d *AzureDetector) checkVirtualMachine(ctx context.Context, resource terraf
 	return nil, nil
 }
 
-func (d *AzureDetector) checkStorageAccount(ctx context.Context, resource terraform.Resource) (*drift.DriftItem, error) {
+func (d *AzureDetector) checkStorageAccount(ctx context.Context, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
 	// Implementation for Azure storage account drift detection
-	log.Debug("Checking Azure storage account:", resource.Name)
+	log.Debug("Checking Azure storage account:", resource.Address)
 	
 	return nil, nil
 }
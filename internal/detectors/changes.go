@@ -0,0 +1,50 @@
+package detectors
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+)
+
+// changeSet collects the changes of one resource. The compare methods take
+// the raw state attribute and skip attributes the state does not record, so
+// older states and partial imports do not produce spurious drift.
+type changeSet []drift.Change
+
+func (c *changeSet) add(field string, expected, actual interface{}) {
+	*c = append(*c, drift.Change{Field: field, Expected: expected, Actual: actual})
+}
+
+func (c *changeSet) compareString(field string, expected interface{}, actual string) {
+	if s, ok := expected.(string); ok && s != actual {
+		c.add(field, s, actual)
+	}
+}
+
+func (c *changeSet) compareBool(field string, expected interface{}, actual bool) {
+	if b, ok := expected.(bool); ok && b != actual {
+		c.add(field, b, actual)
+	}
+}
+
+func (c *changeSet) compareInt(field string, expected interface{}, actual int) {
+	if n, ok := expected.(float64); ok && int(n) != actual {
+		c.add(field, int(n), actual)
+	}
+}
+
+// compareStrings compares an unordered list of strings such as a set of
+// security group IDs
+func (c *changeSet) compareStrings(field string, expected interface{}, actual []string) {
+	list, ok := expected.([]interface{})
+	if !ok {
+		return
+	}
+	want := stringListAttr(map[string]interface{}{field: list}, field)
+	got := append([]string(nil), actual...)
+	sort.Strings(got)
+	if strings.Join(want, ",") != strings.Join(got, ",") {
+		c.add(field, want, got)
+	}
+}
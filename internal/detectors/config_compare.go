@@ -0,0 +1,269 @@
+package detectors
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+)
+
+// CompareWithConfig turns state-vs-cloud drift into a three-way comparison
+// against the Terraform configuration. Each cloud change is annotated with
+// the configured value and its origin, and attributes where state disagrees
+// with configuration are added as changes with origin "state" so that a
+// console change can be told apart from a refresh that left state out of
+// line with the code. Changes to fields covered by lifecycle ignore_changes
+// are kept but marked as ignored. Values of attributes the state marks
+// sensitive are redacted.
+func CompareWithConfig(config *terraform.ModuleConfig, state *terraform.State, drifts []drift.DriftItem) []drift.DriftItem {
+	byAddress := make(map[string]int, len(drifts))
+	for i, d := range drifts {
+		byAddress[d.Address] = i
+	}
+
+	for _, resource := range state.ManagedInstances() {
+		cfg, ok := config.Resource(resource)
+		if !ok {
+			continue
+		}
+
+		i, drifted := byAddress[resource.Address]
+		if drifted {
+			for j := range drifts[i].Changes {
+				annotateChange(&drifts[i].Changes[j], cfg)
+			}
+			markIgnored(&drifts[i], cfg)
+			redactChanges(resource, drifts[i].Changes)
+		}
+
+		stateChanges := compareStateWithConfig(resource, cfg)
+		redactChanges(resource, stateChanges)
+		if drifted {
+			stateChanges = withoutFields(stateChanges, drifts[i].Changes)
+		}
+		if len(stateChanges) == 0 {
+			continue
+		}
+
+		if drifted {
+			drifts[i].Changes = append(drifts[i].Changes, stateChanges...)
+			continue
+		}
+
+		id, _ := resource.Attributes["id"].(string)
+		byAddress[resource.Address] = len(drifts)
+		drifts = append(drifts, drift.DriftItem{
+			ResourceType: resource.Type,
+			ResourceName: resource.Name,
+			Address:      resource.Address,
+			ResourceID:   id,
+			Provider:     providerName(resource.Type),
+			Severity:     "low",
+			Changes:      stateChanges,
+		})
+	}
+
+	return drifts
+}
+
+// annotateChange sets the configured value and origin of a state-vs-cloud change
+func annotateChange(change *drift.Change, cfg *terraform.ConfigResource) {
+	if change.Origin == drift.OriginUnknown {
+		return
+	}
+	configured, ok := configValue(cfg, change.Field)
+	if !ok {
+		change.Origin = drift.OriginCloud
+		return
+	}
+
+	change.Configured = configured
+	switch {
+	case valuesEqual(configured, change.Expected):
+		change.Origin = drift.OriginCloud
+	case valuesEqual(configured, change.Actual):
+		change.Origin = drift.OriginState
+	default:
+		change.Origin = drift.OriginCloudAndState
+	}
+}
+
+// markIgnored flags the changes covered by lifecycle ignore_changes and
+// recomputes the severity from the remaining ones
+func markIgnored(item *drift.DriftItem, cfg *terraform.ConfigResource) {
+	ignored := false
+	for j := range item.Changes {
+		if cfg.Ignores(item.Changes[j].Field) {
+			item.Changes[j].Ignored = true
+			ignored = true
+		}
+	}
+	if active := item.ActiveChanges(); ignored && len(active) > 0 {
+		item.Severity = determineSeverity(active)
+	}
+}
+
+// compareStateWithConfig reports configured attributes whose state value
+// differs, skipping fields Terraform is told to ignore. Maps are compared
+// key by key so fields line up with detector fields such as tags.Name.
+func compareStateWithConfig(resource terraform.ResourceInstance, cfg *terraform.ConfigResource) []drift.Change {
+	names := make([]string, 0, len(cfg.Attributes))
+	for name := range cfg.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var changes []drift.Change
+	for _, name := range names {
+		configured := cfg.Attributes[name]
+		if configured == nil || cfg.Ignores(name) {
+			continue
+		}
+
+		configuredMap, isMap := configured.(map[string]interface{})
+		stateMap, stateIsMap := resource.Attributes[name].(map[string]interface{})
+		if isMap && (stateIsMap || resource.Attributes[name] == nil) {
+			keys := make([]string, 0, len(configuredMap))
+			for k := range configuredMap {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				if !valuesEqual(configuredMap[k], stateMap[k]) && !cfg.Ignores(name+"."+k) {
+					changes = append(changes, stateChange(name+"."+k, configuredMap[k], stateMap[k]))
+				}
+			}
+			continue
+		}
+
+		if !valuesEqual(configured, resource.Attributes[name]) {
+			changes = append(changes, stateChange(name, configured, resource.Attributes[name]))
+		}
+	}
+	return changes
+}
+
+func stateChange(field string, configured, stateValue interface{}) drift.Change {
+	return drift.Change{
+		Field:      field,
+		Expected:   configured,
+		Actual:     stateValue,
+		Configured: configured,
+		Origin:     drift.OriginState,
+	}
+}
+
+// redactChanges replaces the expected, actual and configured values of
+// sensitive attributes. It runs after origins are set, which need the values.
+func redactChanges(resource terraform.ResourceInstance, changes []drift.Change) {
+	for j := range changes {
+		c := &changes[j]
+		c.Expected = redactSensitivePath(resource, c.Field, c.Expected)
+		c.Actual = redactSensitivePath(resource, c.Field, c.Actual)
+		c.Configured = redactSensitivePath(resource, c.Field, c.Configured)
+	}
+}
+
+// redactSensitivePath redacts a value at an attribute path, recursing into
+// maps and lists so a sensitive value inside a changed block is hidden as well
+func redactSensitivePath(resource terraform.ResourceInstance, path string, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if resource.IsSensitive(path) {
+		return terraform.SensitiveValue
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			redacted[k] = redactSensitivePath(resource, path+"."+k, item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactSensitivePath(resource, fmt.Sprintf("%s.%d", path, i), item)
+		}
+		return redacted
+	}
+	return value
+}
+
+// configValue looks up a change field such as "instance_type" or
+// "tags.Name" in the configured attributes
+func configValue(cfg *terraform.ConfigResource, field string) (interface{}, bool) {
+	parts := strings.Split(field, ".")
+	value, ok := cfg.Attributes[parts[0]]
+	if !ok {
+		return nil, false
+	}
+	for _, part := range parts[1:] {
+		m, isMap := value.(map[string]interface{})
+		if !isMap {
+			return nil, false
+		}
+		value = m[part]
+	}
+	return value, true
+}
+
+// withoutFields drops changes for fields already reported
+func withoutFields(changes, existing []drift.Change) []drift.Change {
+	seen := make(map[string]bool, len(existing))
+	for _, c := range existing {
+		seen[c.Field] = true
+	}
+	var result []drift.Change
+	for _, c := range changes {
+		if !seen[c.Field] {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// valuesEqual compares configured, state and cloud values that may differ
+// in representation, e.g. 8 vs "8" or a list in a different order
+func valuesEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+
+	la, aIsList := a.([]interface{})
+	lb, bIsList := b.([]interface{})
+	if aIsList && bIsList {
+		return len(la) == len(lb) && sortedStrings(la) == sortedStrings(lb)
+	}
+
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func sortedStrings(list []interface{}) string {
+	s := make([]string, len(list))
+	for i, v := range list {
+		s[i] = fmt.Sprint(v)
+	}
+	sort.Strings(s)
+	return strings.Join(s, "\x00")
+}
+
+// providerName maps a resource type to the name of the detector covering it
+func providerName(resourceType string) string {
+	switch {
+	case isAWSResource(resourceType):
+		return "AWS"
+	case isGCPResource(resourceType):
+		return "GCP"
+	case isAzureResource(resourceType):
+		return "Azure"
+	default:
+		return "Terraform"
+	}
+}
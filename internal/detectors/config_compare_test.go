@@ -0,0 +1,67 @@
+package detectors
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+)
+
+func TestCompareWithConfigRedactsSensitiveAttributes(t *testing.T) {
+	state := &terraform.State{Resources: []terraform.Resource{{
+		Mode: "managed",
+		Type: "aws_db_instance",
+		Name: "main",
+		Instances: []terraform.Instance{{
+			Attributes: map[string]interface{}{
+				"id":             "db-1",
+				"password":       "state-secret",
+				"instance_class": "db.t3.micro",
+				"environment":    map[string]interface{}{"API_KEY": "state-key", "STAGE": "prod"},
+			},
+			SensitiveAttributes: json.RawMessage(`[
+				[{"type": "get_attr", "value": "password"}],
+				[{"type": "get_attr", "value": "environment"}, {"type": "index", "value": {"value": "API_KEY", "type": "string"}}]
+			]`),
+		}},
+	}}}
+	config := &terraform.ModuleConfig{Resources: map[string]*terraform.ConfigResource{
+		"aws_db_instance.main": {
+			Type: "aws_db_instance",
+			Name: "main",
+			Attributes: map[string]interface{}{
+				"password":       "configured-secret",
+				"instance_class": "db.t3.small",
+				"environment":    map[string]interface{}{"API_KEY": "configured-key", "STAGE": "prod"},
+			},
+		},
+	}}
+	drifts := []drift.DriftItem{{
+		Address: "aws_db_instance.main",
+		Changes: []drift.Change{{Field: "password", Expected: "state-secret", Actual: "cloud-secret"}},
+	}}
+
+	got := CompareWithConfig(config, state, drifts)
+	if len(got) != 1 {
+		t.Fatalf("got %d drift items, want 1", len(got))
+	}
+
+	byField := make(map[string]drift.Change)
+	for _, c := range got[0].Changes {
+		byField[c.Field] = c
+	}
+
+	password := byField["password"]
+	if password.Origin != drift.OriginCloudAndState {
+		t.Errorf("password origin = %q, want %q", password.Origin, drift.OriginCloudAndState)
+	}
+	for _, c := range []drift.Change{password, byField["environment.API_KEY"]} {
+		if c.Expected != terraform.SensitiveValue || c.Actual != terraform.SensitiveValue || c.Configured != terraform.SensitiveValue {
+			t.Errorf("%s not redacted: %+v", c.Field, c)
+		}
+	}
+	if class := byField["instance_class"]; class.Expected != "db.t3.small" || class.Actual != "db.t3.micro" {
+		t.Errorf("instance_class = %+v, want the plain values", class)
+	}
+}
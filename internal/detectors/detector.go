@@ -2,6 +2,7 @@ package detectors
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/MeowTux/drift-detector/internal/drift"
 	"github.com/MeowTux/drift-detector/internal/terraform"
@@ -15,3 +16,11 @@ type Detector interface {
 	// Detect performs drift detection on resources
 	Detect(ctx context.Context, state *terraform.State) ([]drift.DriftItem, error)
 }
+
+// ScanErrors is returned by Detect together with the drift found so far when
+// some resources could not be checked
+type ScanErrors []drift.ScanError
+
+func (e ScanErrors) Error() string {
+	return fmt.Sprintf("%d resource(s) could not be checked", len(e))
+}
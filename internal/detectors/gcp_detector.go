@@ -36,7 +36,7 @@ func (d *GCPDetector) Detect(ctx context.Context, state *terraform.State) ([]dri
 
 	log.Debugf("Detecting drift in GCP resources for project: %s", d.projectID)
 
-	for _, resource := range state.Resources {
+	for _, resource := range state.ManagedInstances() {
 		// Only check GCP resources
 		if !isGCPResource(resource.Type) {
 			continue
@@ -46,7 +46,7 @@ func (d *GCPDetector) Detect(ctx context.Context, state *terraform.State) ([]dri
 		case "google_compute_instance":
 			drift, err := d.checkComputeInstance(ctx, resource)
 			if err != nil {
-				log.Warnf("Error checking compute instance %s: %v", resource.Name, err)
+				log.Warnf("Error checking compute instance %s: %v", resource.Address, err)
 				continue
 			}
 			if drift != nil {
@@ -56,7 +56,7 @@ func (d *GCPDetector) Detect(ctx context.Context, state *terraform.State) ([]dri
 		case "google_storage_bucket":
 			drift, err := d.checkStorageBucket(ctx, resource)
 			if err != nil {
-				log.Warnf("Error checking storage bucket %s: %v", resource.Name, err)
+				log.Warnf("Error checking storage bucket %s: %v", resource.Address, err)
 				continue
 			}
 			if drift != nil {
@@ -68,10 +68,10 @@ func (d *GCPDetector) Detect(ctx context.Context, state *terraform.State) ([]dri
 	return drifts, nil
 }
 
-func (d *GCPDetector) checkComputeInstance(ctx context.Context, resource terraform.Resource) (*drift.DriftItem, error) {
+func (d *GCPDetector) checkComputeInstance(ctx context.Context, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
 	// Implementation for GCP compute instance drift detection
 	// This is a placeholder - full implementation would use GCP SDK
-	log.Debug("Checking GCP compute instance:", resource.Name)
+	log.Debug("Checking GCP compute instance:", resource.Address)
 	
 	// In production, this would:
 	// 1. Use compute.NewInstancesRESTClient()
@@ -82,9 +82,9 @@ func (d *GCPDetector) checkComputeInstance(ctx context.Context, resource terrafo
 	return nil, nil
 }
 
-func (d *GCPDetector) checkStorageBucket(ctx context.Context, resource terraform.Resource) (*drift.DriftItem, error) {
+func (d *GCPDetector) checkStorageBucket(ctx context.Context, resource terraform.ResourceInstance) (*drift.DriftItem, error) {
 	// Implementation for GCP storage bucket drift detection
-	log.Debug("Checking GCP storage bucket:", resource.Name)
+	log.Debug("Checking GCP storage bucket:", resource.Address)
 	
 	return nil, nil
 }
@@ -0,0 +1,167 @@
+package detectors
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Defaults of the API policy when not configured
+const (
+	DefaultMaxAttempts = 5
+	DefaultBaseDelay   = 500 * time.Millisecond
+	DefaultMaxDelay    = 20 * time.Second
+)
+
+// RetryPolicy configures retries of failed cloud API calls
+type RetryPolicy struct {
+	MaxAttempts int           `mapstructure:"max_attempts"`
+	BaseDelay   time.Duration `mapstructure:"base_delay"`
+	MaxDelay    time.Duration `mapstructure:"max_delay"`
+}
+
+// RateLimit configures a token bucket; a zero rate means unlimited
+type RateLimit struct {
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	Burst             int     `mapstructure:"burst"`
+}
+
+// ProviderRateLimit is the rate limit of a provider, applied to each of its
+// regions separately, with optional per-region overrides
+type ProviderRateLimit struct {
+	RateLimit `mapstructure:",squash"`
+	Regions   map[string]RateLimit `mapstructure:"regions"`
+}
+
+// APIPolicyConfig configures retries and rate limits of cloud API calls.
+// RateLimits is keyed by provider name ("aws", "gcp", "azure"); the
+// "default" entry applies to providers without one.
+type APIPolicyConfig struct {
+	Retry      RetryPolicy                  `mapstructure:"retry"`
+	RateLimits map[string]ProviderRateLimit `mapstructure:"rate_limits"`
+}
+
+// APIPolicy is the retry and rate-limit policy shared by all detectors. It
+// keeps one token bucket per provider and region.
+type APIPolicy struct {
+	retry      RetryPolicy
+	rateLimits map[string]ProviderRateLimit
+
+	mu      sync.Mutex
+	buckets map[string]*TokenBucket
+}
+
+// NewAPIPolicy creates an API policy, filling in defaults
+func NewAPIPolicy(cfg APIPolicyConfig) *APIPolicy {
+	if cfg.Retry.MaxAttempts <= 0 {
+		cfg.Retry.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.Retry.BaseDelay <= 0 {
+		cfg.Retry.BaseDelay = DefaultBaseDelay
+	}
+	if cfg.Retry.MaxDelay <= 0 {
+		cfg.Retry.MaxDelay = DefaultMaxDelay
+	}
+
+	return &APIPolicy{
+		retry:      cfg.Retry,
+		rateLimits: cfg.RateLimits,
+		buckets:    make(map[string]*TokenBucket),
+	}
+}
+
+// MaxAttempts returns the number of attempts per call, including the first
+func (p *APIPolicy) MaxAttempts() int {
+	return p.retry.MaxAttempts
+}
+
+// Backoff returns the delay before retry attempt n (starting at 1): an
+// exponentially growing window capped at the maximum delay, with full jitter
+func (p *APIPolicy) Backoff(attempt int) time.Duration {
+	window := float64(p.retry.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if window > float64(p.retry.MaxDelay) {
+		window = float64(p.retry.MaxDelay)
+	}
+	return time.Duration(rand.Float64() * window)
+}
+
+// Limiter returns the token bucket of a provider and region
+func (p *APIPolicy) Limiter(provider, region string) *TokenBucket {
+	key := provider + "/" + region
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if bucket, ok := p.buckets[key]; ok {
+		return bucket
+	}
+
+	limit, ok := p.rateLimits[provider]
+	if !ok {
+		limit = p.rateLimits["default"]
+	}
+	rate := limit.RateLimit
+	if override, ok := limit.Regions[region]; ok {
+		rate = override
+	}
+
+	bucket := NewTokenBucket(rate)
+	p.buckets[key] = bucket
+	return bucket
+}
+
+// TokenBucket is a token-bucket rate limiter
+type TokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a full token bucket. The burst defaults to one
+// second's worth of requests.
+func NewTokenBucket(limit RateLimit) *TokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = math.Max(1, math.Ceil(limit.RequestsPerSecond))
+	}
+	return &TokenBucket{
+		rate:   limit.RequestsPerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available or ctx is done
+func (b *TokenBucket) Wait(ctx context.Context) error {
+	if b == nil || b.rate <= 0 {
+		return nil
+	}
+
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package detectors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+)
+
+// policyListElements are the policy elements that accept a single value or
+// a list of values with the same meaning
+var policyListElements = map[string]bool{
+	"Action":      true,
+	"NotAction":   true,
+	"Resource":    true,
+	"NotResource": true,
+}
+
+// awsAccountID matches a bare account ID used as an AWS principal
+var awsAccountID = regexp.MustCompile(`^\d{12}$`)
+
+// normalizePolicy parses an IAM or resource policy document into a canonical
+// form: single values become lists, lists are sorted and deduplicated,
+// account ID principals become the account's root ARN as IAM stores them and
+// statements are ordered, so that documents differing only in layout
+// compare equal. IAM returns documents URL-encoded, which is undone first.
+func normalizePolicy(document string) (interface{}, error) {
+	if !strings.HasPrefix(strings.TrimSpace(document), "{") {
+		if decoded, err := url.PathUnescape(document); err == nil {
+			document = decoded
+		}
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		return nil, fmt.Errorf("invalid policy document: %w", err)
+	}
+
+	root, ok := doc.(map[string]interface{})
+	if !ok {
+		return doc, nil
+	}
+	if statements, ok := root["Statement"]; ok {
+		root["Statement"] = normalizeStatements(statements)
+	}
+	return root, nil
+}
+
+// normalizeStatements orders the statements of a policy by their canonical
+// JSON encoding
+func normalizeStatements(value interface{}) []interface{} {
+	statements, ok := value.([]interface{})
+	if !ok {
+		statements = []interface{}{value}
+	}
+
+	for i, statement := range statements {
+		if m, ok := statement.(map[string]interface{}); ok {
+			statements[i] = normalizeStatement(m)
+		}
+	}
+	sort.Slice(statements, func(i, j int) bool {
+		return canonicalJSON(statements[i]) < canonicalJSON(statements[j])
+	})
+	return statements
+}
+
+func normalizeStatement(statement map[string]interface{}) map[string]interface{} {
+	for key, value := range statement {
+		switch {
+		case policyListElements[key]:
+			statement[key] = normalizeList(value)
+		case key == "Principal" || key == "NotPrincipal":
+			// "*" means everyone and is not a list
+			if m, ok := value.(map[string]interface{}); ok {
+				for principalType, principals := range m {
+					list := normalizeList(principals)
+					if principalType == "AWS" {
+						list = normalizeAccountPrincipals(list)
+					}
+					m[principalType] = list
+				}
+			}
+		case key == "Condition":
+			if operators, ok := value.(map[string]interface{}); ok {
+				for _, conditions := range operators {
+					if m, ok := conditions.(map[string]interface{}); ok {
+						for conditionKey, values := range m {
+							m[conditionKey] = normalizeList(values)
+						}
+					}
+				}
+			}
+		}
+	}
+	return statement
+}
+
+// normalizeList turns a single value or a list into a sorted list of
+// distinct values
+func normalizeList(value interface{}) []interface{} {
+	list, ok := value.([]interface{})
+	if !ok {
+		list = []interface{}{value}
+	}
+
+	seen := make(map[string]bool, len(list))
+	result := make([]interface{}, 0, len(list))
+	for _, v := range list {
+		key := canonicalJSON(v)
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, v)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return canonicalJSON(result[i]) < canonicalJSON(result[j])
+	})
+	return result
+}
+
+// normalizeAccountPrincipals rewrites account IDs in a list of AWS
+// principals to the root ARN of the account, which is what IAM returns
+func normalizeAccountPrincipals(principals []interface{}) []interface{} {
+	for i, principal := range principals {
+		if id, ok := principal.(string); ok && awsAccountID.MatchString(id) {
+			principals[i] = "arn:aws:iam::" + id + ":root"
+		}
+	}
+	return normalizeList(principals)
+}
+
+// canonicalJSON encodes a value with sorted map keys
+func canonicalJSON(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// compactPolicy returns the canonical single-line form of a policy document
+// for display, or the document itself when it cannot be parsed
+func compactPolicy(document string) string {
+	normalized, err := normalizePolicy(document)
+	if err != nil {
+		return document
+	}
+	return canonicalJSON(normalized)
+}
+
+// policyStatements returns the normalized statements of a policy document
+func policyStatements(document string) ([]map[string]interface{}, error) {
+	normalized, err := normalizePolicy(document)
+	if err != nil {
+		return nil, err
+	}
+	root, _ := normalized.(map[string]interface{})
+	list, _ := root["Statement"].([]interface{})
+
+	statements := make([]map[string]interface{}, 0, len(list))
+	for _, v := range list {
+		if statement, ok := v.(map[string]interface{}); ok {
+			statements = append(statements, statement)
+		}
+	}
+	return statements, nil
+}
+
+// diffPolicy compares two policy documents statement by statement. Each
+// added or removed statement is a change of field.Statement, or
+// field.Statement[Sid] when it has a Sid; a statement whose actions or
+// resources were edited is reported as one change from the old statement to
+// the new one. A missing document is "" and reported as a single change.
+func diffPolicy(field, expected, actual string) []drift.Change {
+	if expected == "" || actual == "" {
+		if expected == actual {
+			return nil
+		}
+		return []drift.Change{{Field: field, Expected: orAbsent(compactPolicy(expected)), Actual: orAbsent(compactPolicy(actual))}}
+	}
+
+	want, errWant := policyStatements(expected)
+	got, errGot := policyStatements(actual)
+	if errWant != nil || errGot != nil {
+		if expected == actual {
+			return nil
+		}
+		return []drift.Change{{Field: field, Expected: expected, Actual: actual}}
+	}
+
+	inState := make(map[string]bool, len(want))
+	for _, statement := range want {
+		inState[canonicalJSON(statement)] = true
+	}
+	inCloud := make(map[string]bool, len(got))
+	for _, statement := range got {
+		inCloud[canonicalJSON(statement)] = true
+	}
+
+	var removed, added []map[string]interface{}
+	for _, statement := range want {
+		if !inCloud[canonicalJSON(statement)] {
+			removed = append(removed, statement)
+		}
+	}
+	for _, statement := range got {
+		if !inState[canonicalJSON(statement)] {
+			added = append(added, statement)
+		}
+	}
+
+	var changes []drift.Change
+	for _, statement := range added {
+		change := drift.Change{Field: statementField(field, statement), Expected: "absent", Actual: canonicalJSON(statement)}
+		for i, old := range removed {
+			// Statements with the same Sid are edits of each other too
+			sameSid := statement["Sid"] != nil && canonicalJSON(old["Sid"]) == canonicalJSON(statement["Sid"])
+			if sameSid || sameStatementScope(old, statement) {
+				change.Expected = canonicalJSON(old)
+				removed = append(removed[:i], removed[i+1:]...)
+				break
+			}
+		}
+		changes = append(changes, change)
+	}
+	for _, statement := range removed {
+		changes = append(changes, drift.Change{Field: statementField(field, statement), Expected: canonicalJSON(statement), Actual: "absent"})
+	}
+	return changes
+}
+
+func orAbsent(document string) string {
+	if document == "" {
+		return "absent"
+	}
+	return document
+}
+
+func statementField(field string, statement map[string]interface{}) string {
+	if sid, ok := statement["Sid"].(string); ok && sid != "" {
+		return fmt.Sprintf("%s.Statement[%s]", field, sid)
+	}
+	return field + ".Statement"
+}
+
+// sameStatementScope reports whether two statements differ at most in their
+// Sid, actions and resources, i.e. one is an edit of the other
+func sameStatementScope(a, b map[string]interface{}) bool {
+	keys := make(map[string]bool)
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	for key := range keys {
+		if key == "Sid" || key == "Action" || key == "Resource" {
+			continue
+		}
+		if canonicalJSON(a[key]) != canonicalJSON(b[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// statementCovers reports whether outer allows or denies everything inner
+// does: the same scope, with inner's actions and resources all matched by
+// outer's patterns
+func statementCovers(outer, inner map[string]interface{}) bool {
+	if !sameStatementScope(outer, inner) {
+		return false
+	}
+	// IAM actions are case-insensitive, resources are not
+	return patternsCover(outer["Action"], inner["Action"], true) &&
+		patternsCover(outer["Resource"], inner["Resource"], false)
+}
+
+func patternsCover(outer, inner interface{}, fold bool) bool {
+	patterns, _ := outer.([]interface{})
+	values, _ := inner.([]interface{})
+	for _, v := range values {
+		value, _ := v.(string)
+		matched := false
+		for _, p := range patterns {
+			pattern, _ := p.(string)
+			if fold {
+				pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+			}
+			if wildcardMatch(pattern, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// wildcardMatch matches IAM wildcards: * matches any sequence of characters
+// and ? any single character
+func wildcardMatch(pattern, value string) bool {
+	p, v := 0, 0
+	star, next := -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, v
+			p++
+		case star >= 0:
+			p = star + 1
+			next++
+			v = next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// policyStatementSeverity rates a policy statement change: permissions
+// granted outside Terraform, by a new or widened Allow statement or by a
+// removed or narrowed Deny statement, are high; other edits are medium
+func policyStatementSeverity(change drift.Change) string {
+	if !strings.Contains(change.Field, ".Statement") {
+		return ""
+	}
+	before := parseStatement(change.Expected)
+	after := parseStatement(change.Actual)
+
+	switch {
+	case before == nil && after == nil:
+		return ""
+	case before == nil:
+		if after["Effect"] == "Allow" {
+			return "high"
+		}
+	case after == nil:
+		if before["Effect"] == "Deny" {
+			return "high"
+		}
+	default:
+		if after["Effect"] == "Allow" && !statementCovers(before, after) {
+			return "high"
+		}
+		if before["Effect"] == "Deny" && !statementCovers(after, before) {
+			return "high"
+		}
+	}
+	return "medium"
+}
+
+// parseStatement decodes a statement reported by diffPolicy, or returns nil
+// for "absent"
+func parseStatement(value interface{}) map[string]interface{} {
+	s, ok := value.(string)
+	if !ok || !strings.HasPrefix(s, "{") {
+		return nil
+	}
+	var statement map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &statement); err != nil {
+		return nil
+	}
+	return statement
+}
@@ -0,0 +1,248 @@
+package detectors
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+)
+
+func TestNormalizePolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{
+			name: "single value and list",
+			a:    `{"Version":"2012-10-17","Statement":{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}}`,
+			b:    `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["*"]}]}`,
+			same: true,
+		},
+		{
+			name: "list order and duplicates",
+			a:    `{"Statement":[{"Effect":"Allow","Action":["s3:PutObject","s3:GetObject","s3:GetObject"],"Resource":"*"}]}`,
+			b:    `{"Statement":[{"Effect":"Allow","Action":["s3:GetObject","s3:PutObject"],"Resource":"*"}]}`,
+			same: true,
+		},
+		{
+			name: "statement order",
+			a:    `{"Statement":[{"Effect":"Allow","Action":"a:A","Resource":"*"},{"Effect":"Deny","Action":"b:B","Resource":"*"}]}`,
+			b:    `{"Statement":[{"Effect":"Deny","Action":"b:B","Resource":"*"},{"Effect":"Allow","Action":"a:A","Resource":"*"}]}`,
+			same: true,
+		},
+		{
+			name: "principal and condition values",
+			a:    `{"Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::1:root"},"Action":"sts:AssumeRole","Condition":{"StringEquals":{"sts:ExternalId":"x"}}}]}`,
+			b:    `{"Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::1:root"]},"Action":"sts:AssumeRole","Condition":{"StringEquals":{"sts:ExternalId":["x"]}}}]}`,
+			same: true,
+		},
+		{
+			name: "account ID principal",
+			a:    `{"Statement":[{"Effect":"Allow","Principal":{"AWS":"123456789012"},"Action":"sts:AssumeRole"}]}`,
+			b:    `{"Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::123456789012:root"},"Action":"sts:AssumeRole"}]}`,
+			same: true,
+		},
+		{
+			name: "account IDs in a principal list",
+			a:    `{"Statement":[{"Effect":"Allow","Principal":{"AWS":["210987654321","arn:aws:iam::123456789012:role/ci","210987654321"]},"Action":"sts:AssumeRole"}]}`,
+			b:    `{"Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::123456789012:role/ci","arn:aws:iam::210987654321:root"]},"Action":"sts:AssumeRole"}]}`,
+			same: true,
+		},
+		{
+			name: "different account principal",
+			a:    `{"Statement":[{"Effect":"Allow","Principal":{"AWS":"123456789012"},"Action":"sts:AssumeRole"}]}`,
+			b:    `{"Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::210987654321:root"]},"Action":"sts:AssumeRole"}]}`,
+			same: false,
+		},
+		{
+			name: "service principal string and list",
+			a:    `{"Statement":[{"Effect":"Allow","Principal":{"Service":"lambda.amazonaws.com"},"Action":"sts:AssumeRole"}]}`,
+			b:    `{"Statement":[{"Effect":"Allow","Principal":{"Service":["lambda.amazonaws.com"]},"Action":"sts:AssumeRole"}]}`,
+			same: true,
+		},
+		{
+			name: "URL-encoded document from IAM",
+			a:    `%7B%22Statement%22%3A%5B%7B%22Effect%22%3A%22Allow%22%2C%22Action%22%3A%22s3%3A%2A%22%2C%22Resource%22%3A%22%2A%22%7D%5D%7D`,
+			b:    `{"Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"*"}]}`,
+			same: true,
+		},
+		{
+			name: "different action",
+			a:    `{"Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"*"}]}`,
+			b:    `{"Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"*"}]}`,
+			same: false,
+		},
+		{
+			name: "principal wildcard is not a list",
+			a:    `{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*"}]}`,
+			b:    `{"Statement":[{"Effect":"Allow","Principal":["*"],"Action":"s3:GetObject","Resource":"*"}]}`,
+			same: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := normalizePolicy(tt.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := normalizePolicy(tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if same := canonicalJSON(a) == canonicalJSON(b); same != tt.same {
+				t.Errorf("equal = %v, want %v:\n%s\n%s", same, tt.same, canonicalJSON(a), canonicalJSON(b))
+			}
+		})
+	}
+
+	if _, err := normalizePolicy(`{"Statement": [`); err == nil {
+		t.Error("normalizePolicy() accepted an invalid document")
+	}
+}
+
+func TestDiffPolicy(t *testing.T) {
+	const readOnly = `{"Sid":"Read","Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*"}`
+	policy := func(statements ...string) string {
+		doc := `{"Version":"2012-10-17","Statement":[`
+		for i, s := range statements {
+			if i > 0 {
+				doc += ","
+			}
+			doc += s
+		}
+		return doc + `]}`
+	}
+
+	tests := []struct {
+		name             string
+		expected, actual string
+		want             []drift.Change
+	}{
+		{
+			name:     "layout only",
+			expected: policy(readOnly),
+			actual:   `{"Version":"2012-10-17","Statement":{"Resource":["arn:aws:s3:::b/*"],"Action":["s3:GetObject"],"Effect":"Allow","Sid":"Read"}}`,
+		},
+		{
+			name:     "statement added",
+			expected: policy(readOnly),
+			actual:   policy(readOnly, `{"Effect":"Allow","Action":"s3:DeleteObject","Resource":"*"}`),
+			want: []drift.Change{{
+				Field:    "policy.Statement",
+				Expected: "absent",
+				Actual:   `{"Action":["s3:DeleteObject"],"Effect":"Allow","Resource":["*"]}`,
+			}},
+		},
+		{
+			name:     "statement removed",
+			expected: policy(readOnly, `{"Sid":"Deny","Effect":"Deny","Action":"s3:DeleteBucket","Resource":"*"}`),
+			actual:   policy(readOnly),
+			want: []drift.Change{{
+				Field:    "policy.Statement[Deny]",
+				Expected: `{"Action":["s3:DeleteBucket"],"Effect":"Deny","Resource":["*"],"Sid":"Deny"}`,
+				Actual:   "absent",
+			}},
+		},
+		{
+			name:     "statement edited in place",
+			expected: policy(readOnly),
+			actual:   policy(`{"Sid":"Read","Effect":"Allow","Action":["s3:GetObject","s3:PutObject"],"Resource":"arn:aws:s3:::b/*"}`),
+			want: []drift.Change{{
+				Field:    "policy.Statement[Read]",
+				Expected: `{"Action":["s3:GetObject"],"Effect":"Allow","Resource":["arn:aws:s3:::b/*"],"Sid":"Read"}`,
+				Actual:   `{"Action":["s3:GetObject","s3:PutObject"],"Effect":"Allow","Resource":["arn:aws:s3:::b/*"],"Sid":"Read"}`,
+			}},
+		},
+		{
+			name:     "same Sid with a new principal",
+			expected: policy(`{"Sid":"Trust","Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}`),
+			actual:   policy(`{"Sid":"Trust","Effect":"Allow","Principal":{"AWS":"*"},"Action":"sts:AssumeRole"}`),
+			want: []drift.Change{{
+				Field:    "policy.Statement[Trust]",
+				Expected: `{"Action":["sts:AssumeRole"],"Effect":"Allow","Principal":{"Service":["ec2.amazonaws.com"]},"Sid":"Trust"}`,
+				Actual:   `{"Action":["sts:AssumeRole"],"Effect":"Allow","Principal":{"AWS":["*"]},"Sid":"Trust"}`,
+			}},
+		},
+		{
+			name:     "policy attached outside Terraform",
+			expected: "",
+			actual:   `{"Statement":{"Effect":"Allow","Action":"*","Resource":"*"}}`,
+			want: []drift.Change{{
+				Field:    "policy",
+				Expected: "absent",
+				Actual:   `{"Statement":[{"Action":["*"],"Effect":"Allow","Resource":["*"]}]}`,
+			}},
+		},
+		{
+			name:     "unparseable documents compared as text",
+			expected: "not json",
+			actual:   "still not json",
+			want:     []drift.Change{{Field: "policy", Expected: "not json", Actual: "still not json"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffPolicy("policy", tt.expected, tt.actual); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffPolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyStatementSeverity(t *testing.T) {
+	const (
+		allowRead  = `{"Action":["s3:GetObject"],"Effect":"Allow","Resource":["arn:aws:s3:::b/*"]}`
+		allowAll   = `{"Action":["s3:*"],"Effect":"Allow","Resource":["arn:aws:s3:::b/*"]}`
+		allowGet   = `{"Action":["S3:Get*"],"Effect":"Allow","Resource":["arn:aws:s3:::b/*"]}`
+		denyDelete = `{"Action":["s3:DeleteObject","s3:DeleteBucket"],"Effect":"Deny","Resource":["*"]}`
+		denyOne    = `{"Action":["s3:DeleteBucket"],"Effect":"Deny","Resource":["*"]}`
+	)
+
+	tests := []struct {
+		name             string
+		field            string
+		expected, actual string
+		want             string
+	}{
+		{"allow added", "policy.Statement", "absent", allowRead, "high"},
+		{"deny added", "policy.Statement", "absent", denyOne, "medium"},
+		{"allow removed", "policy.Statement", allowRead, "absent", "medium"},
+		{"deny removed", "policy.Statement", denyOne, "absent", "high"},
+		{"allow widened", "policy.Statement", allowRead, allowAll, "high"},
+		{"allow narrowed", "policy.Statement", allowAll, allowRead, "medium"},
+		{"allow narrowed with wildcard, actions case-insensitive", "policy.Statement", allowGet, allowRead, "medium"},
+		{"deny narrowed", "policy.Statement", denyDelete, denyOne, "high"},
+		{"deny widened", "policy.Statement", denyOne, denyDelete, "medium"},
+		{"whole document", "policy", "absent", allowRead, ""},
+		{"not a policy", "tags.Name", "a", "b", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := drift.Change{Field: tt.field, Expected: tt.expected, Actual: tt.actual}
+			if got := policyStatementSeverity(change); got != tt.want {
+				t.Errorf("policyStatementSeverity() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"*", "anything", true},
+		{"s3:Get*", "s3:GetObject", true},
+		{"s3:Get*", "s3:PutObject", false},
+		{"arn:aws:s3:::b/*", "arn:aws:s3:::b/key/nested", true},
+		{"s3:?etObject", "s3:GetObject", true},
+		{"s3:GetObject", "s3:GetObjectAcl", false},
+		{"*Object*", "s3:GetObjectAcl", true},
+	}
+	for _, tt := range tests {
+		if got := wildcardMatch(tt.pattern, tt.value); got != tt.want {
+			t.Errorf("wildcardMatch(%q, %q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
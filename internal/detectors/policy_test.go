@@ -0,0 +1,134 @@
+package detectors
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewAPIPolicyDefaults(t *testing.T) {
+	p := NewAPIPolicy(APIPolicyConfig{})
+	if p.MaxAttempts() != DefaultMaxAttempts {
+		t.Errorf("MaxAttempts() = %d, want %d", p.MaxAttempts(), DefaultMaxAttempts)
+	}
+	if p.retry.BaseDelay != DefaultBaseDelay || p.retry.MaxDelay != DefaultMaxDelay {
+		t.Errorf("delays = %v/%v, want %v/%v", p.retry.BaseDelay, p.retry.MaxDelay, DefaultBaseDelay, DefaultMaxDelay)
+	}
+}
+
+func TestAPIPolicyBackoff(t *testing.T) {
+	p := NewAPIPolicy(APIPolicyConfig{Retry: RetryPolicy{
+		BaseDelay: 100 * time.Millisecond,
+		MaxDelay:  time.Second,
+	}})
+
+	tests := []struct {
+		attempt int
+		window  time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{10, time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			if d := p.Backoff(tt.attempt); d < 0 || d > tt.window {
+				t.Fatalf("Backoff(%d) = %v, want within [0, %v]", tt.attempt, d, tt.window)
+			}
+		}
+	}
+}
+
+func TestAPIPolicyLimiter(t *testing.T) {
+	p := NewAPIPolicy(APIPolicyConfig{RateLimits: map[string]ProviderRateLimit{
+		"aws": {
+			RateLimit: RateLimit{RequestsPerSecond: 10},
+			Regions:   map[string]RateLimit{"us-east-1": {RequestsPerSecond: 2, Burst: 5}},
+		},
+		"default": {RateLimit: RateLimit{RequestsPerSecond: 1}},
+	}})
+
+	tests := []struct {
+		provider, region string
+		rate, burst      float64
+	}{
+		{"aws", "eu-west-1", 10, 10},
+		{"aws", "us-east-1", 2, 5},
+		{"gcp", "europe-west1", 1, 1},
+	}
+	for _, tt := range tests {
+		b := p.Limiter(tt.provider, tt.region)
+		if b.rate != tt.rate || b.burst != tt.burst {
+			t.Errorf("Limiter(%s, %s) = rate %v burst %v, want %v %v", tt.provider, tt.region, b.rate, b.burst, tt.rate, tt.burst)
+		}
+	}
+
+	if p.Limiter("aws", "eu-west-1") != p.Limiter("aws", "eu-west-1") {
+		t.Error("Limiter() returned a new bucket for the same provider and region")
+	}
+	if p.Limiter("aws", "eu-west-1") == p.Limiter("aws", "eu-central-1") {
+		t.Error("Limiter() shared a bucket between regions")
+	}
+}
+
+func TestTokenBucketBurstThenRate(t *testing.T) {
+	b := NewTokenBucket(RateLimit{RequestsPerSecond: 20, Burst: 3})
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := b.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Millisecond {
+		t.Errorf("burst of 3 took %v, want no wait", elapsed)
+	}
+
+	// The bucket is empty; two more tokens take about 100ms at 20/s
+	start = time.Now()
+	for i := 0; i < 2; i++ {
+		if err := b.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("2 tokens after the burst took %v, want about 100ms", elapsed)
+	}
+}
+
+func TestTokenBucketUnlimited(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var nilBucket *TokenBucket
+	for _, b := range []*TokenBucket{nilBucket, NewTokenBucket(RateLimit{})} {
+		for i := 0; i < 100; i++ {
+			if err := b.Wait(ctx); err != nil {
+				t.Fatalf("Wait() = %v, want no limit", err)
+			}
+		}
+	}
+}
+
+func TestTokenBucketWaitCancelled(t *testing.T) {
+	b := NewTokenBucket(RateLimit{RequestsPerSecond: 0.1, Burst: 1})
+	if err := b.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := b.Wait(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Wait() returned after %v, want it to stop at the deadline", elapsed)
+	}
+}
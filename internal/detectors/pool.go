@@ -0,0 +1,77 @@
+package detectors
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultWorkers is the number of concurrent checks per provider and region
+// when none is configured
+const DefaultWorkers = 10
+
+// workerPool bounds the number of concurrent checks per key, such as a
+// provider region, so one slow region does not hold up the others
+type workerPool struct {
+	size int
+
+	mu   sync.Mutex
+	sems map[string]chan struct{}
+}
+
+func newWorkerPool(size int) *workerPool {
+	if size <= 0 {
+		size = DefaultWorkers
+	}
+	return &workerPool{
+		size: size,
+		sems: make(map[string]chan struct{}),
+	}
+}
+
+func (p *workerPool) semaphore(key string) chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sem, ok := p.sems[key]
+	if !ok {
+		sem = make(chan struct{}, p.size)
+		p.sems[key] = sem
+	}
+	return sem
+}
+
+// runPool calls fn for each index 0..n-1 concurrently, with at most the pool
+// size running at once per key. Results are returned in index order. Tasks
+// not yet started when ctx is cancelled are skipped and ctx.Err() is
+// returned.
+func runPool[T any](ctx context.Context, pool *workerPool, n int, key func(i int) string, fn func(ctx context.Context, i int) T) ([]T, error) {
+	results := make([]T, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem := pool.semaphore(key(i))
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			if ctx.Err() != nil {
+				return
+			}
+			results[i] = fn(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,94 @@
+package detectors
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunPoolKeepsIndexOrder(t *testing.T) {
+	pool := newWorkerPool(4)
+	const n = 8
+
+	// Later items finish first
+	results, err := runPool(context.Background(), pool, n,
+		func(i int) string { return "us-east-1" },
+		func(ctx context.Context, i int) int {
+			time.Sleep(time.Duration(n-i) * 5 * time.Millisecond)
+			return i * 10
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, got := range results {
+		if got != i*10 {
+			t.Fatalf("results[%d] = %d, want %d (results %v)", i, got, i*10, results)
+		}
+	}
+}
+
+func TestRunPoolBoundsConcurrencyPerKey(t *testing.T) {
+	pool := newWorkerPool(2)
+	var running, peak int32
+
+	_, err := runPool(context.Background(), pool, 10,
+		func(i int) string { return "eu-west-1" },
+		func(ctx context.Context, i int) struct{} {
+			now := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if now <= old || atomic.CompareAndSwapInt32(&peak, old, now) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return struct{}{}
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peak > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", peak)
+	}
+}
+
+func TestRunPoolCancellation(t *testing.T) {
+	pool := newWorkerPool(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var started int32
+	done := make(chan struct{})
+	var results []int
+	var err error
+	go func() {
+		defer close(done)
+		results, err = runPool(ctx, pool, 20,
+			func(i int) string { return "us-west-2" },
+			func(ctx context.Context, i int) int {
+				if atomic.AddInt32(&started, 1) == 3 {
+					cancel()
+				}
+				return i
+			})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runPool did not return after cancellation")
+	}
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("runPool() error = %v, want context.Canceled", err)
+	}
+	if results != nil {
+		t.Errorf("runPool() results = %v, want nil", results)
+	}
+	if n := atomic.LoadInt32(&started); n >= 20 {
+		t.Errorf("%d items started, want the remaining items skipped", n)
+	}
+}
@@ -0,0 +1,135 @@
+package detectors
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+	log "github.com/sirupsen/logrus"
+)
+
+// TerraformDetector surfaces the drift Terraform itself reported while
+// planning (resource_drift in `terraform show -json` plan output)
+type TerraformDetector struct{}
+
+// NewTerraformDetector creates a new Terraform-reported drift detector
+func NewTerraformDetector() *TerraformDetector {
+	return &TerraformDetector{}
+}
+
+// Name returns the detector name
+func (d *TerraformDetector) Name() string {
+	return "Terraform"
+}
+
+// Detect converts reported drift into drift items
+func (d *TerraformDetector) Detect(ctx context.Context, state *terraform.State) ([]drift.DriftItem, error) {
+	var drifts []drift.DriftItem
+
+	log.Debugf("Collecting %d drift(s) reported by Terraform", len(state.ReportedDrift))
+
+	for _, reported := range state.ReportedDrift {
+		var changes []drift.Change
+
+		if reported.After == nil || containsAction(reported.Actions, "delete") {
+			changes = append(changes, drift.Change{
+				Field:    "existence",
+				Expected: "exists",
+				Actual:   "deleted",
+			})
+		} else {
+			changes = diffAttributes(reported.Before, reported.After, reported.BeforeSensitive, reported.AfterSensitive)
+		}
+
+		if len(changes) == 0 {
+			continue
+		}
+
+		id, _ := reported.Before["id"].(string)
+		drifts = append(drifts, drift.DriftItem{
+			ResourceType: reported.Type,
+			ResourceName: reported.Name,
+			Address:      reported.Address,
+			ResourceID:   id,
+			Provider:     "Terraform",
+			Severity:     determineSeverity(changes),
+			Changes:      changes,
+		})
+	}
+
+	return drifts, nil
+}
+
+// diffAttributes compares two attribute maps key by key, in sorted key
+// order. Nested maps are compared per key so fields read like tags.Name.
+// Values marked sensitive on either side are replaced in both, so a changed
+// secret is reported without revealing it.
+func diffAttributes(expected, actual map[string]interface{}, expectedSensitive, actualSensitive interface{}) []drift.Change {
+	return diffAttributesWithPrefix("", expected, actual, expectedSensitive, actualSensitive)
+}
+
+func diffAttributesWithPrefix(prefix string, expected, actual map[string]interface{}, expectedSensitive, actualSensitive interface{}) []drift.Change {
+	keys := make(map[string]bool)
+	for k := range expected {
+		keys[k] = true
+	}
+	for k := range actual {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var changes []drift.Change
+	for _, k := range sorted {
+		if reflect.DeepEqual(expected[k], actual[k]) {
+			continue
+		}
+		expectedKeySensitive := sensitiveChild(expectedSensitive, k)
+		actualKeySensitive := sensitiveChild(actualSensitive, k)
+
+		expectedMap, expectedIsMap := expected[k].(map[string]interface{})
+		actualMap, actualIsMap := actual[k].(map[string]interface{})
+		if expectedIsMap && actualIsMap {
+			changes = append(changes, diffAttributesWithPrefix(prefix+k+".", expectedMap, actualMap, expectedKeySensitive, actualKeySensitive)...)
+			continue
+		}
+		changes = append(changes, drift.Change{
+			Field:    prefix + k,
+			Expected: redactEither(expected[k], expectedKeySensitive, actualKeySensitive),
+			Actual:   redactEither(actual[k], expectedKeySensitive, actualKeySensitive),
+		})
+	}
+	return changes
+}
+
+// sensitiveChild returns the sensitivity tree of a key: all of it when the
+// parent is sensitive as a whole
+func sensitiveChild(sensitive interface{}, key string) interface{} {
+	switch s := sensitive.(type) {
+	case bool:
+		return s
+	case map[string]interface{}:
+		return s[key]
+	}
+	return nil
+}
+
+// redactEither redacts what either side marks sensitive
+func redactEither(value, expectedSensitive, actualSensitive interface{}) interface{} {
+	return terraform.RedactSensitive(terraform.RedactSensitive(value, expectedSensitive), actualSensitive)
+}
+
+func containsAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package detectors
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/MeowTux/drift-detector/internal/drift"
+	"github.com/MeowTux/drift-detector/internal/terraform"
+)
+
+func TestDiffAttributesRedactsSensitiveValues(t *testing.T) {
+	before := map[string]interface{}{
+		"password": "old-secret",
+		"port":     float64(5432),
+		"tags":     map[string]interface{}{"Name": "db", "Token": "abc"},
+	}
+	after := map[string]interface{}{
+		"password": "new-secret",
+		"port":     float64(5433),
+		"tags":     map[string]interface{}{"Name": "db-2", "Token": "xyz"},
+	}
+	beforeSensitive := map[string]interface{}{
+		"password": true,
+		"tags":     map[string]interface{}{"Token": true},
+	}
+	// after_sensitive can drop a mark the before side has, the value stays
+	// hidden on both sides
+	afterSensitive := map[string]interface{}{"password": true}
+
+	got := diffAttributes(before, after, beforeSensitive, afterSensitive)
+	want := []drift.Change{
+		{Field: "password", Expected: terraform.SensitiveValue, Actual: terraform.SensitiveValue},
+		{Field: "port", Expected: float64(5432), Actual: float64(5433)},
+		{Field: "tags.Name", Expected: "db", Actual: "db-2"},
+		{Field: "tags.Token", Expected: terraform.SensitiveValue, Actual: terraform.SensitiveValue},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffAttributes() = %#v, want %#v", got, want)
+	}
+}
+
+func TestDiffAttributesRedactsWholeBlock(t *testing.T) {
+	before := map[string]interface{}{
+		"environment": []interface{}{map[string]interface{}{"variables": map[string]interface{}{"KEY": "a"}}},
+	}
+	after := map[string]interface{}{
+		"environment": []interface{}{map[string]interface{}{"variables": map[string]interface{}{"KEY": "b"}}},
+	}
+	sensitive := map[string]interface{}{
+		"environment": []interface{}{map[string]interface{}{"variables": true}},
+	}
+
+	got := diffAttributes(before, after, sensitive, sensitive)
+	want := []drift.Change{{
+		Field:    "environment",
+		Expected: []interface{}{map[string]interface{}{"variables": terraform.SensitiveValue}},
+		Actual:   []interface{}{map[string]interface{}{"variables": terraform.SensitiveValue}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("diffAttributes() = %#v, want %#v", got, want)
+	}
+}
@@ -1,24 +1,103 @@
 package drift
 
 import (
+	"fmt"
 	"time"
 )
 
+// Origins of a change, set when state is also compared against configuration
+// or when the states scanned are incomplete
+const (
+	// OriginCloud means the live resource differs from state and
+	// configuration, e.g. a change made in the console
+	OriginCloud = "cloud"
+	// OriginState means the state disagrees with configuration while the
+	// live resource matches one of them, e.g. after a refresh picked up an
+	// out-of-band change or when code has not been applied yet
+	OriginState = "state"
+	// OriginCloudAndState means configuration, state and live resource all differ
+	OriginCloudAndState = "cloud+state"
+	// OriginUnknown means the change may be managed in a state that could
+	// not be loaded, so it cannot be attributed to a change outside Terraform
+	OriginUnknown = "unknown"
+)
+
 // Change represents a single configuration change
 type Change struct {
 	Field    string      `json:"field"`
 	Expected interface{} `json:"expected"`
 	Actual   interface{} `json:"actual"`
+
+	// Configured is the value from the Terraform configuration and Origin
+	// classifies the change; both are only set when configuration is compared
+	Configured interface{} `json:"configured,omitempty"`
+	Origin     string      `json:"origin,omitempty"`
+
+	// Ignored is set when the field is covered by lifecycle ignore_changes;
+	// ignored changes are reported but do not count as drift
+	Ignored bool `json:"ignored,omitempty"`
 }
 
 // DriftItem represents a drifted resource
 type DriftItem struct {
 	ResourceType string   `json:"resource_type"`
 	ResourceName string   `json:"resource_name"`
+	Address      string   `json:"address,omitempty"`
 	ResourceID   string   `json:"resource_id,omitempty"`
 	Provider     string   `json:"provider"`
+	Region       string   `json:"region,omitempty"`
+	AccountID    string   `json:"account_id,omitempty"`
 	Severity     string   `json:"severity"` // critical, high, medium, low
 	Changes      []Change `json:"changes"`
+	State        string   `json:"state,omitempty"`
+	Workspace    string   `json:"workspace,omitempty"`
+}
+
+// ActiveChanges returns the changes not ignored by lifecycle ignore_changes
+func (d DriftItem) ActiveChanges() []Change {
+	var active []Change
+	for _, c := range d.Changes {
+		if !c.Ignored {
+			active = append(active, c)
+		}
+	}
+	return active
+}
+
+// Classes of scan errors
+const (
+	// ErrorClassThrottled means the provider kept throttling requests
+	// after all retries
+	ErrorClassThrottled = "throttled"
+	// ErrorClassAccessDenied means the credentials lack permission
+	ErrorClassAccessDenied = "access-denied"
+	// ErrorClassNotFoundAmbiguous means the resource could not be found but
+	// the response does not prove it was deleted
+	ErrorClassNotFoundAmbiguous = "not-found-ambiguous"
+	// ErrorClassNetwork means the provider could not be reached
+	ErrorClassNetwork = "network"
+	// ErrorClassDetector means a whole detector failed
+	ErrorClassDetector = "detector-failed"
+	// ErrorClassStateDecrypt means a state could not be decrypted
+	ErrorClassStateDecrypt = "state-decrypt-failed"
+	// ErrorClassStateInvalid means a state was fetched but is not a valid
+	// Terraform state
+	ErrorClassStateInvalid = "state-invalid"
+	// ErrorClassUnknown covers any other error
+	ErrorClassUnknown = "unknown"
+)
+
+// ScanError records a resource that could not be checked, leaving it
+// unverified. An empty Address means the error affected a whole detector,
+// or with the Terraform provider a whole state that could not be loaded.
+type ScanError struct {
+	Address   string `json:"address,omitempty"`
+	Provider  string `json:"provider"`
+	AccountID string `json:"account_id,omitempty"`
+	Class     string `json:"class"`
+	Message   string `json:"message"`
+	State     string `json:"state,omitempty"`
+	Workspace string `json:"workspace,omitempty"`
 }
 
 // Report represents a drift detection report
@@ -27,6 +106,49 @@ type Report struct {
 	TotalResources int         `json:"total_resources"`
 	Drifts         []DriftItem `json:"drifts"`
 	Summary        string      `json:"summary"`
+
+	// Ignored holds resources whose every change is covered by lifecycle
+	// ignore_changes
+	Ignored []DriftItem `json:"ignored,omitempty"`
+
+	// ScanErrors lists the resources that could not be checked, so a
+	// report without drift is not mistaken for full coverage
+	ScanErrors []ScanError `json:"scan_errors,omitempty"`
+}
+
+// StateGroup holds the drifts found in a single state and workspace
+type StateGroup struct {
+	State     string      `json:"state"`
+	Workspace string      `json:"workspace,omitempty"`
+	Drifts    []DriftItem `json:"drifts"`
+}
+
+// GroupByState groups drifts by the state and workspace they came from,
+// preserving the order in which states first appear
+func (r *Report) GroupByState() []StateGroup {
+	var groups []StateGroup
+	index := make(map[string]int)
+
+	for _, d := range r.Drifts {
+		key := d.State + "\x00" + d.Workspace
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, StateGroup{State: d.State, Workspace: d.Workspace})
+		}
+		groups[i].Drifts = append(groups[i].Drifts, d)
+	}
+
+	return groups
+}
+
+// StateLabel formats a state location with its workspace
+func StateLabel(state, workspace string) string {
+	if workspace == "" || workspace == "default" {
+		return state
+	}
+	return state + " [" + workspace + "]"
 }
 
 // Analyzer analyzes drift results
@@ -37,15 +159,24 @@ func NewAnalyzer() *Analyzer {
 	return &Analyzer{}
 }
 
-// GenerateReport generates a drift report
-func (a *Analyzer) GenerateReport(drifts []DriftItem) *Report {
+// GenerateReport generates a drift report. Resources whose changes are all
+// ignored by lifecycle ignore_changes are moved to the report's Ignored list.
+func (a *Analyzer) GenerateReport(items []DriftItem, scanErrors []ScanError, totalResources int) *Report {
 	report := &Report{
-		Timestamp: time.Now(),
-		Drifts:    drifts,
+		Timestamp:      time.Now(),
+		TotalResources: totalResources,
+		ScanErrors:     scanErrors,
 	}
 
-	// Calculate total resources (this would be from state in real implementation)
-	report.TotalResources = len(drifts) + 100 // Placeholder
+	var drifts []DriftItem
+	for _, d := range items {
+		if len(d.Changes) > 0 && len(d.ActiveChanges()) == 0 {
+			report.Ignored = append(report.Ignored, d)
+			continue
+		}
+		drifts = append(drifts, d)
+	}
+	report.Drifts = drifts
 
 	// Generate summary
 	if len(drifts) == 0 {
@@ -72,6 +203,13 @@ func (a *Analyzer) GenerateReport(drifts []DriftItem) *Report {
 		report.Summary = formatSummary(len(drifts), critical, high, medium, low)
 	}
 
+	if len(scanErrors) > 0 {
+		if len(drifts) == 0 {
+			report.Summary = "No drift detected in the verified resources."
+		}
+		report.Summary += fmt.Sprintf(" %d resource(s) could not be verified.", len(scanErrors))
+	}
+
 	return report
 }
 
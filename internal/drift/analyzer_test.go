@@ -0,0 +1,56 @@
+package drift
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupByState(t *testing.T) {
+	report := &Report{Drifts: []DriftItem{
+		{ResourceName: "a", State: "prod.tfstate"},
+		{ResourceName: "b", State: "dev.tfstate"},
+		{ResourceName: "c", State: "prod.tfstate", Workspace: "eu"},
+		{ResourceName: "d", State: "prod.tfstate"},
+	}}
+
+	groups := report.GroupByState()
+
+	type group struct {
+		state, workspace string
+		names            []string
+	}
+	var got []group
+	for _, g := range groups {
+		var names []string
+		for _, d := range g.Drifts {
+			names = append(names, d.ResourceName)
+		}
+		got = append(got, group{g.State, g.Workspace, names})
+	}
+
+	want := []group{
+		{"prod.tfstate", "", []string{"a", "d"}},
+		{"dev.tfstate", "", []string{"b"}},
+		{"prod.tfstate", "eu", []string{"c"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupByState() = %+v, want %+v", got, want)
+	}
+}
+
+func TestStateLabel(t *testing.T) {
+	tests := []struct {
+		state, workspace string
+		want             string
+	}{
+		{"prod.tfstate", "", "prod.tfstate"},
+		{"prod.tfstate", "default", "prod.tfstate"},
+		{"s3://bucket/key", "staging", "s3://bucket/key [staging]"},
+	}
+
+	for _, tt := range tests {
+		if got := StateLabel(tt.state, tt.workspace); got != tt.want {
+			t.Errorf("StateLabel(%q, %q) = %q, want %q", tt.state, tt.workspace, got, tt.want)
+		}
+	}
+}
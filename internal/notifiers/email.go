@@ -3,6 +3,7 @@ package notifiers
 import (
 	"context"
 	"fmt"
+	"html"
 	"strings"
 
 	"github.com/MeowTux/drift-detector/internal/drift"
@@ -38,6 +39,9 @@ func (n *EmailNotifier) Send(ctx context.Context, report *drift.Report) error {
 	}
 
 	subject := fmt.Sprintf("[DRIFT ALERT] %d resource(s) drifted", len(report.Drifts))
+	if len(report.ScanErrors) > 0 {
+		subject += fmt.Sprintf(", %d unverified", len(report.ScanErrors))
+	}
 	body := n.buildEmailBody(report)
 
 	log.Debugf("Sending email to %v", n.to)
@@ -56,21 +60,24 @@ func (n *EmailNotifier) buildEmailBody(report *drift.Report) string {
 	sb.WriteString("<html><body>")
 	sb.WriteString("<h2>🔍 Infrastructure Drift Detection Report</h2>")
 	
-	if len(report.Drifts) == 0 {
+	if len(report.Drifts) == 0 && len(report.ScanErrors) > 0 {
+		sb.WriteString("<p>No drift detected in the verified resources.</p>")
+	} else if len(report.Drifts) == 0 {
 		sb.WriteString("<p style='color: green;'>✓ No drift detected. Infrastructure is in sync!</p>")
 	} else {
 		sb.WriteString(fmt.Sprintf("<p style='color: red;'>⚠ Drift detected in %d resource(s)</p>", len(report.Drifts)))
 		
 		sb.WriteString("<table border='1' cellpadding='10' cellspacing='0'>")
-		sb.WriteString("<tr><th>Resource</th><th>Type</th><th>Provider</th><th>Changes</th></tr>")
+		sb.WriteString("<tr><th>Resource</th><th>Type</th><th>Provider</th><th>State</th><th>Changes</th></tr>")
 		
 		for _, d := range report.Drifts {
 			sb.WriteString("<tr>")
-			sb.WriteString(fmt.Sprintf("<td>%s</td>", d.ResourceName))
+			sb.WriteString(fmt.Sprintf("<td>%s</td>", html.EscapeString(d.Address)))
 			sb.WriteString(fmt.Sprintf("<td>%s</td>", d.ResourceType))
 			sb.WriteString(fmt.Sprintf("<td>%s</td>", d.Provider))
+			sb.WriteString(fmt.Sprintf("<td>%s</td>", drift.StateLabel(d.State, d.Workspace)))
 			sb.WriteString("<td><ul>")
-			for _, change := range d.Changes {
+			for _, change := range d.ActiveChanges() {
 				sb.WriteString(fmt.Sprintf("<li>%s: %v → %v</li>", change.Field, change.Expected, change.Actual))
 			}
 			sb.WriteString("</ul></td>")
@@ -80,6 +87,25 @@ func (n *EmailNotifier) buildEmailBody(report *drift.Report) string {
 		sb.WriteString("</table>")
 	}
 
+	if len(report.ScanErrors) > 0 {
+		sb.WriteString(fmt.Sprintf("<p style='color: orange;'>✗ %d resource(s) could not be verified</p>", len(report.ScanErrors)))
+
+		sb.WriteString("<table border='1' cellpadding='10' cellspacing='0'>")
+		sb.WriteString("<tr><th>Resource</th><th>Provider</th><th>State</th><th>Class</th><th>Error</th></tr>")
+
+		for _, e := range report.ScanErrors {
+			sb.WriteString("<tr>")
+			sb.WriteString(fmt.Sprintf("<td>%s</td>", html.EscapeString(e.Address)))
+			sb.WriteString(fmt.Sprintf("<td>%s</td>", e.Provider))
+			sb.WriteString(fmt.Sprintf("<td>%s</td>", drift.StateLabel(e.State, e.Workspace)))
+			sb.WriteString(fmt.Sprintf("<td>%s</td>", e.Class))
+			sb.WriteString(fmt.Sprintf("<td>%s</td>", html.EscapeString(e.Message)))
+			sb.WriteString("</tr>")
+		}
+
+		sb.WriteString("</table>")
+	}
+
 	sb.WriteString("<hr>")
 	sb.WriteString("<p><small>Sent by Drift Detector | ")
 	sb.WriteString(fmt.Sprintf("Report generated at %s</small></p>", report.Timestamp.Format("2006-01-02 15:04:05")))
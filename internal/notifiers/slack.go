@@ -60,7 +60,12 @@ func (n *SlackNotifier) Send(ctx context.Context, report *drift.Report) error {
 
 func (n *SlackNotifier) buildMessage(report *drift.Report) map[string]interface{} {
 	color := "danger"
-	if len(report.Drifts) == 0 {
+	text := "Infrastructure Drift Detected"
+	switch {
+	case len(report.Drifts) == 0 && len(report.ScanErrors) > 0:
+		color = "warning"
+		text = "Infrastructure Drift Scan Incomplete"
+	case len(report.Drifts) == 0:
 		color = "good"
 	}
 
@@ -76,6 +81,13 @@ func (n *SlackNotifier) buildMessage(report *drift.Report) map[string]interface{
 			"short": true,
 		},
 	}
+	if len(report.ScanErrors) > 0 {
+		fields = append(fields, map[string]interface{}{
+			"title": "Unverified Resources",
+			"value": fmt.Sprintf("%d", len(report.ScanErrors)),
+			"short": true,
+		})
+	}
 
 	var driftDetails string
 	for i, d := range report.Drifts {
@@ -83,12 +95,30 @@ func (n *SlackNotifier) buildMessage(report *drift.Report) map[string]interface{
 			driftDetails += fmt.Sprintf("\n... and %d more", len(report.Drifts)-5)
 			break
 		}
-		driftDetails += fmt.Sprintf("\n• *%s* (%s)\n", d.ResourceName, d.ResourceType)
-		for _, change := range d.Changes {
+		driftDetails += fmt.Sprintf("\n• *%s*\n", d.Address)
+		if d.State != "" {
+			driftDetails += fmt.Sprintf("  _state: %s_\n", drift.StateLabel(d.State, d.Workspace))
+		}
+		for _, change := range d.ActiveChanges() {
 			driftDetails += fmt.Sprintf("  - %s: `%v` → `%v`\n", change.Field, change.Expected, change.Actual)
 		}
 	}
 
+	if len(report.ScanErrors) > 0 {
+		driftDetails += "\n*Unverified resources:*\n"
+		for i, e := range report.ScanErrors {
+			if i >= 5 {
+				driftDetails += fmt.Sprintf("... and %d more\n", len(report.ScanErrors)-5)
+				break
+			}
+			target := e.Address
+			if target == "" {
+				target = "all " + e.Provider + " resources"
+			}
+			driftDetails += fmt.Sprintf("• `%s` %s: %s\n", e.Class, target, e.Message)
+		}
+	}
+
 	attachment := map[string]interface{}{
 		"color":      color,
 		"title":      "🔍 Infrastructure Drift Detection Report",
@@ -100,7 +130,7 @@ func (n *SlackNotifier) buildMessage(report *drift.Report) map[string]interface{
 	}
 
 	return map[string]interface{}{
-		"text":        "Infrastructure Drift Detected",
+		"text":        text,
 		"attachments": []map[string]interface{}{attachment},
 	}
 }
@@ -0,0 +1,151 @@
+package terraform
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const azureStorageAPIVersion = "2021-08-06"
+
+// AzureConfig mirrors the settings of Terraform's azurerm backend that matter for reading state
+type AzureConfig struct {
+	StorageAccount string
+	Container      string
+	Key            string
+	Workspace      string
+
+	// AccessKey is the storage account key; ARM_ACCESS_KEY is used when empty
+	AccessKey string
+
+	// SASToken is a shared access signature; ARM_SAS_TOKEN is used when empty
+	SASToken string
+
+	// Endpoint overrides the blob endpoint, e.g. http://127.0.0.1:10000/devstoreaccount1
+	// for a local emulator
+	Endpoint string
+}
+
+// AzureSource reads state from an Azure Blob Storage container
+type AzureSource struct {
+	cfg    AzureConfig
+	client *http.Client
+}
+
+// NewAzureSource creates a state source for the azurerm backend
+func NewAzureSource(cfg AzureConfig) (*AzureSource, error) {
+	if cfg.StorageAccount == "" || cfg.Container == "" || cfg.Key == "" {
+		return nil, fmt.Errorf("azurerm backend requires storage_account_name, container_name and key")
+	}
+	if cfg.Workspace == "" {
+		cfg.Workspace = "default"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", cfg.StorageAccount)
+	}
+	if cfg.AccessKey == "" {
+		cfg.AccessKey = os.Getenv("ARM_ACCESS_KEY")
+	}
+	if cfg.SASToken == "" {
+		cfg.SASToken = os.Getenv("ARM_SAS_TOKEN")
+	}
+
+	return &AzureSource{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// blobName returns the state blob name; non-default workspaces are stored
+// as <key>env:<workspace>
+func (s *AzureSource) blobName() string {
+	if s.cfg.Workspace == "default" {
+		return s.cfg.Key
+	}
+	return s.cfg.Key + "env:" + s.cfg.Workspace
+}
+
+// Location returns the URL of the state blob
+func (s *AzureSource) Location() string {
+	return fmt.Sprintf("%s/%s/%s", strings.TrimRight(s.cfg.Endpoint, "/"), s.cfg.Container, s.blobName())
+}
+
+// Fetch downloads the state blob
+func (s *AzureSource) Fetch(ctx context.Context) ([]byte, error) {
+	blobURL, err := url.Parse(s.Location())
+	if err != nil {
+		return nil, fmt.Errorf("invalid azurerm endpoint: %w", err)
+	}
+
+	if s.cfg.SASToken != "" && s.cfg.AccessKey == "" {
+		blobURL.RawQuery = strings.TrimPrefix(s.cfg.SASToken, "?")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, blobURL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureStorageAPIVersion)
+
+	if s.cfg.AccessKey != "" {
+		if err := s.signSharedKey(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return fetchHTTP(s.client, req, s.Location())
+}
+
+// signSharedKey adds a Shared Key Authorization header to a blob GET request
+func (s *AzureSource) signSharedKey(req *http.Request) error {
+	key, err := base64.StdEncoding.DecodeString(s.cfg.AccessKey)
+	if err != nil {
+		return fmt.Errorf("azurerm access key is not valid base64: %w", err)
+	}
+
+	var headerNames []string
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-") {
+			headerNames = append(headerNames, lower)
+		}
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+
+	canonicalResource := "/" + s.cfg.StorageAccount + req.URL.EscapedPath()
+	query := req.URL.Query()
+	var params []string
+	for name := range query {
+		params = append(params, name)
+	}
+	sort.Strings(params)
+	for _, name := range params {
+		values := query[name]
+		sort.Strings(values)
+		canonicalResource += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+
+	// VERB, eleven standard headers (all empty for a GET), x-ms-* headers, resource
+	stringToSign := req.Method + "\n" + strings.Repeat("\n", 11) +
+		canonicalHeaders.String() + canonicalResource
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	req.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", s.cfg.StorageAccount, signature))
+	return nil
+}
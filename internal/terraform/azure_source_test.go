@@ -0,0 +1,122 @@
+package terraform
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// azuriteKey is the well-known account key of the Azure storage emulator
+const azuriteKey = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+
+func TestAzureSourceSharedKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     AzureConfig
+		url     string
+		wantSig string
+	}{
+		{
+			// String to sign: GET, 11 empty headers, x-ms-date, x-ms-version,
+			// /devstoreaccount1/devstoreaccount1/tfstate/prod.terraform.tfstateenv:staging
+			name:    "emulator path with workspace",
+			cfg:     AzureConfig{StorageAccount: "devstoreaccount1", Container: "tfstate", Key: "prod.terraform.tfstate", Workspace: "staging", AccessKey: azuriteKey},
+			url:     "http://127.0.0.1:10000/devstoreaccount1/tfstate/prod.terraform.tfstateenv:staging",
+			wantSig: "SharedKey devstoreaccount1:LG9ccI91FFMgFgdW5gw0vlNwuNn8Ch8Rkfp2kagb52o=",
+		},
+		{
+			// Query parameters are appended to the canonical resource as
+			// name:value lines
+			name:    "query parameters",
+			cfg:     AzureConfig{StorageAccount: "myaccount", Container: "tfstate", Key: "terraform.tfstate", AccessKey: azuriteKey},
+			url:     "https://myaccount.blob.core.windows.net/tfstate/terraform.tfstate?snapshot=2026-10-17T00:00:00.0000000Z",
+			wantSig: "SharedKey myaccount:bbSFt5ml+ROaZNqQrPpcfu8TFE3sZ4XPJevK+n0PZFE=",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewAzureSource(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("x-ms-date", "Sat, 17 Oct 2026 00:00:00 GMT")
+			req.Header.Set("x-ms-version", azureStorageAPIVersion)
+
+			if err := source.signSharedKey(req); err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Header.Get("Authorization"); got != tt.wantSig {
+				t.Errorf("Authorization = %q, want %q", got, tt.wantSig)
+			}
+		})
+	}
+}
+
+func TestAzureSourceFetch(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       AzureConfig
+		wantPath  string
+		wantQuery string
+		wantAuth  bool
+	}{
+		{
+			name:     "default workspace with access key",
+			cfg:      AzureConfig{StorageAccount: "devstoreaccount1", Container: "tfstate", Key: "prod.terraform.tfstate", AccessKey: azuriteKey},
+			wantPath: "/devstoreaccount1/tfstate/prod.terraform.tfstate",
+			wantAuth: true,
+		},
+		{
+			name:     "workspace suffix",
+			cfg:      AzureConfig{StorageAccount: "devstoreaccount1", Container: "tfstate", Key: "prod.terraform.tfstate", Workspace: "staging", AccessKey: azuriteKey},
+			wantPath: "/devstoreaccount1/tfstate/prod.terraform.tfstateenv:staging",
+			wantAuth: true,
+		},
+		{
+			name:      "SAS token",
+			cfg:       AzureConfig{StorageAccount: "devstoreaccount1", Container: "tfstate", Key: "prod.terraform.tfstate", SASToken: "?sv=2021-08-06&sig=abc"},
+			wantPath:  "/devstoreaccount1/tfstate/prod.terraform.tfstate",
+			wantQuery: "sv=2021-08-06&sig=abc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ARM_ACCESS_KEY", "")
+			t.Setenv("ARM_SAS_TOKEN", "")
+
+			var req *http.Request
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req = r
+				w.Write([]byte(`{"version": 4}`))
+			}))
+			defer srv.Close()
+
+			tt.cfg.Endpoint = srv.URL + "/devstoreaccount1"
+			source, err := NewAzureSource(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := source.Fetch(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if req.URL.Path != tt.wantPath {
+				t.Errorf("path = %q, want %q", req.URL.Path, tt.wantPath)
+			}
+			if req.URL.RawQuery != tt.wantQuery {
+				t.Errorf("query = %q, want %q", req.URL.RawQuery, tt.wantQuery)
+			}
+			if got := req.Header.Get("Authorization") != ""; got != tt.wantAuth {
+				t.Errorf("Authorization set = %v, want %v", got, tt.wantAuth)
+			}
+			if req.Header.Get("x-ms-version") != azureStorageAPIVersion {
+				t.Errorf("x-ms-version = %q", req.Header.Get("x-ms-version"))
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultCloudHostname = "app.terraform.io"
+
+// CloudConfig configures reading state from Terraform Cloud or Terraform Enterprise
+type CloudConfig struct {
+	// Hostname of the TFC/TFE instance, e.g. app.terraform.io
+	Hostname     string
+	Organization string
+	Workspace    string
+
+	// Token is an API token; TF_TOKEN_<hostname> or TFE_TOKEN is used when empty
+	Token string
+
+	// Endpoint overrides the API base URL (scheme and host), e.g. for a local stand-in
+	Endpoint string
+}
+
+// CloudSource reads the current state version of a Terraform Cloud workspace
+type CloudSource struct {
+	cfg    CloudConfig
+	client *http.Client
+}
+
+// NewCloudSource creates a state source for the remote/cloud backend
+func NewCloudSource(cfg CloudConfig) (*CloudSource, error) {
+	if cfg.Organization == "" || cfg.Workspace == "" {
+		return nil, fmt.Errorf("cloud backend requires organization and workspace")
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname = defaultCloudHostname
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://" + cfg.Hostname
+	}
+	if cfg.Token == "" {
+		cfg.Token = cloudTokenFromEnv(cfg.Hostname)
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("cloud backend requires an API token for %s", cfg.Hostname)
+	}
+
+	return &CloudSource{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// cloudTokenFromEnv looks up a token the same way the Terraform CLI does
+func cloudTokenFromEnv(hostname string) string {
+	envName := "TF_TOKEN_" + strings.NewReplacer(".", "_", "-", "__").Replace(hostname)
+	if token := os.Getenv(envName); token != "" {
+		return token
+	}
+	return os.Getenv("TFE_TOKEN")
+}
+
+// Location returns the workspace identifier
+func (s *CloudSource) Location() string {
+	return fmt.Sprintf("%s/%s/%s", s.cfg.Hostname, s.cfg.Organization, s.cfg.Workspace)
+}
+
+// Fetch resolves the workspace, looks up its current state version and
+// downloads it
+func (s *CloudSource) Fetch(ctx context.Context) ([]byte, error) {
+	var workspace struct {
+		Data struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	workspacePath := fmt.Sprintf("/api/v2/organizations/%s/workspaces/%s",
+		url.PathEscape(s.cfg.Organization), url.PathEscape(s.cfg.Workspace))
+	if err := s.getJSON(ctx, workspacePath, &workspace); err != nil {
+		return nil, fmt.Errorf("failed to look up workspace %s: %w", s.Location(), err)
+	}
+
+	var stateVersion struct {
+		Data struct {
+			ID         string `json:"id"`
+			Attributes struct {
+				HostedStateDownload string `json:"hosted-state-download-url"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+	stateVersionPath := fmt.Sprintf("/api/v2/workspaces/%s/current-state-version", url.PathEscape(workspace.Data.ID))
+	if err := s.getJSON(ctx, stateVersionPath, &stateVersion); err != nil {
+		return nil, fmt.Errorf("failed to get current state version of %s: %w", s.Location(), err)
+	}
+
+	downloadURL := stateVersion.Data.Attributes.HostedStateDownload
+	if downloadURL == "" {
+		return nil, fmt.Errorf("state version %s of %s has no download URL", stateVersion.Data.ID, s.Location())
+	}
+
+	req, err := s.newRequest(ctx, downloadURL)
+	if err != nil {
+		return nil, err
+	}
+	return fetchHTTP(s.client, req, s.Location())
+}
+
+func (s *CloudSource) newRequest(ctx context.Context, target string) (*http.Request, error) {
+	base, err := url.Parse(s.cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cloud endpoint: %w", err)
+	}
+	ref, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", target, err)
+	}
+
+	resolved := base.ResolveReference(ref)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, resolved.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// The state download URL comes from the API and may point to another
+	// host, such as an object store, which must not see the API token
+	if resolved.Host == base.Host || resolved.Host == s.cfg.Hostname {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+	}
+	req.Header.Set("Content-Type", "application/vnd.api+json")
+	return req, nil
+}
+
+func (s *CloudSource) getJSON(ctx context.Context, path string, v interface{}) error {
+	req, err := s.newRequest(ctx, path)
+	if err != nil {
+		return err
+	}
+	body, err := fetchHTTP(s.client, req, s.Location())
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse API response: %w", err)
+	}
+	return nil
+}
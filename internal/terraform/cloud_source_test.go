@@ -0,0 +1,114 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCloudSourceCurrentStateVersion(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer tfc-token" {
+			t.Errorf("%s: Authorization = %q", r.URL.Path, got)
+		}
+		switch r.URL.Path {
+		case "/api/v2/organizations/acme/workspaces/network-prod":
+			fmt.Fprint(w, `{"data": {"id": "ws-123"}}`)
+		case "/api/v2/workspaces/ws-123/current-state-version":
+			fmt.Fprintf(w, `{"data": {"id": "sv-456", "attributes": {"hosted-state-download-url": "%s/api/state-versions/sv-456/hosted_state"}}}`, srv.URL)
+		case "/api/state-versions/sv-456/hosted_state":
+			fmt.Fprint(w, testStateDocument)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	source, err := NewCloudSource(CloudConfig{Organization: "acme", Workspace: "network-prod", Token: "tfc-token", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testStateDocument {
+		t.Errorf("Fetch() = %s", data)
+	}
+	if want := "app.terraform.io/acme/network-prod"; source.Location() != want {
+		t.Errorf("Location() = %q, want %q", source.Location(), want)
+	}
+}
+
+func TestCloudSourceDownloadFromOtherHost(t *testing.T) {
+	archivist := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("download from another host sent Authorization = %q", got)
+		}
+		fmt.Fprint(w, testStateDocument)
+	}))
+	defer archivist.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer tfc-token" {
+			t.Errorf("%s: Authorization = %q", r.URL.Path, got)
+		}
+		switch r.URL.Path {
+		case "/api/v2/organizations/acme/workspaces/network-prod":
+			fmt.Fprint(w, `{"data": {"id": "ws-123"}}`)
+		case "/api/v2/workspaces/ws-123/current-state-version":
+			fmt.Fprintf(w, `{"data": {"id": "sv-456", "attributes": {"hosted-state-download-url": "%s/v1/object/sv-456"}}}`, archivist.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	source, err := NewCloudSource(CloudConfig{Organization: "acme", Workspace: "network-prod", Token: "tfc-token", Endpoint: api.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != testStateDocument {
+		t.Errorf("Fetch() = %s", data)
+	}
+}
+
+func TestCloudSourceNoStateVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/organizations/acme/workspaces/empty":
+			fmt.Fprint(w, `{"data": {"id": "ws-1"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	source, err := NewCloudSource(CloudConfig{Organization: "acme", Workspace: "empty", Token: "tfc-token", Endpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Fetch(context.Background()); err == nil {
+		t.Fatal("expected an error for a workspace without state versions")
+	}
+}
+
+func TestCloudTokenFromEnv(t *testing.T) {
+	t.Setenv("TFE_TOKEN", "fallback")
+	t.Setenv("TF_TOKEN_tfe_example-corp_com", "")
+	if got := cloudTokenFromEnv("tfe.example-corp.com"); got != "fallback" {
+		t.Errorf("cloudTokenFromEnv() = %q, want TFE_TOKEN", got)
+	}
+
+	t.Setenv("TF_TOKEN_tfe_example__corp_com", "host-token")
+	if got := cloudTokenFromEnv("tfe.example-corp.com"); got != "host-token" {
+		t.Errorf("cloudTokenFromEnv() = %q, want the host-specific token", got)
+	}
+}
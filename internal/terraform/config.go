@@ -0,0 +1,549 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	log "github.com/sirupsen/logrus"
+)
+
+// ModuleConfig is the desired configuration of a module, parsed from its .tf
+// files. Modules holds the child modules by call name.
+type ModuleConfig struct {
+	Dir       string
+	Resources map[string]*ConfigResource
+	Modules   map[string]*ModuleConfig
+}
+
+// ConfigResource is a managed resource block from configuration
+type ConfigResource struct {
+	Type    string
+	Name    string
+	Address string
+
+	// Attributes holds the top-level arguments that could be evaluated to
+	// known values; arguments referring to other resources, count.index,
+	// each.key or functions are left out
+	Attributes map[string]interface{}
+
+	// IgnoreChanges lists the attribute paths from lifecycle ignore_changes,
+	// e.g. "tags" or "tags.Name"; IgnoreAll is set for `ignore_changes = all`
+	IgnoreChanges []string
+	IgnoreAll     bool
+}
+
+// Ignores reports whether lifecycle ignore_changes covers a field. A path
+// covers the field itself and everything nested under it.
+func (r *ConfigResource) Ignores(field string) bool {
+	if r.IgnoreAll {
+		return true
+	}
+	for _, path := range r.IgnoreChanges {
+		if field == path || strings.HasPrefix(field, path+".") || strings.HasPrefix(field, path+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// Resource returns the configuration of the resource an instance belongs to,
+// following the module path of the instance into child modules. Instances of
+// modules whose source could not be resolved have no configuration.
+func (c *ModuleConfig) Resource(inst ResourceInstance) (*ConfigResource, bool) {
+	if c == nil {
+		return nil, false
+	}
+	module := c
+	for _, name := range moduleCallNames(inst.Module) {
+		child, ok := module.Modules[name]
+		if !ok {
+			return nil, false
+		}
+		module = child
+	}
+	r, ok := module.Resources[inst.Type+"."+inst.Name]
+	return r, ok
+}
+
+// moduleCallNames splits a module path such as module.net["a"].module.subnets
+// into its call names, dropping instance keys
+func moduleCallNames(path string) []string {
+	var names []string
+	for path != "" {
+		path = strings.TrimPrefix(strings.TrimPrefix(path, "."), "module.")
+		end := strings.IndexAny(path, ".[")
+		if end < 0 {
+			names = append(names, path)
+			break
+		}
+		names = append(names, path[:end])
+		path = path[end:]
+		if path[0] == '[' {
+			close := strings.Index(path, "]")
+			if close < 0 {
+				break
+			}
+			path = path[close+1:]
+		}
+	}
+	return names
+}
+
+var rootModuleSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "resource", LabelNames: []string{"type", "name"}},
+		{Type: "module", LabelNames: []string{"name"}},
+		{Type: "variable", LabelNames: []string{"name"}},
+		{Type: "locals"},
+	},
+}
+
+var variableSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "default"},
+		{Name: "type"},
+	},
+}
+
+// moduleArguments are the meta-arguments of a module block, which are not
+// input variables of the child module
+var moduleArguments = map[string]bool{
+	"source": true, "version": true, "count": true, "for_each": true,
+	"providers": true, "depends_on": true,
+}
+
+// LoadModuleConfig parses the .tf files of a root module and evaluates the
+// resource arguments that only use literals, variables and locals. Variable
+// values come from defaults, terraform.tfvars, *.auto.tfvars, the given var
+// files and TF_VAR_ environment variables, in increasing precedence.
+//
+// Child modules are loaded from local sources, or for registry and remote
+// sources from the directories `terraform init` recorded in
+// .terraform/modules/modules.json. Their variables come from the arguments
+// of the module call.
+func LoadModuleConfig(dir string, varFiles []string) (*ModuleConfig, error) {
+	loader := &moduleLoader{
+		rootDir:  dir,
+		manifest: readModuleManifest(dir),
+	}
+	return loader.load(dir, "", nil, varFiles)
+}
+
+// moduleLoader loads a root module and its child modules
+type moduleLoader struct {
+	rootDir  string
+	manifest map[string]string
+}
+
+// load parses one module. key is the module path in modules.json notation
+// ("" for the root, "net.subnets" for a nested call) and inputs the values of
+// a child module's variables; only the root module reads var files.
+func (l *moduleLoader) load(dir, key string, inputs map[string]cty.Value, varFiles []string) (*ModuleConfig, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configuration files: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no .tf files found in %s", dir)
+	}
+	sort.Strings(files)
+
+	var resourceBlocks, moduleBlocks []*hcl.Block
+	localAttrs := make(hcl.Attributes)
+	variables := make(map[string]cty.Value)
+	variableTypes := make(map[string]cty.Type)
+
+	for _, file := range files {
+		body, err := parseHCLFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		content, _, diags := body.PartialContent(rootModuleSchema)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to read %s: %s", file, diags.Error())
+		}
+
+		for _, block := range content.Blocks {
+			switch block.Type {
+			case "resource":
+				resourceBlocks = append(resourceBlocks, block)
+			case "module":
+				moduleBlocks = append(moduleBlocks, block)
+			case "variable":
+				varContent, _, _ := block.Body.PartialContent(variableSchema)
+				if attr, ok := varContent.Attributes["default"]; ok {
+					if val, diags := attr.Expr.Value(nil); !diags.HasErrors() {
+						variables[block.Labels[0]] = val
+					}
+				}
+				if attr, ok := varContent.Attributes["type"]; ok {
+					if typ, diags := typeexpr.TypeConstraint(attr.Expr); !diags.HasErrors() {
+						variableTypes[block.Labels[0]] = typ
+					}
+				}
+			case "locals":
+				attrs, _ := block.Body.JustAttributes()
+				for name, attr := range attrs {
+					localAttrs[name] = attr
+				}
+			}
+		}
+	}
+
+	if key == "" {
+		if err := loadVariableValues(dir, varFiles, variables, variableTypes); err != nil {
+			return nil, err
+		}
+	}
+	for name, val := range inputs {
+		variables[name] = val
+	}
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(variables),
+		},
+	}
+	ctx.Variables["local"] = evaluateLocals(localAttrs, ctx)
+
+	config := &ModuleConfig{
+		Dir:       dir,
+		Resources: make(map[string]*ConfigResource),
+		Modules:   make(map[string]*ModuleConfig),
+	}
+
+	prefix := ""
+	if key != "" {
+		prefix = "module." + strings.ReplaceAll(key, ".", ".module.") + "."
+	}
+
+	for _, block := range resourceBlocks {
+		r := &ConfigResource{
+			Type:       block.Labels[0],
+			Name:       block.Labels[1],
+			Address:    prefix + block.Labels[0] + "." + block.Labels[1],
+			Attributes: make(map[string]interface{}),
+		}
+
+		attrs, _ := block.Body.JustAttributes()
+		for name, attr := range attrs {
+			if name == "count" || name == "for_each" || name == "depends_on" || name == "provider" {
+				continue
+			}
+			val, diags := attr.Expr.Value(ctx)
+			if diags.HasErrors() || !val.IsWhollyKnown() {
+				continue
+			}
+			r.Attributes[name] = ctyToGo(val)
+		}
+
+		if err := readLifecycle(block, r); err != nil {
+			return nil, err
+		}
+
+		config.Resources[r.Type+"."+r.Name] = r
+	}
+
+	for _, block := range moduleBlocks {
+		name := block.Labels[0]
+		childKey := name
+		if key != "" {
+			childKey = key + "." + name
+		}
+
+		attrs, _ := block.Body.JustAttributes()
+		childDir, ok := l.moduleDir(dir, childKey, attrs["source"])
+		if !ok {
+			log.Warnf("Configuration of module.%s is not compared: its source is not available locally, run terraform init", childKey)
+			continue
+		}
+
+		// Arguments that cannot be evaluated stay unknown instead of falling
+		// back to the variable default, so dependent attributes are skipped
+		childInputs := make(map[string]cty.Value)
+		for argName, attr := range attrs {
+			if moduleArguments[argName] {
+				continue
+			}
+			val, diags := attr.Expr.Value(ctx)
+			if diags.HasErrors() {
+				val = cty.DynamicVal
+			}
+			childInputs[argName] = val
+		}
+
+		child, err := l.load(childDir, childKey, childInputs, nil)
+		if err != nil {
+			log.Warnf("Configuration of module.%s is not compared: %v", childKey, err)
+			continue
+		}
+		config.Modules[name] = child
+	}
+
+	log.Debugf("Loaded configuration for %d resources from %s", len(config.Resources), dir)
+	return config, nil
+}
+
+// moduleDir resolves the directory of a module call: local paths relative to
+// the calling module, anything else through the module manifest
+func (l *moduleLoader) moduleDir(dir, key string, source *hcl.Attribute) (string, bool) {
+	if source != nil {
+		val, diags := source.Expr.Value(nil)
+		if !diags.HasErrors() && val.Type() == cty.String && !val.IsNull() {
+			s := val.AsString()
+			if strings.HasPrefix(s, "./") || strings.HasPrefix(s, "../") {
+				return filepath.Join(dir, s), true
+			}
+		}
+	}
+	if d, ok := l.manifest[key]; ok {
+		return filepath.Join(l.rootDir, d), true
+	}
+	return "", false
+}
+
+// readModuleManifest reads the module directories `terraform init` installed,
+// keyed by module path such as "net" or "net.subnets"
+func readModuleManifest(dir string) map[string]string {
+	data, err := os.ReadFile(filepath.Join(dir, ".terraform", "modules", "modules.json"))
+	if err != nil {
+		return nil
+	}
+	var manifest struct {
+		Modules []struct {
+			Key string `json:"Key"`
+			Dir string `json:"Dir"`
+		} `json:"Modules"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		log.Warnf("Failed to read the module manifest of %s: %v", dir, err)
+		return nil
+	}
+	dirs := make(map[string]string, len(manifest.Modules))
+	for _, m := range manifest.Modules {
+		if m.Key != "" {
+			dirs[m.Key] = m.Dir
+		}
+	}
+	return dirs
+}
+
+// readLifecycle reads ignore_changes from the lifecycle block of a resource
+func readLifecycle(block *hcl.Block, r *ConfigResource) error {
+	body, ok := block.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+
+	for _, lifecycle := range body.Blocks {
+		if lifecycle.Type != "lifecycle" {
+			continue
+		}
+		attr, ok := lifecycle.Body.Attributes["ignore_changes"]
+		if !ok {
+			continue
+		}
+
+		if hcl.ExprAsKeyword(attr.Expr) == "all" {
+			r.IgnoreAll = true
+			continue
+		}
+
+		exprs, diags := hcl.ExprList(attr.Expr)
+		if diags.HasErrors() {
+			return fmt.Errorf("invalid ignore_changes in %s: %s", r.Address, diags.Error())
+		}
+		for _, expr := range exprs {
+			traversal, diags := hcl.AbsTraversalForExpr(expr)
+			if diags.HasErrors() {
+				return fmt.Errorf("invalid ignore_changes in %s: %s", r.Address, diags.Error())
+			}
+			r.IgnoreChanges = append(r.IgnoreChanges, traversalPath(traversal))
+		}
+	}
+
+	return nil
+}
+
+// traversalPath formats an attribute traversal such as tags["Name"] the
+// way detectors name fields, e.g. tags.Name
+func traversalPath(traversal hcl.Traversal) string {
+	var sb strings.Builder
+	for _, step := range traversal {
+		switch t := step.(type) {
+		case hcl.TraverseRoot:
+			sb.WriteString(t.Name)
+		case hcl.TraverseAttr:
+			sb.WriteString("." + t.Name)
+		case hcl.TraverseIndex:
+			if t.Key.Type() == cty.String {
+				sb.WriteString("." + t.Key.AsString())
+			} else {
+				sb.WriteString(fmt.Sprintf("[%v]", ctyToGo(t.Key)))
+			}
+		}
+	}
+	return sb.String()
+}
+
+func parseHCLFile(path string) (hcl.Body, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	file, diags := hclsyntax.ParseConfig(src, path, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse %s: %s", path, diags.Error())
+	}
+	return file.Body, nil
+}
+
+// loadVariableValues overlays tfvars files and TF_VAR_ environment variables
+// onto the variable defaults. types holds the declared variable types.
+func loadVariableValues(dir string, varFiles []string, variables map[string]cty.Value, types map[string]cty.Type) error {
+	files := []string{filepath.Join(dir, "terraform.tfvars")}
+	autoFiles, _ := filepath.Glob(filepath.Join(dir, "*.auto.tfvars"))
+	sort.Strings(autoFiles)
+	files = append(files, autoFiles...)
+
+	for _, file := range files {
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		if err := loadVarFile(file, variables); err != nil {
+			return err
+		}
+	}
+	for _, file := range varFiles {
+		if err := loadVarFile(file, variables); err != nil {
+			return err
+		}
+	}
+
+	for _, env := range os.Environ() {
+		name, value, ok := strings.Cut(env, "=")
+		if !ok || !strings.HasPrefix(name, "TF_VAR_") {
+			continue
+		}
+		name = strings.TrimPrefix(name, "TF_VAR_")
+		variables[name] = envVariableValue(name, value, types[name])
+	}
+
+	return nil
+}
+
+// envVariableValue interprets a TF_VAR_ value the way Terraform does: as a
+// literal string for string variables and variables without a type, and
+// otherwise as an HCL expression, like a .tfvars value, converted to the
+// declared type. A value that does not parse or convert is unknown, so the
+// attributes that use it are not compared.
+func envVariableValue(name, value string, typ cty.Type) cty.Value {
+	if typ == cty.NilType || typ == cty.String || typ == cty.DynamicPseudoType {
+		return cty.StringVal(value)
+	}
+
+	var val cty.Value
+	expr, diags := hclsyntax.ParseExpression([]byte(value), "TF_VAR_"+name, hcl.Pos{Line: 1, Column: 1})
+	if !diags.HasErrors() {
+		val, diags = expr.Value(nil)
+	}
+	if diags.HasErrors() {
+		log.Warnf("Ignoring TF_VAR_%s: %s", name, diags.Error())
+		return cty.DynamicVal
+	}
+
+	converted, err := convert.Convert(val, typ)
+	if err != nil {
+		log.Warnf("Ignoring TF_VAR_%s: not a valid %s: %v", name, typ.FriendlyName(), err)
+		return cty.DynamicVal
+	}
+	return converted
+}
+
+func loadVarFile(path string, variables map[string]cty.Value) error {
+	body, err := parseHCLFile(path)
+	if err != nil {
+		return err
+	}
+	attrs, diags := body.JustAttributes()
+	if diags.HasErrors() {
+		return fmt.Errorf("failed to read %s: %s", path, diags.Error())
+	}
+	for name, attr := range attrs {
+		val, diags := attr.Expr.Value(nil)
+		if diags.HasErrors() {
+			return fmt.Errorf("failed to evaluate %s in %s: %s", name, path, diags.Error())
+		}
+		variables[name] = val
+	}
+	return nil
+}
+
+// evaluateLocals resolves locals that only depend on variables and other
+// locals, repeating until no more can be resolved
+func evaluateLocals(attrs hcl.Attributes, ctx *hcl.EvalContext) cty.Value {
+	locals := make(map[string]cty.Value)
+
+	for progress := true; progress; {
+		progress = false
+		ctx.Variables["local"] = cty.ObjectVal(locals)
+		for name, attr := range attrs {
+			if _, done := locals[name]; done {
+				continue
+			}
+			val, diags := attr.Expr.Value(ctx)
+			if diags.HasErrors() || !val.IsWhollyKnown() {
+				continue
+			}
+			locals[name] = val
+			progress = true
+		}
+	}
+
+	return cty.ObjectVal(locals)
+}
+
+// ctyToGo converts a known cty value into the shapes encoding/json produces
+// for state attributes
+func ctyToGo(val cty.Value) interface{} {
+	if val.IsNull() {
+		return nil
+	}
+
+	ty := val.Type()
+	switch {
+	case ty == cty.String:
+		return val.AsString()
+	case ty == cty.Number:
+		f, _ := val.AsBigFloat().Float64()
+		return f
+	case ty == cty.Bool:
+		return val.True()
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		list := make([]interface{}, 0, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			list = append(list, ctyToGo(v))
+		}
+		return list
+	case ty.IsMapType() || ty.IsObjectType():
+		m := make(map[string]interface{}, val.LengthInt())
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			m[k.AsString()] = ctyToGo(v)
+		}
+		return m
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,229 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadModuleConfigChildModules(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"main.tf": `
+variable "env" {
+  default = "prod"
+}
+
+module "web" {
+  source        = "./modules/web"
+  instance_type = "t3.large"
+  name          = "web-${var.env}"
+}
+
+module "subnets" {
+  source   = "./modules/web"
+  for_each = toset(["a", "b"])
+  name     = each.key
+}
+
+module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.0.0"
+}
+
+module "missing" {
+  source = "git::https://example.com/missing.git"
+}
+`,
+		"modules/web/main.tf": `
+variable "instance_type" {
+  default = "t3.micro"
+}
+
+variable "name" {}
+
+resource "aws_instance" "this" {
+  instance_type = var.instance_type
+  tags = {
+    Name = var.name
+  }
+
+  lifecycle {
+    ignore_changes = [ami]
+  }
+}
+`,
+		".terraform/modules/modules.json": `{"Modules": [
+  {"Key": "", "Source": "", "Dir": "."},
+  {"Key": "vpc", "Source": "registry.terraform.io/terraform-aws-modules/vpc/aws", "Dir": ".terraform/modules/vpc"}
+]}`,
+		".terraform/modules/vpc/main.tf": `
+resource "aws_vpc" "this" {
+  cidr_block = "10.0.0.0/16"
+}
+`,
+	})
+
+	config, err := LoadModuleConfig(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		inst      ResourceInstance
+		wantFound bool
+		wantAttrs map[string]interface{}
+	}{
+		{
+			name:      "local module with evaluated inputs",
+			inst:      ResourceInstance{Module: "module.web", Type: "aws_instance", Name: "this"},
+			wantFound: true,
+			wantAttrs: map[string]interface{}{
+				"instance_type": "t3.large",
+				"tags":          map[string]interface{}{"Name": "web-prod"},
+			},
+		},
+		{
+			// each.key cannot be evaluated, so tags is left out rather than
+			// compared against a wrong value
+			name:      "for_each module instance",
+			inst:      ResourceInstance{Module: `module.subnets["a"]`, Type: "aws_instance", Name: "this"},
+			wantFound: true,
+			wantAttrs: map[string]interface{}{"instance_type": "t3.micro"},
+		},
+		{
+			name:      "registry module from the manifest",
+			inst:      ResourceInstance{Module: "module.vpc", Type: "aws_vpc", Name: "this"},
+			wantFound: true,
+			wantAttrs: map[string]interface{}{"cidr_block": "10.0.0.0/16"},
+		},
+		{
+			name: "module not installed",
+			inst: ResourceInstance{Module: "module.missing", Type: "aws_s3_bucket", Name: "this"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := config.Resource(tt.inst)
+			if ok != tt.wantFound {
+				t.Fatalf("Resource() found = %v, want %v", ok, tt.wantFound)
+			}
+			if !ok {
+				return
+			}
+			if !reflect.DeepEqual(r.Attributes, tt.wantAttrs) {
+				t.Errorf("Attributes = %#v, want %#v", r.Attributes, tt.wantAttrs)
+			}
+		})
+	}
+
+	r, _ := config.Resource(ResourceInstance{Module: "module.web", Type: "aws_instance", Name: "this"})
+	if r.Address != "module.web.aws_instance.this" || !r.Ignores("ami") {
+		t.Errorf("got address %q, ignores ami = %v", r.Address, r.Ignores("ami"))
+	}
+}
+
+func TestModuleCallNames(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"", nil},
+		{"module.net", []string{"net"}},
+		{`module.net["a.b"].module.subnets[0]`, []string{"net", "subnets"}},
+		{"module.net.module.subnets", []string{"net", "subnets"}},
+	}
+	for _, tt := range tests {
+		if got := moduleCallNames(tt.path); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("moduleCallNames(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLoadModuleConfigTypedEnvVariables(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"main.tf": `
+variable "name" {
+  type = string
+}
+
+variable "untyped" {}
+
+variable "size" {
+  type = number
+}
+
+variable "monitoring" {
+  type = bool
+}
+
+variable "zones" {
+  type = list(string)
+}
+
+variable "tags" {
+  type = map(string)
+}
+
+variable "broken" {
+  type    = list(string)
+  default = ["a"]
+}
+
+resource "aws_instance" "web" {
+  name       = var.name
+  untyped    = var.untyped
+  size       = var.size
+  monitoring = var.monitoring
+  zones      = var.zones
+  tags       = var.tags
+  broken     = var.broken
+}
+`,
+	})
+
+	t.Setenv("TF_VAR_name", "[web]")
+	t.Setenv("TF_VAR_untyped", "42")
+	t.Setenv("TF_VAR_size", "20")
+	t.Setenv("TF_VAR_monitoring", "true")
+	t.Setenv("TF_VAR_zones", `["eu-west-1a", "eu-west-1b"]`)
+	t.Setenv("TF_VAR_tags", `{ Env = "prod", Tier = 2 }`)
+	t.Setenv("TF_VAR_broken", `["a"`)
+
+	config, err := LoadModuleConfig(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, ok := config.Resource(ResourceInstance{Type: "aws_instance", Name: "web"})
+	if !ok {
+		t.Fatal("aws_instance.web not found")
+	}
+
+	want := map[string]interface{}{
+		"name":       "[web]",
+		"untyped":    "42",
+		"size":       float64(20),
+		"monitoring": true,
+		"zones":      []interface{}{"eu-west-1a", "eu-west-1b"},
+		"tags":       map[string]interface{}{"Env": "prod", "Tier": "2"},
+	}
+	if !reflect.DeepEqual(r.Attributes, want) {
+		t.Errorf("Attributes = %#v, want %#v", r.Attributes, want)
+	}
+}
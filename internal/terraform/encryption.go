@@ -0,0 +1,231 @@
+package terraform
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// ErrStateEncrypted is returned when an encrypted state is loaded without a
+// decrypter configured
+var ErrStateEncrypted = errors.New("state is encrypted; enable security.encrypt_state and provide a decryption key")
+
+const (
+	ageHeader        = "age-encryption.org/v1\n"
+	pbkdf2KeyPrefix  = "key_provider.pbkdf2."
+	minPassphraseLen = 16
+)
+
+// encryptedState is the envelope OpenTofu writes for encrypted state
+type encryptedState struct {
+	Meta    map[string][]byte `json:"meta"`
+	Data    []byte            `json:"encrypted_data"`
+	Version string            `json:"encryption_version"`
+}
+
+// pbkdf2Metadata is stored in the envelope so the key can be derived again
+type pbkdf2Metadata struct {
+	Salt         []byte `json:"salt"`
+	Iterations   int    `json:"iterations"`
+	HashFunction string `json:"hash_function"`
+	KeyLength    int    `json:"key_length"`
+}
+
+// Decrypter decrypts state files encrypted with OpenTofu state encryption
+// (pbkdf2 key provider with aes_gcm) or with age
+type Decrypter struct {
+	passphrase string
+	identities []age.Identity
+}
+
+// NewDecrypter creates a decrypter from a passphrase and/or age identities
+// in the format written by age-keygen. At least one is required.
+func NewDecrypter(passphrase, ageIdentities string) (*Decrypter, error) {
+	d := &Decrypter{
+		passphrase: passphrase,
+	}
+
+	if passphrase != "" && len(passphrase) < minPassphraseLen {
+		return nil, fmt.Errorf("state passphrase must be at least %d characters", minPassphraseLen)
+	}
+
+	if strings.TrimSpace(ageIdentities) != "" {
+		identities, err := age.ParseIdentities(strings.NewReader(ageIdentities))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse age identities: %w", err)
+		}
+		d.identities = identities
+	}
+
+	if d.passphrase == "" && len(d.identities) == 0 {
+		return nil, fmt.Errorf("state encryption is enabled but no passphrase or age identity is configured")
+	}
+
+	return d, nil
+}
+
+// IsEncryptedState reports whether data is an encrypted state payload
+func IsEncryptedState(data []byte) bool {
+	return isAgeEncrypted(data) || isOpenTofuEncrypted(data)
+}
+
+// Decrypt returns the plaintext state. Data that is not encrypted is
+// returned unchanged.
+func (d *Decrypter) Decrypt(data []byte) ([]byte, error) {
+	switch {
+	case isAgeEncrypted(data):
+		return d.decryptAge(data)
+	case isOpenTofuEncrypted(data):
+		return d.decryptOpenTofu(data)
+	default:
+		return data, nil
+	}
+}
+
+func isAgeEncrypted(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return bytes.HasPrefix(trimmed, []byte(ageHeader)) || bytes.HasPrefix(trimmed, []byte(armor.Header))
+}
+
+func isOpenTofuEncrypted(data []byte) bool {
+	var probe struct {
+		Data    []byte `json:"encrypted_data"`
+		Version string `json:"encryption_version"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return false
+	}
+	return probe.Version != "" && len(probe.Data) > 0
+}
+
+func (d *Decrypter) decryptAge(data []byte) ([]byte, error) {
+	if len(d.identities) == 0 {
+		return nil, fmt.Errorf("state is age-encrypted but no age identity is configured")
+	}
+
+	var src io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		src = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+
+	r, err := age.Decrypt(src, d.identities...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, fmt.Errorf("failed to decrypt age-encrypted state: none of the configured identities is a recipient")
+		}
+		return nil, fmt.Errorf("failed to decrypt age-encrypted state: %w", err)
+	}
+
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt age-encrypted state: %w", err)
+	}
+	return plaintext, nil
+}
+
+func (d *Decrypter) decryptOpenTofu(data []byte) ([]byte, error) {
+	var envelope encryptedState
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted state: %w", err)
+	}
+	if envelope.Version != "v0" {
+		return nil, fmt.Errorf("unsupported state encryption version %q", envelope.Version)
+	}
+	if d.passphrase == "" {
+		return nil, fmt.Errorf("state is encrypted with a passphrase but none is configured")
+	}
+
+	// Try every pbkdf2 key provider recorded in the envelope, in a stable
+	// order, since the one used for the current data is not marked
+	names := make([]string, 0, len(envelope.Meta))
+	for name := range envelope.Meta {
+		if strings.HasPrefix(name, pbkdf2KeyPrefix) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("encrypted state uses an unsupported key provider; only pbkdf2 is supported")
+	}
+	sort.Strings(names)
+
+	var lastErr error
+	for _, name := range names {
+		var meta pbkdf2Metadata
+		if err := json.Unmarshal(envelope.Meta[name], &meta); err != nil {
+			lastErr = fmt.Errorf("invalid %s metadata: %w", name, err)
+			continue
+		}
+
+		key, err := meta.deriveKey(d.passphrase)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		plaintext, err := openAESGCM(key, envelope.Data)
+		if err == nil {
+			return plaintext, nil
+		}
+		lastErr = err
+	}
+
+	return nil, fmt.Errorf("failed to decrypt state: %w", lastErr)
+}
+
+// deriveKey derives the aes_gcm key from the passphrase
+func (m pbkdf2Metadata) deriveKey(passphrase string) ([]byte, error) {
+	var h func() hash.Hash
+	switch m.HashFunction {
+	case "sha256":
+		h = sha256.New
+	case "sha512", "":
+		h = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported pbkdf2 hash function %q", m.HashFunction)
+	}
+	if m.Iterations <= 0 || len(m.Salt) == 0 {
+		return nil, fmt.Errorf("invalid pbkdf2 metadata")
+	}
+
+	keyLength := m.KeyLength
+	if keyLength == 0 {
+		keyLength = 32
+	}
+	return pbkdf2.Key([]byte(passphrase), m.Salt, m.Iterations, keyLength, h), nil
+}
+
+// openAESGCM decrypts data laid out as nonce followed by ciphertext and tag
+func openAESGCM(key, data []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid aes_gcm key: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize aes_gcm: %w", err)
+	}
+	if len(data) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or corrupted state")
+	}
+	return plaintext, nil
+}
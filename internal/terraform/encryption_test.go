@@ -0,0 +1,216 @@
+package terraform
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	testPlaintextState = `{"version": 4, "serial": 1, "resources": []}`
+	testPassphrase     = "correct horse battery staple"
+)
+
+// sealOpenTofuState encrypts a state the way OpenTofu's pbkdf2 key provider
+// and aes_gcm method do
+func sealOpenTofuState(t *testing.T, passphrase string, plaintext []byte) []byte {
+	t.Helper()
+	meta := pbkdf2Metadata{
+		Salt:         []byte("0123456789abcdef0123456789abcdef"),
+		Iterations:   1000,
+		HashFunction: "sha512",
+		KeyLength:    32,
+	}
+	key := pbkdf2.Key([]byte(passphrase), meta.Salt, meta.Iterations, meta.KeyLength, sha512.New)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+
+	rawMeta, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope, err := json.Marshal(encryptedState{
+		Meta:    map[string][]byte{pbkdf2KeyPrefix + "main": rawMeta},
+		Data:    gcm.Seal(nonce, nonce, plaintext, nil),
+		Version: "v0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return envelope
+}
+
+func sealAgeState(t *testing.T, recipient age.Recipient, plaintext []byte, armored bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var dst io.Writer = &buf
+	var armorWriter io.WriteCloser
+	if armored {
+		armorWriter = armor.NewWriter(&buf)
+		dst = armorWriter
+	}
+	w, err := age.Encrypt(dst, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if armorWriter != nil {
+		if err := armorWriter.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDecryptOpenTofuRoundTrip(t *testing.T) {
+	data := sealOpenTofuState(t, testPassphrase, []byte(testPlaintextState))
+	if !IsEncryptedState(data) {
+		t.Fatal("IsEncryptedState() = false for an OpenTofu envelope")
+	}
+
+	d, err := NewDecrypter(testPassphrase, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := d.Decrypt(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != testPlaintextState {
+		t.Errorf("Decrypt() = %q, want %q", plaintext, testPlaintextState)
+	}
+}
+
+func TestDecryptOpenTofuWrongPassphrase(t *testing.T) {
+	data := sealOpenTofuState(t, testPassphrase, []byte(testPlaintextState))
+
+	d, err := NewDecrypter("not the right passphrase", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.Decrypt(data)
+	if err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("Decrypt() error = %v, want a wrong passphrase error", err)
+	}
+}
+
+func TestDecryptOpenTofuWithoutPassphrase(t *testing.T) {
+	data := sealOpenTofuState(t, testPassphrase, []byte(testPlaintextState))
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := NewDecrypter("", identity.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Decrypt(data); err == nil || !strings.Contains(err.Error(), "passphrase") {
+		t.Fatalf("Decrypt() error = %v, want a missing passphrase error", err)
+	}
+}
+
+func TestDecryptAgeRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := NewDecrypter("", "# created: 2026-10-17\n"+identity.String()+"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, armored := range []bool{false, true} {
+		data := sealAgeState(t, identity.Recipient(), []byte(testPlaintextState), armored)
+		if !IsEncryptedState(data) {
+			t.Fatalf("IsEncryptedState() = false for age data (armored %v)", armored)
+		}
+		plaintext, err := d.Decrypt(data)
+		if err != nil {
+			t.Fatalf("Decrypt() armored %v: %v", armored, err)
+		}
+		if string(plaintext) != testPlaintextState {
+			t.Errorf("Decrypt() armored %v = %q, want %q", armored, plaintext, testPlaintextState)
+		}
+	}
+}
+
+func TestDecryptAgeWrongIdentity(t *testing.T) {
+	recipient, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := sealAgeState(t, recipient.Recipient(), []byte(testPlaintextState), false)
+
+	d, err := NewDecrypter("", other.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.Decrypt(data)
+	if err == nil || !strings.Contains(err.Error(), "none of the configured identities is a recipient") {
+		t.Fatalf("Decrypt() error = %v, want a no matching identity error", err)
+	}
+}
+
+func TestNewDecrypter(t *testing.T) {
+	tests := []struct {
+		name       string
+		passphrase string
+		identities string
+		wantErr    bool
+	}{
+		{"passphrase", testPassphrase, "", false},
+		{"short passphrase", "too-short", "", true},
+		{"invalid identity", "", "AGE-SECRET-KEY-INVALID", true},
+		{"nothing configured", "", "  ", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDecrypter(tt.passphrase, tt.identities)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewDecrypter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecryptPlaintextUnchanged(t *testing.T) {
+	d, err := NewDecrypter(testPassphrase, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := d.Decrypt([]byte(testPlaintextState))
+	if err != nil || string(plaintext) != testPlaintextState {
+		t.Errorf("Decrypt() = %q, %v; want the state unchanged", plaintext, err)
+	}
+}
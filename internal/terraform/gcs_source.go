@@ -0,0 +1,98 @@
+package terraform
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const defaultGCSEndpoint = "https://storage.googleapis.com"
+
+// GCSConfig mirrors the settings of Terraform's gcs backend that matter for reading state
+type GCSConfig struct {
+	Bucket    string
+	Prefix    string
+	Workspace string
+
+	// AccessToken is an OAuth2 token; GOOGLE_OAUTH_ACCESS_TOKEN is used when empty
+	AccessToken string
+
+	// EncryptionKey is the base64 encoded customer-supplied key for encrypted state
+	EncryptionKey string
+
+	// Endpoint overrides the storage endpoint, e.g. for a local emulator
+	Endpoint string
+}
+
+// GCSSource reads state from a Google Cloud Storage bucket
+type GCSSource struct {
+	cfg    GCSConfig
+	client *http.Client
+}
+
+// NewGCSSource creates a state source for the gcs backend
+func NewGCSSource(cfg GCSConfig) (*GCSSource, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("gcs backend requires bucket")
+	}
+	if cfg.Workspace == "" {
+		cfg.Workspace = "default"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = defaultGCSEndpoint
+	}
+	if cfg.AccessToken == "" {
+		cfg.AccessToken = os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	}
+
+	return &GCSSource{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// object returns the state object name, laid out as <prefix>/<workspace>.tfstate
+func (s *GCSSource) object() string {
+	return path.Join(strings.Trim(s.cfg.Prefix, "/"), s.cfg.Workspace+".tfstate")
+}
+
+// Location returns the gs:// URI of the state object
+func (s *GCSSource) Location() string {
+	return fmt.Sprintf("gs://%s/%s", s.cfg.Bucket, s.object())
+}
+
+// Fetch downloads the state object through the JSON API
+func (s *GCSSource) Fetch(ctx context.Context) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media",
+		strings.TrimRight(s.cfg.Endpoint, "/"),
+		url.PathEscape(s.cfg.Bucket),
+		url.PathEscape(s.object()))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if s.cfg.AccessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.AccessToken)
+	}
+	if s.cfg.EncryptionKey != "" {
+		key, err := base64.StdEncoding.DecodeString(s.cfg.EncryptionKey)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("gcs encryption_key must be a base64 encoded 256-bit key")
+		}
+		sum := sha256.Sum256(key)
+		req.Header.Set("x-goog-encryption-algorithm", "AES256")
+		req.Header.Set("x-goog-encryption-key", s.cfg.EncryptionKey)
+		req.Header.Set("x-goog-encryption-key-sha256", base64.StdEncoding.EncodeToString(sum[:]))
+	}
+
+	return fetchHTTP(s.client, req, s.Location())
+}
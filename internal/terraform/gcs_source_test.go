@@ -0,0 +1,93 @@
+package terraform
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGCSSourceFetch(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+	sum := sha256.Sum256(key)
+
+	tests := []struct {
+		name        string
+		cfg         GCSConfig
+		wantURI     string
+		wantHeaders map[string]string
+	}{
+		{
+			name:        "default workspace",
+			cfg:         GCSConfig{Bucket: "tf-state", Prefix: "envs/prod", AccessToken: "ya29.token"},
+			wantURI:     "/storage/v1/b/tf-state/o/envs%2Fprod%2Fdefault.tfstate?alt=media",
+			wantHeaders: map[string]string{"Authorization": "Bearer ya29.token"},
+		},
+		{
+			name:    "workspace and trailing slash prefix",
+			cfg:     GCSConfig{Bucket: "tf-state", Prefix: "/envs/prod/", Workspace: "staging"},
+			wantURI: "/storage/v1/b/tf-state/o/envs%2Fprod%2Fstaging.tfstate?alt=media",
+		},
+		{
+			name:    "no prefix",
+			cfg:     GCSConfig{Bucket: "tf-state"},
+			wantURI: "/storage/v1/b/tf-state/o/default.tfstate?alt=media",
+		},
+		{
+			name:    "customer-supplied key",
+			cfg:     GCSConfig{Bucket: "tf-state", EncryptionKey: encodedKey},
+			wantURI: "/storage/v1/b/tf-state/o/default.tfstate?alt=media",
+			wantHeaders: map[string]string{
+				"x-goog-encryption-algorithm":  "AES256",
+				"x-goog-encryption-key":        encodedKey,
+				"x-goog-encryption-key-sha256": base64.StdEncoding.EncodeToString(sum[:]),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "")
+
+			var req *http.Request
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				req = r
+				w.Write([]byte(`{"version": 4}`))
+			}))
+			defer srv.Close()
+
+			tt.cfg.Endpoint = srv.URL
+			source, err := NewGCSSource(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := source.Fetch(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if req.RequestURI != tt.wantURI {
+				t.Errorf("request URI = %q, want %q", req.RequestURI, tt.wantURI)
+			}
+			for name, want := range tt.wantHeaders {
+				if got := req.Header.Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if tt.cfg.AccessToken == "" && req.Header.Get("Authorization") != "" {
+				t.Errorf("unexpected Authorization header %q", req.Header.Get("Authorization"))
+			}
+		})
+	}
+}
+
+func TestGCSSourceInvalidEncryptionKey(t *testing.T) {
+	source, err := NewGCSSource(GCSConfig{Bucket: "tf-state", EncryptionKey: "c2hvcnQ=", Endpoint: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Fetch(context.Background()); err == nil {
+		t.Fatal("expected an error for a short key")
+	}
+}
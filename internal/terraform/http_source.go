@@ -0,0 +1,193 @@
+package terraform
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// HTTPConfig mirrors the settings of Terraform's http backend that matter for reading state
+type HTTPConfig struct {
+	Address string
+
+	// Username and Password are sent as basic auth; TF_HTTP_USERNAME and
+	// TF_HTTP_PASSWORD are used when empty
+	Username string
+	Password string
+
+	// LockAddress enables locking the state while it is read
+	LockAddress   string
+	LockMethod    string
+	UnlockAddress string
+	UnlockMethod  string
+
+	SkipCertVerification bool
+}
+
+// HTTPSource reads state from a generic http backend
+type HTTPSource struct {
+	cfg    HTTPConfig
+	client *http.Client
+}
+
+// httpLockInfo is the lock payload exchanged with the lock endpoints
+type httpLockInfo struct {
+	ID        string    `json:"ID"`
+	Operation string    `json:"Operation"`
+	Info      string    `json:"Info"`
+	Who       string    `json:"Who"`
+	Version   string    `json:"Version"`
+	Created   time.Time `json:"Created"`
+	Path      string    `json:"Path"`
+}
+
+// NewHTTPSource creates a state source for the http backend
+func NewHTTPSource(cfg HTTPConfig) (*HTTPSource, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("http backend requires address")
+	}
+	if cfg.Username == "" {
+		cfg.Username = os.Getenv("TF_HTTP_USERNAME")
+	}
+	if cfg.Password == "" {
+		cfg.Password = os.Getenv("TF_HTTP_PASSWORD")
+	}
+	if cfg.LockMethod == "" {
+		cfg.LockMethod = "LOCK"
+	}
+	if cfg.UnlockAddress == "" {
+		cfg.UnlockAddress = cfg.LockAddress
+	}
+	if cfg.UnlockMethod == "" {
+		cfg.UnlockMethod = "UNLOCK"
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.SkipCertVerification {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &HTTPSource{
+		cfg: cfg,
+		client: &http.Client{
+			Timeout:   60 * time.Second,
+			Transport: transport,
+		},
+	}, nil
+}
+
+// Location returns the state address
+func (s *HTTPSource) Location() string {
+	return s.cfg.Address
+}
+
+// Fetch downloads the state, holding the lock for the duration of the read
+// when lock endpoints are configured
+func (s *HTTPSource) Fetch(ctx context.Context) ([]byte, error) {
+	if s.cfg.LockAddress != "" {
+		lock, err := s.lock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err := s.unlock(context.Background(), lock); err != nil {
+				log.Warnf("Failed to unlock state %s: %v", s.Location(), err)
+			}
+		}()
+	}
+
+	req, err := s.newRequest(ctx, http.MethodGet, s.cfg.Address, nil)
+	if err != nil {
+		return nil, err
+	}
+	return fetchHTTP(s.client, req, s.Location())
+}
+
+func (s *HTTPSource) lock(ctx context.Context) (*httpLockInfo, error) {
+	hostname, _ := os.Hostname()
+	info := &httpLockInfo{
+		ID:        newLockID(),
+		Operation: "OperationTypeRead",
+		Info:      "drift-detector",
+		Who:       fmt.Sprintf("%s@%s", os.Getenv("USER"), hostname),
+		Created:   time.Now().UTC(),
+	}
+
+	resp, err := s.sendLock(ctx, s.cfg.LockMethod, s.cfg.LockAddress, info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock state %s: %w", s.Location(), err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return info, nil
+	case http.StatusLocked, http.StatusConflict:
+		var holder httpLockInfo
+		body, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(body, &holder) == nil && holder.ID != "" {
+			return nil, fmt.Errorf("state %s is locked by %s since %s (lock ID %s)",
+				s.Location(), holder.Who, holder.Created.Format(time.RFC3339), holder.ID)
+		}
+		return nil, fmt.Errorf("state %s is locked", s.Location())
+	default:
+		return nil, fmt.Errorf("locking state %s returned status %d", s.Location(), resp.StatusCode)
+	}
+}
+
+func (s *HTTPSource) unlock(ctx context.Context, info *httpLockInfo) error {
+	resp, err := s.sendLock(ctx, s.cfg.UnlockMethod, s.cfg.UnlockAddress, info)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unlock returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *HTTPSource) sendLock(ctx context.Context, method, address string, info *httpLockInfo) (*http.Response, error) {
+	payload, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal lock info: %w", err)
+	}
+
+	req, err := s.newRequest(ctx, method, address, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return s.client.Do(req)
+}
+
+func (s *HTTPSource) newRequest(ctx context.Context, method, address string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, address, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if s.cfg.Username != "" || s.cfg.Password != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+	return req, nil
+}
+
+// newLockID returns a random UUID-formatted lock ID
+func newLockID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,149 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testStateDocument = `{"version": 4, "terraform_version": "1.6.0", "serial": 3, "lineage": "3f1c7a2e", "resources": []}`
+
+func TestHTTPSourceBasicAuth(t *testing.T) {
+	t.Setenv("TF_HTTP_USERNAME", "")
+	t.Setenv("TF_HTTP_PASSWORD", "")
+
+	var user, password string
+	var ok bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok = r.BasicAuth()
+		w.Write([]byte(testStateDocument))
+	}))
+	defer srv.Close()
+
+	source, err := NewHTTPSource(HTTPConfig{Address: srv.URL + "/state/app", Username: "ci", Password: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !ok || user != "ci" || password != "s3cret" {
+		t.Errorf("basic auth = %q/%q (%v), want ci/s3cret", user, password, ok)
+	}
+}
+
+func TestHTTPSourceLockUnlock(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	var lockID, unlockID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+
+		var info httpLockInfo
+		switch r.Method {
+		case "LOCK":
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &info)
+			lockID = info.ID
+			if info.Operation != "OperationTypeRead" {
+				t.Errorf("lock operation = %q", info.Operation)
+			}
+		case "UNLOCK":
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &info)
+			unlockID = info.ID
+		case http.MethodGet:
+			w.Write([]byte(testStateDocument))
+		}
+	}))
+	defer srv.Close()
+
+	source, err := NewHTTPSource(HTTPConfig{Address: srv.URL + "/state", LockAddress: srv.URL + "/lock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"LOCK /lock", "GET /state", "UNLOCK /lock"}
+	if strings.Join(calls, ", ") != strings.Join(want, ", ") {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+	if lockID == "" || lockID != unlockID {
+		t.Errorf("unlock ID %q does not match lock ID %q", unlockID, lockID)
+	}
+}
+
+func TestHTTPSourceLocked(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "LOCK" {
+			t.Errorf("unexpected %s request while locked", r.Method)
+			return
+		}
+		w.WriteHeader(http.StatusLocked)
+		w.Write([]byte(`{"ID": "a1b2", "Who": "alice@ci", "Created": "2026-10-17T00:00:00Z"}`))
+	}))
+	defer srv.Close()
+
+	source, err := NewHTTPSource(HTTPConfig{Address: srv.URL + "/state", LockAddress: srv.URL + "/lock"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = source.Fetch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "locked by alice@ci") {
+		t.Fatalf("Fetch() error = %v, want lock holder", err)
+	}
+}
+
+func TestHTTPSourceNoState(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"no content", http.StatusNoContent},
+		{"empty body", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			source, err := NewHTTPSource(HTTPConfig{Address: srv.URL + "/state"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			state, err := NewStateLoaderFromSource(source).LoadState(context.Background())
+			if err != nil {
+				t.Fatalf("LoadState() error = %v, want an empty state", err)
+			}
+			if len(state.Resources) != 0 || state.Source != source.Location() {
+				t.Errorf("LoadState() = %+v, want an empty state from %s", state, source.Location())
+			}
+		})
+	}
+}
+
+func TestHTTPSourceErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	source, err := NewHTTPSource(HTTPConfig{Address: srv.URL + "/state"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Fetch(context.Background()); err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Fatalf("Fetch() error = %v, want status 403", err)
+	}
+}
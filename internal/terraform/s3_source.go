@@ -0,0 +1,160 @@
+package terraform
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	log "github.com/sirupsen/logrus"
+)
+
+// S3Config mirrors the settings of Terraform's s3 backend that matter for reading state
+type S3Config struct {
+	Bucket    string
+	Key       string
+	Region    string
+	Workspace string
+
+	// WorkspaceKeyPrefix is the prefix of non-default workspace keys, "env:" by default
+	WorkspaceKeyPrefix string
+
+	// VersionID loads a specific version of a versioned state object
+	VersionID string
+
+	// KMSKeyID, when set, requires the object to be SSE-KMS encrypted with this key
+	KMSKeyID string
+
+	// SSECustomerKey is the base64 encoded 256-bit key for SSE-C encrypted state
+	SSECustomerKey string
+
+	// Endpoint overrides the S3 endpoint, e.g. for a local S3 stand-in
+	Endpoint     string
+	UsePathStyle bool
+}
+
+// S3Source reads state from an S3 bucket
+type S3Source struct {
+	cfg    S3Config
+	client *s3.Client
+}
+
+// NewS3Source creates a state source for the s3 backend
+func NewS3Source(cfg S3Config) (*S3Source, error) {
+	if cfg.Bucket == "" || cfg.Key == "" {
+		return nil, fmt.Errorf("s3 backend requires bucket and key")
+	}
+	if cfg.Workspace == "" {
+		cfg.Workspace = "default"
+	}
+	if cfg.WorkspaceKeyPrefix == "" {
+		cfg.WorkspaceKeyPrefix = "env:"
+	}
+
+	var opts []func(*config.LoadOptions) error
+	if cfg.Region != "" {
+		opts = append(opts, config.WithRegion(cfg.Region))
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+
+	return &S3Source{
+		cfg:    cfg,
+		client: client,
+	}, nil
+}
+
+// objectKey returns the state object key; non-default workspaces are stored
+// as <workspace_key_prefix>/<workspace>/<key>
+func (s *S3Source) objectKey() string {
+	if s.cfg.Workspace == "default" {
+		return s.cfg.Key
+	}
+	return path.Join(s.cfg.WorkspaceKeyPrefix, s.cfg.Workspace, s.cfg.Key)
+}
+
+// Location returns the S3 URI of the state object
+func (s *S3Source) Location() string {
+	location := fmt.Sprintf("s3://%s/%s", s.cfg.Bucket, s.objectKey())
+	if s.cfg.VersionID != "" {
+		location += "?versionId=" + s.cfg.VersionID
+	}
+	return location
+}
+
+// Fetch downloads the state object
+func (s *S3Source) Fetch(ctx context.Context) ([]byte, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.cfg.Bucket),
+		Key:    aws.String(s.objectKey()),
+	}
+	if s.cfg.VersionID != "" {
+		input.VersionId = aws.String(s.cfg.VersionID)
+	}
+	if s.cfg.SSECustomerKey != "" {
+		key, err := base64.StdEncoding.DecodeString(s.cfg.SSECustomerKey)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("sse_customer_key must be a base64 encoded 256-bit key")
+		}
+		sum := md5.Sum(key)
+		input.SSECustomerAlgorithm = aws.String("AES256")
+		input.SSECustomerKey = aws.String(s.cfg.SSECustomerKey)
+		input.SSECustomerKeyMD5 = aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+	}
+
+	out, err := s.client.GetObject(ctx, input)
+	if err != nil {
+		var noSuchKey *types.NoSuchKey
+		if errors.As(err, &noSuchKey) {
+			return nil, fmt.Errorf("state object %s not found", s.Location())
+		}
+		return nil, fmt.Errorf("failed to get state object %s: %w", s.Location(), err)
+	}
+	defer out.Body.Close()
+
+	if s.cfg.KMSKeyID != "" {
+		if out.ServerSideEncryption != types.ServerSideEncryptionAwsKms {
+			return nil, fmt.Errorf("state object %s is not SSE-KMS encrypted", s.Location())
+		}
+		if !kmsKeyMatches(aws.ToString(out.SSEKMSKeyId), s.cfg.KMSKeyID) {
+			return nil, fmt.Errorf("state object %s is encrypted with KMS key %s, expected %s",
+				s.Location(), aws.ToString(out.SSEKMSKeyId), s.cfg.KMSKeyID)
+		}
+	}
+
+	log.Debugf("Fetched %s (version: %s, encryption: %s)",
+		s.Location(), aws.ToString(out.VersionId), out.ServerSideEncryption)
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state object %s: %w", s.Location(), err)
+	}
+	return data, nil
+}
+
+// kmsKeyMatches compares a key ARN reported by S3 against a configured key
+// ID or ARN
+func kmsKeyMatches(actual, expected string) bool {
+	if actual == expected {
+		return true
+	}
+	return strings.HasSuffix(actual, ":key/"+expected)
+}
@@ -0,0 +1,160 @@
+package terraform
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestS3Source points an S3 source at a local stand-in
+func newTestS3Source(t *testing.T, cfg S3Config, handler http.HandlerFunc) *S3Source {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+
+	cfg.Endpoint = srv.URL
+	cfg.UsePathStyle = true
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	source, err := NewS3Source(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return source
+}
+
+func TestS3SourceCustomEndpointAndWorkspace(t *testing.T) {
+	var gotPath string
+	source := newTestS3Source(t, S3Config{Bucket: "tf-state", Key: "app/terraform.tfstate", Workspace: "staging"},
+		func(w http.ResponseWriter, r *http.Request) {
+			gotPath = r.URL.Path
+			w.Write([]byte(`{"version": 4}`))
+		})
+
+	data, err := source.Fetch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"version": 4}` {
+		t.Errorf("Fetch() = %q", data)
+	}
+	if want := "/tf-state/env:/staging/app/terraform.tfstate"; gotPath != want {
+		t.Errorf("request path = %q, want %q", gotPath, want)
+	}
+	if want := "s3://tf-state/env:/staging/app/terraform.tfstate"; source.Location() != want {
+		t.Errorf("Location() = %q, want %q", source.Location(), want)
+	}
+}
+
+func TestS3SourceVersionID(t *testing.T) {
+	var gotVersion string
+	source := newTestS3Source(t, S3Config{Bucket: "tf-state", Key: "terraform.tfstate", VersionID: "3HL4kqtJlcpXroDTDmJ"},
+		func(w http.ResponseWriter, r *http.Request) {
+			gotVersion = r.URL.Query().Get("versionId")
+			w.Write([]byte(`{}`))
+		})
+
+	if _, err := source.Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if gotVersion != "3HL4kqtJlcpXroDTDmJ" {
+		t.Errorf("versionId = %q", gotVersion)
+	}
+	if !strings.HasSuffix(source.Location(), "?versionId=3HL4kqtJlcpXroDTDmJ") {
+		t.Errorf("Location() = %q does not carry the version", source.Location())
+	}
+}
+
+func TestS3SourceSSECustomerKeyHeaders(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	encoded := base64.StdEncoding.EncodeToString(key)
+	sum := md5.Sum(key)
+
+	var header http.Header
+	source := newTestS3Source(t, S3Config{Bucket: "tf-state", Key: "terraform.tfstate", SSECustomerKey: encoded},
+		func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Clone()
+			w.Write([]byte(`{}`))
+		})
+
+	if _, err := source.Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"X-Amz-Server-Side-Encryption-Customer-Algorithm": "AES256",
+		"X-Amz-Server-Side-Encryption-Customer-Key":       encoded,
+		"X-Amz-Server-Side-Encryption-Customer-Key-Md5":   base64.StdEncoding.EncodeToString(sum[:]),
+	} {
+		if got := header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestS3SourceSSECustomerKeyInvalid(t *testing.T) {
+	source := newTestS3Source(t, S3Config{Bucket: "tf-state", Key: "terraform.tfstate", SSECustomerKey: "c2hvcnQ="},
+		func(w http.ResponseWriter, r *http.Request) {
+			t.Error("no request expected for an invalid key")
+		})
+
+	if _, err := source.Fetch(context.Background()); err == nil {
+		t.Fatal("expected an error for a short key")
+	}
+}
+
+func TestS3SourceKMSKey(t *testing.T) {
+	const keyARN = "arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+
+	tests := []struct {
+		name       string
+		encryption string
+		keyID      string
+		configured string
+		wantErr    bool
+	}{
+		{"matching key ID", "aws:kms", keyARN, "1234abcd-12ab-34cd-56ef-1234567890ab", false},
+		{"matching key ARN", "aws:kms", keyARN, keyARN, false},
+		{"other key", "aws:kms", "arn:aws:kms:us-east-1:111122223333:key/other", keyARN, true},
+		{"not KMS encrypted", "AES256", "", keyARN, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newTestS3Source(t, S3Config{Bucket: "tf-state", Key: "terraform.tfstate", KMSKeyID: tt.configured},
+				func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("X-Amz-Server-Side-Encryption", tt.encryption)
+					if tt.keyID != "" {
+						w.Header().Set("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id", tt.keyID)
+					}
+					w.Write([]byte(`{}`))
+				})
+
+			_, err := source.Fetch(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestS3SourceNoSuchKey(t *testing.T) {
+	source := newTestS3Source(t, S3Config{Bucket: "tf-state", Key: "terraform.tfstate"},
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`))
+		})
+
+	_, err := source.Fetch(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("Fetch() error = %v, want not found", err)
+	}
+}
@@ -0,0 +1,351 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ReportedDrift is a change Terraform itself detected between the state and
+// the remote objects while planning (resource_drift in plan JSON)
+type ReportedDrift struct {
+	Address  string
+	Type     string
+	Name     string
+	Provider string
+	Actions  []string
+	Before   map[string]interface{}
+	After    map[string]interface{}
+
+	// BeforeSensitive and AfterSensitive mirror Before and After with true
+	// at every sensitive value (before_sensitive and after_sensitive)
+	BeforeSensitive interface{}
+	AfterSensitive  interface{}
+}
+
+// SensitiveValue replaces values Terraform marks sensitive, so secrets never
+// reach reports or notifications
+const SensitiveValue = "(sensitive value)"
+
+// RedactSensitive replaces every leaf of value that the sensitivity tree
+// marks true. The tree mirrors the value as in before_sensitive: true for a
+// sensitive value, an object or array for a value with sensitive parts.
+func RedactSensitive(value, sensitive interface{}) interface{} {
+	switch s := sensitive.(type) {
+	case bool:
+		if s && value != nil {
+			return SensitiveValue
+		}
+	case map[string]interface{}:
+		if m, ok := value.(map[string]interface{}); ok {
+			redacted := make(map[string]interface{}, len(m))
+			for k, v := range m {
+				redacted[k] = RedactSensitive(v, s[k])
+			}
+			return redacted
+		}
+	case []interface{}:
+		if list, ok := value.([]interface{}); ok {
+			redacted := make([]interface{}, len(list))
+			for i, v := range list {
+				if i < len(s) {
+					v = RedactSensitive(v, s[i])
+				}
+				redacted[i] = v
+			}
+			return redacted
+		}
+	}
+	return value
+}
+
+// showModule is a module in the values representation of `terraform show -json`
+type showModule struct {
+	Address      string         `json:"address"`
+	Resources    []showResource `json:"resources"`
+	ChildModules []showModule   `json:"child_modules"`
+}
+
+type showResource struct {
+	Address         string                 `json:"address"`
+	Mode            string                 `json:"mode"`
+	Type            string                 `json:"type"`
+	Name            string                 `json:"name"`
+	Index           interface{}            `json:"index"`
+	ProviderName    string                 `json:"provider_name"`
+	SchemaVersion   int                    `json:"schema_version"`
+	Values          map[string]interface{} `json:"values"`
+	SensitiveValues interface{}            `json:"sensitive_values"`
+}
+
+type showValues struct {
+	RootModule showModule `json:"root_module"`
+}
+
+type showResourceChange struct {
+	Address       string      `json:"address"`
+	ModuleAddress string      `json:"module_address"`
+	Mode          string      `json:"mode"`
+	Type          string      `json:"type"`
+	Name          string      `json:"name"`
+	Index         interface{} `json:"index"`
+	ProviderName  string      `json:"provider_name"`
+	Change        struct {
+		Actions         []string               `json:"actions"`
+		Before          map[string]interface{} `json:"before"`
+		After           map[string]interface{} `json:"after"`
+		BeforeSensitive interface{}            `json:"before_sensitive"`
+		AfterSensitive  interface{}            `json:"after_sensitive"`
+	} `json:"change"`
+}
+
+// showConfiguration is the configuration section of plan output. It is the
+// only place recording which provider configuration manages a resource, as
+// provider_name carries no alias.
+type showConfiguration struct {
+	ProviderConfig map[string]showProviderConfig `json:"provider_config"`
+	RootModule     showConfigModule              `json:"root_module"`
+}
+
+type showProviderConfig struct {
+	FullName      string `json:"full_name"`
+	Alias         string `json:"alias"`
+	ModuleAddress string `json:"module_address"`
+}
+
+type showConfigModule struct {
+	Resources []struct {
+		Address           string `json:"address"`
+		ProviderConfigKey string `json:"provider_config_key"`
+	} `json:"resources"`
+	ModuleCalls map[string]struct {
+		Module showConfigModule `json:"module"`
+	} `json:"module_calls"`
+}
+
+// showDocument covers both `terraform show -json` for a state and for a
+// saved plan
+type showDocument struct {
+	FormatVersion    string               `json:"format_version"`
+	TerraformVersion string               `json:"terraform_version"`
+	Values           *showValues          `json:"values"`
+	PlannedValues    *showValues          `json:"planned_values"`
+	ResourceDrift    []showResourceChange `json:"resource_drift"`
+	ResourceChanges  []showResourceChange `json:"resource_changes"`
+	Configuration    *showConfiguration   `json:"configuration"`
+}
+
+// moduleInstanceKey matches the instance key of a module call in an
+// address, e.g. [0] or ["a"]
+var moduleInstanceKey = regexp.MustCompile(`\[(?:\d+|"(?:[^"\\]|\\.)*")\]`)
+
+// showProviders maps the configuration address of each resource, e.g.
+// module.net.aws_subnet.private, to its provider in state form including
+// the alias, e.g. provider["registry.terraform.io/hashicorp/aws"].west
+type showProviders map[string]string
+
+// providers resolves the provider configuration of every resource in the
+// configuration. `terraform show -json` of a state has no configuration, so
+// its resources are all attributed to the default provider configurations.
+func (c *showConfiguration) providers() showProviders {
+	providers := make(showProviders)
+	if c == nil {
+		return providers
+	}
+
+	var walk func(prefix string, m showConfigModule)
+	walk = func(prefix string, m showConfigModule) {
+		for _, r := range m.Resources {
+			config, ok := c.ProviderConfig[r.ProviderConfigKey]
+			if !ok || config.FullName == "" {
+				continue
+			}
+			address := providerAddress(config.FullName)
+			if config.Alias != "" {
+				address += "." + config.Alias
+			}
+			if config.ModuleAddress != "" {
+				address = config.ModuleAddress + "." + address
+			}
+			providers[prefix+r.Address] = address
+		}
+		for name, call := range m.ModuleCalls {
+			walk(prefix+"module."+name+".", call.Module)
+		}
+	}
+	walk("", c.RootModule)
+
+	return providers
+}
+
+// of returns the provider of a resource, falling back to the default
+// configuration of provider_name when the configuration does not say
+func (p showProviders) of(resource Resource, providerName string) string {
+	resource.Module = moduleInstanceKey.ReplaceAllString(resource.Module, "")
+	if address, ok := p[resource.Address()]; ok {
+		return address
+	}
+	return providerAddress(providerName)
+}
+
+// parseStateDocument parses either a raw .tfstate file or the JSON output of
+// `terraform show -json` for a state or a saved plan
+func parseStateDocument(data []byte) (*State, error) {
+	var probe struct {
+		FormatVersion string `json:"format_version"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if probe.FormatVersion == "" {
+		return parseState(data)
+	}
+	return parseShowJSON(data)
+}
+
+// parseShowJSON converts `terraform show -json` output into a State. For a
+// plan, resources come from planned_values so detectors compare the cloud
+// against the state expected after apply; resources that the plan creates
+// or replaces do not exist yet and are left out.
+func parseShowJSON(data []byte) (*State, error) {
+	var doc showDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse terraform show output: %w", err)
+	}
+	if !strings.HasPrefix(doc.FormatVersion, "1.") {
+		return nil, fmt.Errorf("unsupported terraform show format version %s", doc.FormatVersion)
+	}
+
+	state := &State{
+		TerraformVersion: doc.TerraformVersion,
+		Resources:        []Resource{},
+	}
+
+	switch {
+	case doc.PlannedValues != nil:
+		state.FromPlan = true
+
+		creating := make(map[string]bool)
+		for _, rc := range doc.ResourceChanges {
+			for _, action := range rc.Change.Actions {
+				if action == "create" {
+					creating[rc.Address] = true
+				}
+			}
+		}
+
+		providers := doc.Configuration.providers()
+		state.Resources = resourcesFromModule(doc.PlannedValues.RootModule, creating, providers)
+		for _, rd := range doc.ResourceDrift {
+			if rd.Mode == "data" {
+				continue
+			}
+			resource := Resource{Module: rd.ModuleAddress, Mode: rd.Mode, Type: rd.Type, Name: rd.Name}
+			state.ReportedDrift = append(state.ReportedDrift, ReportedDrift{
+				Address:  rd.Address,
+				Type:     rd.Type,
+				Name:     rd.Name,
+				Provider: providers.of(resource, rd.ProviderName),
+				Actions:  rd.Change.Actions,
+				Before:   rd.Change.Before,
+				After:    rd.Change.After,
+
+				BeforeSensitive: rd.Change.BeforeSensitive,
+				AfterSensitive:  rd.Change.AfterSensitive,
+			})
+		}
+	case doc.Values != nil:
+		state.Resources = resourcesFromModule(doc.Values.RootModule, nil, doc.Configuration.providers())
+	}
+
+	return state, nil
+}
+
+// resourcesFromModule groups the per-instance entries of a module tree into
+// resources, skipping any address in skip
+func resourcesFromModule(module showModule, skip map[string]bool, providers showProviders) []Resource {
+	var resources []Resource
+	index := make(map[string]int)
+
+	var walk func(m showModule)
+	walk = func(m showModule) {
+		for _, r := range m.Resources {
+			if skip[r.Address] {
+				continue
+			}
+
+			resource := Resource{
+				Module: m.Address,
+				Mode:   r.Mode,
+				Type:   r.Type,
+				Name:   r.Name,
+			}
+			resource.Provider = providers.of(resource, r.ProviderName)
+			key := resource.Address()
+			i, ok := index[key]
+			if !ok {
+				i = len(resources)
+				index[key] = i
+				resources = append(resources, resource)
+			}
+
+			resources[i].Instances = append(resources[i].Instances, Instance{
+				IndexKey:            r.Index,
+				SchemaVersion:       r.SchemaVersion,
+				Attributes:          r.Values,
+				SensitiveAttributes: sensitiveAttributesFromValues(r.SensitiveValues),
+			})
+		}
+		for _, child := range m.ChildModules {
+			walk(child)
+		}
+	}
+	walk(module)
+
+	return resources
+}
+
+// providerAddress converts a provider source address to the form used in
+// state files, e.g. provider["registry.terraform.io/hashicorp/aws"]
+func providerAddress(name string) string {
+	if name == "" {
+		return ""
+	}
+	return fmt.Sprintf("provider[%q]", name)
+}
+
+// sensitiveAttributesFromValues converts the sensitive_values tree (true at
+// each sensitive leaf) into the sensitive_attributes path list of a state file
+func sensitiveAttributesFromValues(values interface{}) json.RawMessage {
+	type step struct {
+		Type  string      `json:"type"`
+		Value interface{} `json:"value"`
+	}
+
+	var paths [][]step
+	var walk func(v interface{}, path []step)
+	walk = func(v interface{}, path []step) {
+		switch t := v.(type) {
+		case bool:
+			if t && len(path) > 0 {
+				paths = append(paths, append([]step(nil), path...))
+			}
+		case map[string]interface{}:
+			for k, child := range t {
+				walk(child, append(path, step{Type: "get_attr", Value: k}))
+			}
+		case []interface{}:
+			for i, child := range t {
+				walk(child, append(path, step{Type: "index", Value: map[string]interface{}{"value": i, "type": "number"}}))
+			}
+		}
+	}
+	walk(values, nil)
+
+	if len(paths) == 0 {
+		return nil
+	}
+	raw, _ := json.Marshal(paths)
+	return raw
+}
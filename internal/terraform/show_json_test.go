@@ -0,0 +1,138 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseShowJSONReportedDriftSensitivity(t *testing.T) {
+	doc := `{
+		"format_version": "1.2",
+		"planned_values": {"root_module": {}},
+		"resource_drift": [{
+			"address": "aws_db_instance.main",
+			"mode": "managed",
+			"type": "aws_db_instance",
+			"name": "main",
+			"provider_name": "registry.terraform.io/hashicorp/aws",
+			"change": {
+				"actions": ["update"],
+				"before": {"password": "old"},
+				"after": {"password": "new"},
+				"before_sensitive": {"password": true},
+				"after_sensitive": {"password": true}
+			}
+		}]
+	}`
+
+	state, err := parseShowJSON([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.ReportedDrift) != 1 {
+		t.Fatalf("got %d reported drifts, want 1", len(state.ReportedDrift))
+	}
+	reported := state.ReportedDrift[0]
+	want := map[string]interface{}{"password": true}
+	if !reflect.DeepEqual(reported.BeforeSensitive, want) || !reflect.DeepEqual(reported.AfterSensitive, want) {
+		t.Errorf("sensitivity = %v / %v, want %v", reported.BeforeSensitive, reported.AfterSensitive, want)
+	}
+}
+
+func TestParseShowJSONProviderAlias(t *testing.T) {
+	doc := `{
+		"format_version": "1.2",
+		"planned_values": {"root_module": {
+			"resources": [
+				{"address": "aws_instance.web", "mode": "managed", "type": "aws_instance", "name": "web", "provider_name": "registry.terraform.io/hashicorp/aws", "values": {}},
+				{"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "name": "logs", "provider_name": "registry.terraform.io/hashicorp/aws", "values": {}},
+				{"address": "aws_iam_role.ci", "mode": "managed", "type": "aws_iam_role", "name": "ci", "provider_name": "registry.terraform.io/hashicorp/aws", "values": {}}
+			],
+			"child_modules": [
+				{"address": "module.net", "resources": [
+					{"address": "module.net.aws_subnet.private", "mode": "managed", "type": "aws_subnet", "name": "private", "provider_name": "registry.terraform.io/hashicorp/aws", "values": {}}
+				]},
+				{"address": "module.dr[\"eu\"]", "resources": [
+					{"address": "module.dr[\"eu\"].aws_vpc.main", "mode": "managed", "type": "aws_vpc", "name": "main", "provider_name": "registry.terraform.io/hashicorp/aws", "values": {}}
+				]}
+			]
+		}},
+		"resource_drift": [{
+			"address": "aws_instance.web", "mode": "managed", "type": "aws_instance", "name": "web",
+			"provider_name": "registry.terraform.io/hashicorp/aws",
+			"change": {"actions": ["update"], "before": {}, "after": {}}
+		}],
+		"configuration": {
+			"provider_config": {
+				"aws": {"name": "aws", "full_name": "registry.terraform.io/hashicorp/aws"},
+				"aws.west": {"name": "aws", "full_name": "registry.terraform.io/hashicorp/aws", "alias": "west"},
+				"module.net:aws": {"name": "aws", "full_name": "registry.terraform.io/hashicorp/aws", "module_address": "module.net"}
+			},
+			"root_module": {
+				"resources": [
+					{"address": "aws_instance.web", "provider_config_key": "aws.west"},
+					{"address": "aws_s3_bucket.logs", "provider_config_key": "aws"}
+				],
+				"module_calls": {
+					"net": {"module": {"resources": [{"address": "aws_subnet.private", "provider_config_key": "module.net:aws"}]}},
+					"dr": {"module": {"resources": [{"address": "aws_vpc.main", "provider_config_key": "aws.west"}]}}
+				}
+			}
+		}
+	}`
+
+	state, err := parseShowJSON([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const aws = `provider["registry.terraform.io/hashicorp/aws"]`
+	want := map[string]string{
+		"aws_instance.web":              aws + ".west",
+		"aws_s3_bucket.logs":            aws,
+		"aws_iam_role.ci":               aws,
+		"module.net.aws_subnet.private": "module.net." + aws,
+		`module.dr["eu"].aws_vpc.main`:  aws + ".west",
+	}
+	for _, resource := range state.Resources {
+		if got := resource.Provider; got != want[resource.Address()] {
+			t.Errorf("provider of %s = %s, want %s", resource.Address(), got, want[resource.Address()])
+		}
+	}
+	if len(state.ReportedDrift) != 1 || state.ReportedDrift[0].Provider != aws+".west" {
+		t.Errorf("reported drift = %+v, want provider %s.west", state.ReportedDrift, aws)
+	}
+
+	instances := state.ManagedInstances()
+	if alias := instances[0].ProviderAlias(); alias != "west" {
+		t.Errorf("ProviderAlias() of %s = %q, want west", instances[0].Address, alias)
+	}
+}
+
+func TestRedactSensitive(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     interface{}
+		sensitive interface{}
+		want      interface{}
+	}{
+		{"not sensitive", "a", false, "a"},
+		{"no tree", "a", nil, "a"},
+		{"sensitive leaf", "a", true, SensitiveValue},
+		{"sensitive null stays null", nil, true, nil},
+		{"whole object", map[string]interface{}{"k": "v"}, true, SensitiveValue},
+		{"object key", map[string]interface{}{"k": "v", "o": "p"}, map[string]interface{}{"k": true},
+			map[string]interface{}{"k": SensitiveValue, "o": "p"}},
+		{"list element", []interface{}{"a", "b"}, []interface{}{false, true},
+			[]interface{}{"a", SensitiveValue}},
+		{"shorter tree", []interface{}{"a", "b"}, []interface{}{true},
+			[]interface{}{SensitiveValue, "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactSensitive(tt.value, tt.sensitive); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RedactSensitive() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SupportedStateVersion is the only state format version that can be parsed
+const SupportedStateVersion = 4
+
+// State represents a Terraform state (format version 4)
+type State struct {
+	Version          int        `json:"version"`
+	TerraformVersion string     `json:"terraform_version"`
+	Serial           int64      `json:"serial"`
+	Lineage          string     `json:"lineage"`
+	Resources        []Resource `json:"resources"`
+
+	// Source and Workspace identify where the state was loaded from
+	Source    string `json:"-"`
+	Workspace string `json:"-"`
+
+	// ModuleDir is the root module whose configuration the state is also
+	// compared against, if any
+	ModuleDir string `json:"-"`
+
+	// FromPlan is set when resources come from the planned values of a saved
+	// plan; ReportedDrift holds the drift Terraform found while planning
+	FromPlan      bool            `json:"-"`
+	ReportedDrift []ReportedDrift `json:"-"`
+}
+
+// Resource represents a Terraform resource block and all of its instances
+type Resource struct {
+	Module    string     `json:"module,omitempty"`
+	Mode      string     `json:"mode"` // managed or data
+	Type      string     `json:"type"`
+	Name      string     `json:"name"`
+	Provider  string     `json:"provider"`
+	Instances []Instance `json:"instances"`
+}
+
+// Instance represents a single instance of a resource, one per count index
+// or for_each key
+type Instance struct {
+	IndexKey            interface{}            `json:"index_key,omitempty"`
+	Status              string                 `json:"status,omitempty"`
+	Deposed             string                 `json:"deposed,omitempty"`
+	SchemaVersion       int                    `json:"schema_version"`
+	Attributes          map[string]interface{} `json:"attributes"`
+	SensitiveAttributes json.RawMessage        `json:"sensitive_attributes,omitempty"`
+	Private             string                 `json:"private,omitempty"`
+	Dependencies        []string               `json:"dependencies,omitempty"`
+	CreateBeforeDestroy bool                   `json:"create_before_destroy,omitempty"`
+}
+
+// ResourceInstance is a managed resource instance flattened for detectors
+type ResourceInstance struct {
+	Address    string
+	Module     string
+	Type       string
+	Name       string
+	Provider   string
+	IndexKey   interface{}
+	Attributes map[string]interface{}
+
+	// SensitivePaths lists attribute paths marked sensitive, e.g. "password"
+	// or "environment.0.variables.API_KEY"
+	SensitivePaths []string
+}
+
+// IsData reports whether the resource is a data source
+func (r Resource) IsData() bool {
+	return r.Mode == "data"
+}
+
+// Address returns the resource address without an instance key, e.g.
+// module.net.aws_instance.web
+func (r Resource) Address() string {
+	address := r.Type + "." + r.Name
+	if r.IsData() {
+		address = "data." + address
+	}
+	if r.Module != "" {
+		address = r.Module + "." + address
+	}
+	return address
+}
+
+// InstanceAddress returns the address of one instance, e.g.
+// module.net.aws_instance.web["a"] or aws_instance.web[0]
+func (r Resource) InstanceAddress(inst Instance) string {
+	return r.Address() + formatIndexKey(inst.IndexKey)
+}
+
+// ManagedInstances returns every current instance of every managed resource.
+// Data sources and deposed objects are skipped.
+func (s *State) ManagedInstances() []ResourceInstance {
+	var instances []ResourceInstance
+
+	for _, r := range s.Resources {
+		if r.IsData() {
+			continue
+		}
+		for _, inst := range r.Instances {
+			if inst.Deposed != "" {
+				continue
+			}
+			instances = append(instances, ResourceInstance{
+				Address:        r.InstanceAddress(inst),
+				Module:         r.Module,
+				Type:           r.Type,
+				Name:           r.Name,
+				Provider:       r.Provider,
+				IndexKey:       inst.IndexKey,
+				Attributes:     inst.Attributes,
+				SensitivePaths: sensitivePaths(inst.SensitiveAttributes),
+			})
+		}
+	}
+
+	return instances
+}
+
+// IsSensitive reports whether an attribute path, or one of its parents, is
+// marked sensitive
+func (r ResourceInstance) IsSensitive(path string) bool {
+	for _, p := range r.SensitivePaths {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// ProviderAlias returns the alias of the provider configuration that manages
+// the instance, e.g. "west" for provider["registry.terraform.io/hashicorp/aws"].west,
+// or "" for the default configuration
+func (r ResourceInstance) ProviderAlias() string {
+	i := strings.LastIndex(r.Provider, "]")
+	if i < 0 || i+1 >= len(r.Provider) || r.Provider[i+1] != '.' {
+		return ""
+	}
+	return r.Provider[i+2:]
+}
+
+// parseState decodes a raw state document, rejecting format versions other
+// than 4
+func parseState(data []byte) (*State, error) {
+	var header struct {
+		Version          int    `json:"version"`
+		TerraformVersion string `json:"terraform_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+
+	switch {
+	case header.Version == 0:
+		return nil, fmt.Errorf("not a Terraform state file: missing format version")
+	case header.Version != SupportedStateVersion:
+		return nil, fmt.Errorf("unsupported state format version %d (written by Terraform %s); only version %d from Terraform 0.12 and later is supported",
+			header.Version, valueOr(header.TerraformVersion, "unknown"), SupportedStateVersion)
+	}
+
+	state := &State{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if state.Lineage == "" {
+		return nil, fmt.Errorf("invalid state file: missing lineage")
+	}
+
+	if state.Resources == nil {
+		state.Resources = []Resource{}
+	}
+	return state, nil
+}
+
+func formatIndexKey(key interface{}) string {
+	switch k := key.(type) {
+	case nil:
+		return ""
+	case string:
+		return "[" + strconv.Quote(k) + "]"
+	case float64:
+		return "[" + strconv.FormatFloat(k, 'f', -1, 64) + "]"
+	default:
+		return fmt.Sprintf("[%v]", k)
+	}
+}
+
+// sensitivePaths flattens the sensitive_attributes path list into dotted
+// attribute paths
+func sensitivePaths(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var paths [][]struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &paths); err != nil {
+		return nil
+	}
+
+	var result []string
+	for _, steps := range paths {
+		var parts []string
+		for _, step := range steps {
+			switch step.Type {
+			case "get_attr":
+				var name string
+				if json.Unmarshal(step.Value, &name) == nil {
+					parts = append(parts, name)
+				}
+			case "index":
+				var index struct {
+					Value interface{} `json:"value"`
+				}
+				if json.Unmarshal(step.Value, &index) == nil {
+					parts = append(parts, fmt.Sprint(index.Value))
+				}
+			}
+		}
+		if len(parts) > 0 {
+			result = append(result, strings.Join(parts, "."))
+		}
+	}
+	return result
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
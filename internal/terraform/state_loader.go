@@ -1,119 +1,199 @@
 package terraform
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// State represents a Terraform state
-type State struct {
-	Version   int        `json:"version"`
-	Resources []Resource `json:"resources"`
+// StateSource fetches raw Terraform state from a backend
+type StateSource interface {
+	// Location describes where the state is read from
+	Location() string
+
+	// Fetch returns the raw state document
+	Fetch(ctx context.Context) ([]byte, error)
+}
+
+// Stages of loading a state, recorded in LoadError
+const (
+	LoadStageFetch   = "fetch"
+	LoadStageDecrypt = "decrypt"
+	LoadStageParse   = "parse"
+)
+
+// LoadError is returned when a state cannot be loaded, recording where it
+// was loaded from and which stage failed
+type LoadError struct {
+	Location string
+	Stage    string
+	Err      error
+}
+
+func (e *LoadError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatusError is returned when a backend answers a state download with
+// a status other than 2xx
+type HTTPStatusError struct {
+	Location   string
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	if e.StatusCode == http.StatusNotFound {
+		return fmt.Sprintf("state %s not found", e.Location)
+	}
+	return fmt.Sprintf("fetching state from %s returned status %d: %s", e.Location, e.StatusCode, e.Body)
+}
+
+// LocalSource reads state from a file on disk
+type LocalSource struct {
+	path string
+}
+
+// NewLocalSource creates a state source for a local state file
+func NewLocalSource(path string) *LocalSource {
+	return &LocalSource{
+		path: path,
+	}
 }
 
-// Resource represents a Terraform resource
-type Resource struct {
-	Type       string                 `json:"type"`
-	Name       string                 `json:"name"`
-	Provider   string                 `json:"provider"`
-	Instances  []Instance             `json:"instances"`
-	Attributes map[string]interface{} `json:"attributes,omitempty"`
+// Location returns the state file path
+func (s *LocalSource) Location() string {
+	return s.path
 }
 
-// Instance represents a resource instance
-type Instance struct {
-	Attributes map[string]interface{} `json:"attributes"`
+// Fetch reads the state file
+func (s *LocalSource) Fetch(ctx context.Context) ([]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+	return data, nil
+}
+
+// LocalWorkspacePath returns the path of a workspace's state next to a local
+// state file, following the terraform.tfstate.d/<workspace> layout
+func LocalWorkspacePath(statePath, workspace string) string {
+	if workspace == "" || workspace == "default" {
+		return statePath
+	}
+	return filepath.Join(filepath.Dir(statePath), "terraform.tfstate.d", workspace, filepath.Base(statePath))
 }
 
 // StateLoader loads Terraform state
 type StateLoader struct {
-	statePath string
+	source    StateSource
+	decrypter *Decrypter
 }
 
-// NewStateLoader creates a new state loader
+// NewStateLoader creates a new state loader for a local state file
 func NewStateLoader(statePath string) *StateLoader {
+	return NewStateLoaderFromSource(NewLocalSource(statePath))
+}
+
+// NewStateLoaderFromSource creates a new state loader for any state source
+func NewStateLoaderFromSource(source StateSource) *StateLoader {
 	return &StateLoader{
-		statePath: statePath,
+		source: source,
 	}
 }
 
-// LoadState loads the Terraform state from file
+// WithDecrypter sets the decrypter used for encrypted state payloads
+func (l *StateLoader) WithDecrypter(d *Decrypter) *StateLoader {
+	l.decrypter = d
+	return l
+}
+
+// LoadState fetches the Terraform state from its source and parses it
 func (l *StateLoader) LoadState(ctx context.Context) (*State, error) {
-	log.Debugf("Loading Terraform state from: %s", l.statePath)
+	log.Debugf("Loading Terraform state from: %s", l.source.Location())
 
-	// Read state file
-	data, err := os.ReadFile(l.statePath)
+	data, err := l.source.Fetch(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read state file: %w", err)
+		return nil, &LoadError{Location: l.source.Location(), Stage: LoadStageFetch, Err: err}
 	}
 
-	// Parse JSON
-	var rawState map[string]interface{}
-	if err := json.Unmarshal(data, &rawState); err != nil {
-		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	// Backends answer with an empty document when no state has been
+	// written yet, which Terraform treats as an empty state
+	if len(bytes.TrimSpace(data)) == 0 {
+		log.Warnf("No state stored at %s", l.source.Location())
+		return &State{Source: l.source.Location(), Resources: []Resource{}}, nil
 	}
 
-	// Extract resources
-	state := &State{
-		Resources: []Resource{},
+	if IsEncryptedState(data) {
+		if l.decrypter == nil {
+			return nil, &LoadError{Location: l.source.Location(), Stage: LoadStageDecrypt,
+				Err: fmt.Errorf("%s: %w", l.source.Location(), ErrStateEncrypted)}
+		}
+		data, err = l.decrypter.Decrypt(data)
+		if err != nil {
+			return nil, &LoadError{Location: l.source.Location(), Stage: LoadStageDecrypt,
+				Err: fmt.Errorf("%s: %w", l.source.Location(), err)}
+		}
+		log.Debugf("Decrypted state %s", l.source.Location())
 	}
 
-	if version, ok := rawState["version"].(float64); ok {
-		state.Version = int(version)
+	state, err := parseStateDocument(data)
+	if err != nil {
+		return nil, &LoadError{Location: l.source.Location(), Stage: LoadStageParse, Err: err}
 	}
+	state.Source = l.source.Location()
 
-	// Parse resources from state
-	resources, ok := rawState["resources"].([]interface{})
-	if !ok {
+	if len(state.Resources) == 0 {
 		log.Warn("No resources found in state file")
-		return state, nil
 	}
 
-	for _, r := range resources {
-		resourceMap, ok := r.(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		resource := Resource{
-			Type:     getString(resourceMap, "type"),
-			Name:     getString(resourceMap, "name"),
-			Provider: getString(resourceMap, "provider"),
-		}
-
-		// Extract instances
-		instances, ok := resourceMap["instances"].([]interface{})
-		if ok && len(instances) > 0 {
-			for _, inst := range instances {
-				instMap, ok := inst.(map[string]interface{})
-				if !ok {
-					continue
-				}
-
-				attrs, ok := instMap["attributes"].(map[string]interface{})
-				if ok {
-					resource.Attributes = attrs
-					resource.Instances = append(resource.Instances, Instance{
-						Attributes: attrs,
-					})
-				}
-			}
-		}
-
-		state.Resources = append(state.Resources, resource)
+	if state.FromPlan {
+		log.Infof("Using planned values from %s (%d drift(s) reported by Terraform)",
+			state.Source, len(state.ReportedDrift))
 	}
 
+	log.Debugf("State %s: serial %d, lineage %s, written by Terraform %s",
+		state.Source, state.Serial, state.Lineage, state.TerraformVersion)
 	log.Infof("Loaded %d resources from Terraform state", len(state.Resources))
 	return state, nil
 }
 
-func getString(m map[string]interface{}, key string) string {
-	if val, ok := m[key].(string); ok {
-		return val
+// fetchHTTP performs a state download request and returns the body, treating
+// any non-2xx response as an error. A 204 No Content response returns an
+// empty body, meaning no state has been stored yet.
+func fetchHTTP(client *http.Client, req *http.Request, location string) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch state from %s: %w", location, err)
 	}
-	return ""
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state from %s: %w", location, err)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNoContent:
+		return nil, nil
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, &HTTPStatusError{
+			Location:   location,
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(body)),
+		}
+	}
+
+	return body, nil
 }
@@ -0,0 +1,154 @@
+package terraform
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const moduleState = `{
+  "version": 4,
+  "terraform_version": "1.6.0",
+  "serial": 3,
+  "lineage": "0e5c6d1a",
+  "resources": [
+    {
+      "mode": "managed", "type": "aws_instance", "name": "web",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [
+        {"index_key": 0, "schema_version": 1, "attributes": {"id": "i-0"}},
+        {"index_key": 1, "schema_version": 1, "attributes": {"id": "i-1"}},
+        {"index_key": 1, "deposed": "00000001", "schema_version": 1, "attributes": {"id": "i-old"}}
+      ]
+    },
+    {
+      "module": "module.net", "mode": "managed", "type": "aws_subnet", "name": "private",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [
+        {"index_key": "a", "schema_version": 1, "attributes": {"id": "subnet-a"}}
+      ]
+    },
+    {
+      "mode": "data", "type": "aws_ami", "name": "ubuntu",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [{"schema_version": 0, "attributes": {"id": "ami-1"}}]
+    },
+    {
+      "mode": "managed", "type": "aws_db_instance", "name": "main",
+      "provider": "provider[\"registry.terraform.io/hashicorp/aws\"]",
+      "instances": [
+        {
+          "schema_version": 2,
+          "attributes": {"id": "db", "password": "secret"},
+          "sensitive_attributes": [
+            [{"type": "get_attr", "value": "password"}],
+            [{"type": "get_attr", "value": "environment"}, {"type": "index", "value": {"value": 0, "type": "number"}}, {"type": "get_attr", "value": "variables"}]
+          ]
+        }
+      ]
+    }
+  ]
+}`
+
+func TestManagedInstances(t *testing.T) {
+	state, err := parseState([]byte(moduleState))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var addresses []string
+	for _, inst := range state.ManagedInstances() {
+		addresses = append(addresses, inst.Address)
+	}
+
+	want := []string{
+		"aws_instance.web[0]",
+		"aws_instance.web[1]",
+		`module.net.aws_subnet.private["a"]`,
+		"aws_db_instance.main",
+	}
+	if !reflect.DeepEqual(addresses, want) {
+		t.Errorf("ManagedInstances() addresses = %q, want %q", addresses, want)
+	}
+}
+
+func TestSensitivePaths(t *testing.T) {
+	state, err := parseState([]byte(moduleState))
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances := state.ManagedInstances()
+	db := instances[len(instances)-1]
+
+	want := []string{"password", "environment.0.variables"}
+	if !reflect.DeepEqual(db.SensitivePaths, want) {
+		t.Fatalf("SensitivePaths = %q, want %q", db.SensitivePaths, want)
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"password", true},
+		{"environment.0.variables", true},
+		{"environment.0.variables.API_KEY", true},
+		{"environment.0", false},
+		{"password_policy", false},
+		{"username", false},
+	}
+	for _, tt := range tests {
+		if got := db.IsSensitive(tt.path); got != tt.want {
+			t.Errorf("IsSensitive(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFormatIndexKey(t *testing.T) {
+	tests := []struct {
+		key  interface{}
+		want string
+	}{
+		{nil, ""},
+		{float64(2), "[2]"},
+		{"eu-west-1", `["eu-west-1"]`},
+		{`a"b`, `["a\"b"]`},
+	}
+
+	for _, tt := range tests {
+		if got := formatIndexKey(tt.key); got != tt.want {
+			t.Errorf("formatIndexKey(%v) = %s, want %s", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestParseStateValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"valid", `{"version": 4, "serial": 1, "lineage": "abc"}`, ""},
+		{"no version", `{"serial": 1, "lineage": "abc"}`, "not a Terraform state file: missing format version"},
+		{"version 3", `{"version": 3, "terraform_version": "0.11.14"}`, "unsupported state format version 3 (written by Terraform 0.11.14)"},
+		{"no lineage", `{"version": 4, "serial": 1}`, "invalid state file: missing lineage"},
+		{"not json", `<html>`, "failed to parse state file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := parseState([]byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("parseState() error = %v", err)
+				}
+				if state.Resources == nil {
+					t.Error("Resources is nil, want an empty list")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("parseState() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
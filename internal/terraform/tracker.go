@@ -0,0 +1,129 @@
+package terraform
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StaleStateError reports a state that is older than, or unrelated to, the
+// last copy seen from the same source
+type StaleStateError struct {
+	Source string
+	Reason string
+}
+
+func (e *StaleStateError) Error() string {
+	return fmt.Sprintf("stale state %s: %s", e.Source, e.Reason)
+}
+
+// stateRecord is the last lineage and serial seen for a state
+type stateRecord struct {
+	Lineage string    `json:"lineage"`
+	Serial  int64     `json:"serial"`
+	SeenAt  time.Time `json:"seen_at"`
+}
+
+// StateTracker remembers the last-seen lineage and serial of each state
+// across runs so stale copies can be detected
+type StateTracker struct {
+	path    string
+	mu      sync.Mutex
+	records map[string]stateRecord
+}
+
+// NewStateTracker loads the tracking file at path, starting empty if it does
+// not exist yet
+func NewStateTracker(path string) (*StateTracker, error) {
+	tracker := &StateTracker{
+		path:    path,
+		records: make(map[string]stateRecord),
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tracker, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state tracking file: %w", err)
+	}
+	if err := json.Unmarshal(data, &tracker.records); err != nil {
+		return nil, fmt.Errorf("failed to parse state tracking file %s: %w", path, err)
+	}
+
+	return tracker, nil
+}
+
+// Observe compares a state against the last one seen from the same source
+// and workspace. It returns a *StaleStateError when the serial went
+// backwards or the lineage changed. A lower serial is never recorded, so a
+// stale copy keeps being reported until a newer state is seen; a new
+// lineage is recorded so it is only reported once. States without a
+// lineage, such as `terraform show -json` output, are not tracked.
+func (t *StateTracker) Observe(state *State) error {
+	if state.Lineage == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := StateKey(state)
+	current := stateRecord{
+		Lineage: state.Lineage,
+		Serial:  state.Serial,
+		SeenAt:  time.Now().UTC(),
+	}
+
+	previous, ok := t.records[key]
+	switch {
+	case !ok:
+		t.records[key] = current
+		return nil
+	case previous.Lineage != state.Lineage:
+		t.records[key] = current
+		return &StaleStateError{
+			Source: key,
+			Reason: fmt.Sprintf("lineage changed from %s to %s", previous.Lineage, state.Lineage),
+		}
+	case state.Serial < previous.Serial:
+		return &StaleStateError{
+			Source: key,
+			Reason: fmt.Sprintf("serial went backwards from %d (seen %s) to %d",
+				previous.Serial, previous.SeenAt.Format(time.RFC3339), state.Serial),
+		}
+	default:
+		t.records[key] = current
+		return nil
+	}
+}
+
+// Save writes the tracking file
+func (t *StateTracker) Save() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data, err := json.MarshalIndent(t.records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state tracking data: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0700); err != nil {
+		return fmt.Errorf("failed to create state tracking directory: %w", err)
+	}
+	if err := os.WriteFile(t.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state tracking file: %w", err)
+	}
+	return nil
+}
+
+// StateKey identifies a state by its source and workspace
+func StateKey(state *State) string {
+	if state.Workspace == "" || state.Workspace == "default" {
+		return state.Source
+	}
+	return state.Source + "#" + state.Workspace
+}
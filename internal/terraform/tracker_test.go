@@ -0,0 +1,68 @@
+package terraform
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateTrackerObserve(t *testing.T) {
+	state := func(lineage string, serial int64) *State {
+		return &State{Source: "s3://bucket/prod.tfstate", Lineage: lineage, Serial: serial}
+	}
+
+	tests := []struct {
+		name      string
+		observed  []*State
+		wantStale []bool
+	}{
+		{"first seen", []*State{state("a", 5)}, []bool{false}},
+		{"serial advances", []*State{state("a", 5), state("a", 6), state("a", 6)}, []bool{false, false, false}},
+		{"serial goes back until a newer state", []*State{state("a", 5), state("a", 4), state("a", 4), state("a", 5)}, []bool{false, true, true, false}},
+		{"lineage changes once", []*State{state("a", 5), state("b", 1), state("b", 2)}, []bool{false, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, err := NewStateTracker(filepath.Join(t.TempDir(), "states.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, s := range tt.observed {
+				err := tracker.Observe(s)
+				var stale *StaleStateError
+				if got := errors.As(err, &stale); got != tt.wantStale[i] {
+					t.Errorf("Observe #%d (lineage %s, serial %d) error = %v, want stale %v", i, s.Lineage, s.Serial, err, tt.wantStale[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStateTrackerPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tracking", "states.json")
+
+	tracker, err := NewStateTracker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Observe(&State{Source: "prod.tfstate", Workspace: "eu", Lineage: "a", Serial: 7}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tracker.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := NewStateTracker(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stale *StaleStateError
+	err = reloaded.Observe(&State{Source: "prod.tfstate", Workspace: "eu", Lineage: "a", Serial: 6})
+	if !errors.As(err, &stale) || stale.Source != "prod.tfstate#eu" {
+		t.Errorf("Observe after reload error = %v, want stale state prod.tfstate#eu", err)
+	}
+	if err := reloaded.Observe(&State{Source: "prod.tfstate", Lineage: "a", Serial: 1}); err != nil {
+		t.Errorf("Observe of the default workspace error = %v, want it tracked separately", err)
+	}
+}